@@ -0,0 +1,277 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type SessionInfo struct {
+	ID          string
+	Label       string
+	RemoteAddr  string
+	Pid         int
+	PermitWrite bool
+	Muted       bool
+}
+
+func (app *App) addSession(context *clientContext) {
+	app.sessionsMutex.Lock()
+	app.sessions[context.id] = context
+	app.sessionsMutex.Unlock()
+
+	app.saveState()
+}
+
+func (app *App) removeSession(context *clientContext) {
+	app.sessionsMutex.Lock()
+	delete(app.sessions, context.id)
+	app.sessionsMutex.Unlock()
+
+	app.saveState()
+}
+
+func (app *App) findSession(id string) (*clientContext, bool) {
+	app.sessionsMutex.Lock()
+	defer app.sessionsMutex.Unlock()
+	context, ok := app.sessions[id]
+	return context, ok
+}
+
+func (app *App) Sessions() []SessionInfo {
+	app.sessionsMutex.Lock()
+	defer app.sessionsMutex.Unlock()
+
+	infos := make([]SessionInfo, 0, len(app.sessions))
+	for _, context := range app.sessions {
+		infos = append(infos, SessionInfo{
+			ID:          context.id,
+			Label:       context.label,
+			RemoteAddr:  context.request.RemoteAddr,
+			Pid:         context.command.Process.Pid,
+			PermitWrite: context.writable(),
+			Muted:       context.isMuted(),
+		})
+	}
+	return infos
+}
+
+// SetWritePermission grants or revokes write access of a running session.
+func (app *App) SetWritePermission(id string, permit bool) bool {
+	context, ok := app.findSession(id)
+	if !ok {
+		return false
+	}
+	if err := context.setWritable(permit); err != nil {
+		log.Printf("Failed to notify write permission to %s: %v", context.request.RemoteAddr, err)
+	}
+	log.Printf("Write permission for session %s (%s) set to %t", id, context.request.RemoteAddr, permit)
+	return true
+}
+
+// wrapAdmin lets only AdminUsers use the admin API, which controls the sessions of everyone.
+// Other users and clients authenticated with a bearer token are forbidden.
+func (app *App) wrapAdmin(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.authenticatedUser(r)
+		for _, admin := range app.options.AdminUsers {
+			if user != "" && user == admin {
+				handler(w, r)
+				return
+			}
+		}
+		log.Printf("Rejected admin request from %s (user: %q)", r.RemoteAddr, user)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+// SetMuted stops or resumes delivering output to a running session.
+// The command keeps running while the session is muted.
+func (app *App) SetMuted(id string, muted bool) bool {
+	context, ok := app.findSession(id)
+	if !ok {
+		return false
+	}
+	if err := context.setMuted(muted); err != nil {
+		log.Printf("Failed to notify mute state to %s: %v", context.request.RemoteAddr, err)
+	}
+	log.Printf("Output for session %s (%s) muted: %t", id, context.request.RemoteAddr, muted)
+	return true
+}
+
+// SignalSession sends a signal to the process group of a running session.
+func (app *App) SignalSession(id string, signal syscall.Signal) (bool, error) {
+	context, ok := app.findSession(id)
+	if !ok {
+		return false, nil
+	}
+	log.Printf("Sending signal %d to session %s (%s)", signal, id, context.request.RemoteAddr)
+	return true, syscall.Kill(-context.command.Process.Pid, signal)
+}
+
+// KillSession sends the close signal to a running session and closes its connection.
+// The session is given by its ID or the remote address of its client.
+func (app *App) KillSession(id string) bool {
+	context, ok := app.findSession(id)
+	if !ok {
+		app.sessionsMutex.Lock()
+		for _, other := range app.sessions {
+			if other.request.RemoteAddr == id {
+				context, ok = other, true
+				break
+			}
+		}
+		app.sessionsMutex.Unlock()
+	}
+	if !ok {
+		return false
+	}
+
+	log.Printf("Killing session %s (%s)", context.id, context.request.RemoteAddr)
+	syscall.Kill(-context.command.Process.Pid, syscall.Signal(context.closeSignal))
+	context.writeMutex.Lock()
+	context.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Session killed"), time.Now().Add(time.Second))
+	context.writeMutex.Unlock()
+	context.connection.Close()
+	return true
+}
+
+// Broadcast writes a message to the terminal of every running session,
+// e.g. to announce a restart, and returns the number of sessions reached.
+func (app *App) Broadcast(msg string) int {
+	data := []byte("\r\n" + strings.ReplaceAll(msg, "\n", "\r\n") + "\r\n")
+
+	app.sessionsMutex.Lock()
+	contexts := make([]*clientContext, 0, len(app.sessions))
+	for _, context := range app.sessions {
+		contexts = append(contexts, context)
+	}
+	app.sessionsMutex.Unlock()
+
+	reached := 0
+	for _, context := range contexts {
+		if err := context.writeOutput(data); err != nil {
+			log.Printf("Failed to broadcast to %s: %v", context.request.RemoteAddr, err)
+			continue
+		}
+		if app.options.SharedSession {
+			app.shareOutput(context, data)
+		}
+		reached++
+	}
+	log.Printf("Broadcast a message to %d sessions", reached)
+	return reached
+}
+
+func (app *App) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, app.Sessions())
+}
+
+func (app *App) handleAdminWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	permit, err := strconv.ParseBool(r.FormValue("permit"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !app.SetWritePermission(r.FormValue("id"), permit) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+func (app *App) handleAdminMute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	muted, err := strconv.ParseBool(r.FormValue("mute"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !app.SetMuted(r.FormValue("id"), muted) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) handleAdminSignal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	signal, err := parseSignal(r.FormValue("signal"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !app.signalAllowed(signal) {
+		log.Printf("Refused to send signal %d to session %s, which is not allowed", signal, r.FormValue("id"))
+		http.Error(w, "Signal not allowed", http.StatusForbidden)
+		return
+	}
+	found, err := app.SignalSession(r.FormValue("id"), signal)
+	if !found {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to send signal: %v", err)
+		http.Error(w, "Failed to send signal", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) handleAdminBroadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	message := r.FormValue("message")
+	if message == "" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"Sessions": app.Broadcast(message)})
+}
+
+func (app *App) handleAdminKill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !app.KillSession(r.FormValue("id")) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
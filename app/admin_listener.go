@@ -0,0 +1,65 @@
+package app
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+// serveAdmin starts serving the admin listener at AdminAddress, apart from the public one,
+// so that diagnostics such as pprof are never exposed publicly.
+// When basic authentication is enabled, only AdminUsers can use it:
+// profiles load the server and the command line may contain credentials.
+func (app *App) serveAdmin() (*http.Server, error) {
+	mux := http.NewServeMux()
+	if app.options.EnablePprof {
+		handle := func(pattern string, handler http.HandlerFunc) {
+			if app.options.EnableBasicAuth {
+				mux.Handle(pattern, app.wrapAdmin(handler))
+			} else {
+				mux.Handle(pattern, handler)
+			}
+		}
+		handle("/debug/pprof/", pprof.Index)
+		handle("/debug/pprof/cmdline", pprof.Cmdline)
+		handle("/debug/pprof/profile", pprof.Profile)
+		handle("/debug/pprof/symbol", pprof.Symbol)
+		handle("/debug/pprof/trace", pprof.Trace)
+	}
+
+	handler := http.Handler(mux)
+	if app.options.EnableBasicAuth {
+		handler = wrapBasicAuth(handler, credentialList(app.options), app.options.CredentialHashed, nil)
+	}
+
+	listener, err := net.Listen("tcp", app.options.AdminAddress)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: app.wrapLogger(handler)}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Admin listener stopped: %v", err)
+		}
+	}()
+
+	log.Printf("Admin listener is serving at %s", listener.Addr())
+	if app.options.EnablePprof {
+		log.Printf("Profiles are available at http://%s/debug/pprof/", listener.Addr())
+	}
+	return server, nil
+}
+
+// isLoopbackAddress reports whether the host:port address only accepts local connections.
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
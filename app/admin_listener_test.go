@@ -0,0 +1,110 @@
+package app
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+var adminAddressPattern = regexp.MustCompile(`Admin listener is serving at (\S+)`)
+
+// startAdmin runs serveAdmin on a free port and returns its address.
+func startAdmin(t *testing.T, app *App) string {
+	app.options.AdminAddress = "127.0.0.1:0"
+	var server *http.Server
+	var err error
+	logged := captureLog(func() { server, err = app.serveAdmin() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return adminAddressPattern.FindStringSubmatch(logged)[1]
+}
+
+func getStatus(t *testing.T, url string, user string, password string) int {
+	r, _ := http.NewRequest("GET", url, nil)
+	if user != "" {
+		r.SetBasicAuth(user, password)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAdminListenerPprof(t *testing.T) {
+	options := testOptions()
+	options.EnablePprof = true
+	options.EnableBasicAuth = true
+	options.Credentials = []string{"alice:secret", "bob:secret"}
+	options.AdminUsers = []string{"alice"}
+	address := startAdmin(t, newTestApp(t, options))
+
+	url := "http://" + address + "/debug/pprof/"
+	if status := getStatus(t, url, "", ""); status != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", status)
+	}
+	if status := getStatus(t, url, "alice", "secret"); status != http.StatusOK {
+		t.Errorf("expected 200 for an admin user, got %d", status)
+	}
+	// The command line of gotty may contain credentials.
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline"} {
+		var status int
+		captureLog(func() { status = getStatus(t, "http://"+address+path, "bob", "secret") })
+		if status != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for a user who isn't an admin, got %d", path, status)
+		}
+	}
+}
+
+func TestAdminListenerWithoutPprof(t *testing.T) {
+	address := startAdmin(t, newTestApp(t, testOptions()))
+	if status := getStatus(t, "http://"+address+"/debug/pprof/", "", ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 with pprof disabled, got %d", status)
+	}
+}
+
+func TestCheckConfigPprof(t *testing.T) {
+	options := testOptions()
+	options.EnablePprof = true
+	if err := CheckConfig(options); err == nil {
+		t.Error("pprof was accepted without an admin address")
+	}
+	options.AdminAddress = "127.0.0.1:6060"
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("valid configuration was rejected: %v", err)
+	}
+
+	// Profiles and command lines must not be exposed without authentication.
+	for _, address := range []string{"0.0.0.0:6060", ":6060", "192.0.2.1:6060"} {
+		options.AdminAddress = address
+		if err := CheckConfig(options); err == nil {
+			t.Errorf("pprof was accepted at %s without basic authentication", address)
+		}
+	}
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	if err := CheckConfig(options); err == nil {
+		t.Error("pprof with basic authentication was accepted without admin users")
+	}
+	options.AdminUsers = []string{"alice"}
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("pprof with basic authentication was rejected: %v", err)
+	}
+}
+
+func TestPprofAbsentFromPublicListener(t *testing.T) {
+	options := testOptions()
+	options.EnablePprof = true
+	options.AdminAddress = "127.0.0.1:0"
+	app := newTestApp(t, options)
+	server, path := runTCPApp(t, app)
+
+	for _, url := range []string{server + "/debug/pprof/", server + path + "/debug/pprof/"} {
+		if status := getStatus(t, url, "", ""); status != http.StatusNotFound {
+			t.Errorf("%s: expected 404 on the public listener, got %d", url, status)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAdminWritePermission(t *testing.T) {
+	options := testOptions()
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	if permit := readMessage(t, conn, SetWritePermit); permit != "false" {
+		t.Fatalf("session started with write permit %s", permit)
+	}
+	id := waitSessions(t, app, 1)[0].ID
+
+	// Input is dropped until permitted.
+	conn.WriteMessage(websocket.TextMessage, []byte("0dropped\n"))
+	// Input is handled in order, the pong tells it was.
+	conn.WriteMessage(websocket.TextMessage, []byte{Ping})
+	readMessage(t, conn, Pong)
+	w := postForm(app.handleAdminWrite, url.Values{"id": {id}, "permit": {"true"}})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("admin answered %d", w.Code)
+	}
+	if permit := readMessage(t, conn, SetWritePermit); permit != "true" {
+		t.Errorf("client was told write permit %s", permit)
+	}
+	if !app.Sessions()[0].PermitWrite {
+		t.Error("session is not listed as writable")
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("0permitted\n"))
+	if output := readOutput(t, conn, "permitted"); strings.Contains(output, "dropped") {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	postForm(app.handleAdminWrite, url.Values{"id": {id}, "permit": {"false"}})
+	if permit := readMessage(t, conn, SetWritePermit); permit != "false" {
+		t.Errorf("client was told write permit %s", permit)
+	}
+}
+
+func TestAdminWritePermissionErrors(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	if w := postForm(app.handleAdminWrite, url.Values{"id": {"missing"}, "permit": {"true"}}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown session, got %d", w.Code)
+	}
+	if w := postForm(app.handleAdminWrite, url.Values{"id": {"missing"}, "permit": {"maybe"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid permit, got %d", w.Code)
+	}
+}
+
+func TestAdminUsers(t *testing.T) {
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credentials = []string{"alice:secret", "bob:secret"}
+	options.AdminUsers = []string{"alice"}
+	app := newTestApp(t, options)
+	handler := app.wrapAdmin(app.handleAdminSessions)
+
+	tests := []struct {
+		user     string
+		password string
+		status   int
+	}{
+		{"alice", "secret", http.StatusOK},
+		// Any other user passing basic authentication isn't an admin.
+		{"bob", "secret", http.StatusForbidden},
+		{"alice", "guessed", http.StatusForbidden},
+		// Neither are clients authenticated with a bearer token.
+		{"", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/admin/sessions", nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.password)
+		} else {
+			r.Header.Set("Authorization", "Bearer token")
+		}
+		w := httptest.NewRecorder()
+		captureLog(func() { handler.ServeHTTP(w, r) })
+		if w.Code != test.status {
+			t.Errorf("%s:%s: expected %d, got %d", test.user, test.password, test.status, w.Code)
+		}
+	}
+}
+
+func TestCheckConfigAdminUsers(t *testing.T) {
+	options := testOptions()
+	options.EnableAdmin = true
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	if err := CheckConfig(options); err == nil {
+		t.Error("admin API was enabled without admin users")
+	}
+	options.AdminUsers = []string{"alice"}
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
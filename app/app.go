@@ -2,11 +2,9 @@ package app
 
 import (
 	"bytes"
-	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,6 +18,8 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,27 +31,18 @@ import (
 	"github.com/braintree/manners"
 	"github.com/elazarl/go-bindata-assetfs"
 	"github.com/gorilla/websocket"
-	"github.com/kr/pty"
 	"github.com/yudai/hcl"
 	"github.com/yudai/umutex"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
 )
 
 type InitMessage struct {
-	Arguments string `json:"Arguments,omitempty"`
-	AuthToken string `json:"AuthToken,omitempty"`
-}
-
-type ExecMessageReq struct {
-	Context   string
-	Command   string
-	Arguments []string
-}
-
-type ExecMessageRsp struct {
-	*ExecMessageReq
-	Output1 string
-	Output2 string
-	Error   string
+	Arguments string            `json:"Arguments,omitempty"`
+	AuthToken string            `json:"AuthToken,omitempty"`
+	Timestamp int64             `json:"Timestamp,omitempty"` // milliseconds since the epoch, as given by Date.now()
+	Command   string            `json:"Command,omitempty"`   // one of Commands, on the default endpoint
+	Env       map[string]string `json:"Env,omitempty"`       // only variables listed in PermitEnv are used
 }
 
 type App struct {
@@ -59,11 +50,28 @@ type App struct {
 	options *Options
 	uid     uint32
 	gid     uint32
+	groups  []uint32 // supplementary groups of RunAsUser
+
+	// System users of the authenticated users listed in UserMapping
+	userCredentials map[string]*syscall.Credential
+
+	// Directory commands start in, empty to inherit the one of gotty
+	workingDir string
+
+	// Directory commands are chrooted to, empty to run them in the root of gotty
+	chroot string
 
 	upgrader *websocket.Upgrader
 	server   *manners.GracefulServer
 
 	titleTemplate *template.Template
+	labelTemplate *template.Template
+	argsTemplate  *template.Template
+
+	trustedProxies []*net.IPNet
+	umask          int // -1 to inherit the umask of gotty
+	allowIPs       []*net.IPNet
+	denyIPs        []*net.IPNet
 
 	onceMutex *umutex.UnblockingMutex
 	timer     *time.Timer
@@ -71,63 +79,370 @@ type App struct {
 	// clientContext writes concurrently
 	// Use atomic operations.
 	connections *int64
+
+	// Unix time in nanoseconds until which new sessions are refused
+	// after failing to spawn a command. Use atomic operations.
+	spawnCooldownUntil *int64
+
+	sessions      map[string]*clientContext
+	sessionsMutex *sync.Mutex
+	stateMutex    *sync.Mutex
+
+	execJobs         *execJobStore
+	authTokens       *authTokens
+	reconnectLimiter *rateLimiter
+	recentClients    *recentClients
+	execLimiters     *rateLimiters
+
+	logStream   *logStream
+	accessLog   *log.Logger
+	auditLog    *auditLog
+	metrics     *metrics
+	webhook     *webhook
+	k8sEvents   *k8sEvents
+	eventSocket *eventSocket
+	startTime   time.Time
+
+	// Taken by each session being recorded when MaxConcurrentRecordings is set
+	recordingSlots chan struct{}
+	// Taken by each websocket handshake in progress when MaxPendingUpgrades is set
+	pendingUpgrades chan struct{}
+	// defaultHandshakeTimeout, shorter in tests
+	handshakeTimeout time.Duration
+
+	// The sessions clients attach to when SharedSession is set, by sharedSessionKey
+	sharedMutex    *sync.Mutex
+	sharedSessions map[string]*sharedSession
+
+	// Closed by Exit() to stop background goroutines.
+	quit     chan struct{}
+	quitOnce *sync.Once
 }
 
 type Options struct {
-	RunAsUser           string                 `hcl:"run_as_user"`
-	Address             string                 `hcl:"address"`
-	Port                string                 `hcl:"port"`
-	PermitWrite         bool                   `hcl:"permit_write"`
-	EnableBasicAuth     bool                   `hcl:"enable_basic_auth"`
-	Credential          string                 `hcl:"credential"`
-	EnableRandomUrl     bool                   `hcl:"enable_random_url"`
-	RandomUrlLength     int                    `hcl:"random_url_length"`
-	IndexFile           string                 `hcl:"index_file"`
-	EnableTLS           bool                   `hcl:"enable_tls"`
-	TLSCrtFile          string                 `hcl:"tls_crt_file"`
-	TLSKeyFile          string                 `hcl:"tls_key_file"`
-	EnableTLSClientAuth bool                   `hcl:"enable_tls_client_auth"`
-	TLSCACrtFile        string                 `hcl:"tls_ca_crt_file"`
-	TitleFormat         string                 `hcl:"title_format"`
-	EnableReconnect     bool                   `hcl:"enable_reconnect"`
-	ReconnectTime       int                    `hcl:"reconnect_time"`
-	MaxConnection       int                    `hcl:"max_connection"`
-	Once                bool                   `hcl:"once"`
-	Timeout             int                    `hcl:"timeout"`
-	PermitArguments     bool                   `hcl:"permit_arguments"`
-	CloseSignal         int                    `hcl:"close_signal"`
-	Preferences         HtermPrefernces        `hcl:"preferences"`
-	RawPreferences      map[string]interface{} `hcl:"preferences"`
-	Width               int                    `hcl:"width"`
-	Height              int                    `hcl:"height"`
+	RunAsUser               string                 `hcl:"run_as_user" yaml:"run_as_user"`
+	Address                 string                 `hcl:"address" yaml:"address"`
+	Port                    string                 `hcl:"port" yaml:"port"`
+	PermitWrite             bool                   `hcl:"permit_write" yaml:"permit_write"`
+	EnableBasicAuth         bool                   `hcl:"enable_basic_auth" yaml:"enable_basic_auth"`
+	Credential              string                 `hcl:"credential" yaml:"credential"`
+	EnableRandomUrl         bool                   `hcl:"enable_random_url" yaml:"enable_random_url"`
+	RandomUrlLength         int                    `hcl:"random_url_length" yaml:"random_url_length"`
+	IndexFile               string                 `hcl:"index_file" yaml:"index_file"`
+	EnableTLS               bool                   `hcl:"enable_tls" yaml:"enable_tls"`
+	TLSCrtFile              string                 `hcl:"tls_crt_file" yaml:"tls_crt_file"`
+	TLSKeyFile              string                 `hcl:"tls_key_file" yaml:"tls_key_file"`
+	EnableTLSClientAuth     bool                   `hcl:"enable_tls_client_auth" yaml:"enable_tls_client_auth"`
+	TLSCACrtFile            string                 `hcl:"tls_ca_crt_file" yaml:"tls_ca_crt_file"`
+	TitleFormat             string                 `hcl:"title_format" yaml:"title_format"`
+	EnableReconnect         bool                   `hcl:"enable_reconnect" yaml:"enable_reconnect"`
+	ReconnectTime           int                    `hcl:"reconnect_time" yaml:"reconnect_time"`
+	MaxConnection           int                    `hcl:"max_connection" yaml:"max_connection"`
+	Once                    bool                   `hcl:"once" yaml:"once"`
+	Timeout                 int                    `hcl:"timeout" yaml:"timeout"`
+	PermitArguments         bool                   `hcl:"permit_arguments" yaml:"permit_arguments"`
+	CloseSignal             int                    `hcl:"close_signal" yaml:"close_signal"`
+	Preferences             HtermPrefernces        `hcl:"preferences" yaml:"preferences"`
+	RawPreferences          map[string]interface{} `hcl:"preferences" yaml:"-"`
+	Width                   int                    `hcl:"width" yaml:"width"`
+	Height                  int                    `hcl:"height" yaml:"height"`
+	EnableAdmin             bool                   `hcl:"enable_admin" yaml:"enable_admin"`
+	AdminUsers              []string               `hcl:"admin_users" yaml:"admin_users"`
+	TrackCwd                bool                   `hcl:"track_cwd" yaml:"track_cwd"`
+	MaxInitMessageSize      int                    `hcl:"max_init_message_size" yaml:"max_init_message_size"`
+	LogFile                 string                 `hcl:"log_file" yaml:"log_file"`
+	LogMaxSizeMB            int                    `hcl:"log_max_size_mb" yaml:"log_max_size_mb"`
+	LogMaxBackups           int                    `hcl:"log_max_backups" yaml:"log_max_backups"`
+	LogMaxAgeDays           int                    `hcl:"log_max_age_days" yaml:"log_max_age_days"`
+	SessionLabelFormat      string                 `hcl:"session_label_format" yaml:"session_label_format"`
+	ExecJobMaxAge           int                    `hcl:"exec_job_max_age" yaml:"exec_job_max_age"`
+	ExecJobMaxCount         int                    `hcl:"exec_job_max_count" yaml:"exec_job_max_count"`
+	TrustedProxies          []string               `hcl:"trusted_proxies" yaml:"trusted_proxies"`
+	ForwardedProto          string                 `hcl:"forwarded_proto" yaml:"forwarded_proto"`
+	ReauthInterval          int                    `hcl:"reauth_interval" yaml:"reauth_interval"`
+	ReauthTimeout           int                    `hcl:"reauth_timeout" yaml:"reauth_timeout"`
+	MuteReplay              bool                   `hcl:"mute_replay" yaml:"mute_replay"`
+	MuteBufferSize          int                    `hcl:"mute_buffer_size" yaml:"mute_buffer_size"`
+	OutputOnly              bool                   `hcl:"output_only" yaml:"output_only"`
+	PrintQR                 bool                   `hcl:"print_qr" yaml:"print_qr"`
+	MaxResizesPerSecond     int                    `hcl:"max_resizes_per_second" yaml:"max_resizes_per_second"`
+	Commands                map[string][]string    `hcl:"commands" yaml:"commands"`
+	ListenRetries           int                    `hcl:"listen_retries" yaml:"listen_retries"`
+	ListenRetryDelay        int                    `hcl:"listen_retry_delay" yaml:"listen_retry_delay"`
+	ExecLogDir              string                 `hcl:"exec_log_dir" yaml:"exec_log_dir"`
+	CredentialHashed        bool                   `hcl:"credential_hashed" yaml:"credential_hashed"`
+	SpawnCooldown           int                    `hcl:"spawn_cooldown" yaml:"spawn_cooldown"`
+	Credentials             []string               `hcl:"credentials" yaml:"credentials"`
+	EnableAutoCert          bool                   `hcl:"enable_auto_cert" yaml:"enable_auto_cert"`
+	AutoCertDomains         []string               `hcl:"auto_cert_domains" yaml:"auto_cert_domains"`
+	AutoCertCacheDir        string                 `hcl:"auto_cert_cache_dir" yaml:"auto_cert_cache_dir"`
+	LogStreamMaxViewers     int                    `hcl:"log_stream_max_viewers" yaml:"log_stream_max_viewers"`
+	LogStreamRateLimit      int                    `hcl:"log_stream_rate_limit" yaml:"log_stream_rate_limit"`
+	LogStreamRateBurst      int                    `hcl:"log_stream_rate_burst" yaml:"log_stream_rate_burst"`
+	ArgumentTransform       string                 `hcl:"argument_transform" yaml:"argument_transform"`
+	PreSpawnValidate        []string               `hcl:"pre_spawn_validate" yaml:"pre_spawn_validate"`
+	PreSpawnValidateTimeout int                    `hcl:"pre_spawn_validate_timeout" yaml:"pre_spawn_validate_timeout"`
+	GenerateSelfSignedCert  bool                   `hcl:"generate_self_signed_cert" yaml:"generate_self_signed_cert"`
+	TLSMinVersion           string                 `hcl:"tls_min_version" yaml:"tls_min_version"`
+	TLSCipherSuites         []string               `hcl:"tls_cipher_suites" yaml:"tls_cipher_suites"`
+	MaxPasteBytes           int                    `hcl:"max_paste_bytes" yaml:"max_paste_bytes"`
+	PasteOverflow           string                 `hcl:"paste_overflow" yaml:"paste_overflow"`
+	StateFile               string                 `hcl:"state_file" yaml:"state_file"`
+	WSPingInterval          int                    `hcl:"ws_ping_interval" yaml:"ws_ping_interval"`
+	WSPongTimeout           int                    `hcl:"ws_pong_timeout" yaml:"ws_pong_timeout"`
+	AllowedOrigins          []string               `hcl:"allowed_origins" yaml:"allowed_origins"`
+	MaxClockSkew            int                    `hcl:"max_clock_skew" yaml:"max_clock_skew"`
+	PerUserHome             bool                   `hcl:"per_user_home" yaml:"per_user_home"`
+	HomeBaseDir             string                 `hcl:"home_base_dir" yaml:"home_base_dir"`
+	RecordDir               string                 `hcl:"record_dir" yaml:"record_dir"`
+	CommandCloseSignals     map[string]int         `hcl:"command_close_signals" yaml:"command_close_signals"`
+	PublicURL               string                 `hcl:"public_url" yaml:"public_url"`
+	EnableMetrics           bool                   `hcl:"enable_metrics" yaml:"enable_metrics"`
+	MetricsPath             string                 `hcl:"metrics_path" yaml:"metrics_path"`
+	MetricsSkipAuth         bool                   `hcl:"metrics_skip_auth" yaml:"metrics_skip_auth"`
+	HealthCheckPath         string                 `hcl:"health_check_path" yaml:"health_check_path"`
+	WebhookURL              string                 `hcl:"webhook_url" yaml:"webhook_url"`
+	WebhookQueueSize        int                    `hcl:"webhook_queue_size" yaml:"webhook_queue_size"`
+	WebhookMaxBackoff       int                    `hcl:"webhook_max_backoff" yaml:"webhook_max_backoff"`
+	EventSocket             string                 `hcl:"event_socket" yaml:"event_socket"`
+	ManifestArguments       string                 `hcl:"manifest_arguments" yaml:"manifest_arguments"`
+	AllowedSignals          []string               `hcl:"allowed_signals" yaml:"allowed_signals"`
+	StartupBufferSize       int                    `hcl:"startup_buffer_size" yaml:"startup_buffer_size"`
+	EnableK8sEvents         bool                   `hcl:"enable_k8s_events" yaml:"enable_k8s_events"`
+	Env                     map[string]string      `hcl:"env" yaml:"env"`
+	PermitEnv               []string               `hcl:"permit_env" yaml:"permit_env"`
+	ClearEnv                bool                   `hcl:"clear_env" yaml:"clear_env"`
+	WorkingDir              string                 `hcl:"working_dir" yaml:"working_dir"`
+	ReconnectRate           int                    `hcl:"reconnect_rate" yaml:"reconnect_rate"`
+	ReconnectBurst          int                    `hcl:"reconnect_burst" yaml:"reconnect_burst"`
+	ExecMaxArguments        int                    `hcl:"exec_max_arguments" yaml:"exec_max_arguments"`
+	ExecMaxArgLength        int                    `hcl:"exec_max_arg_length" yaml:"exec_max_arg_length"`
+	ExecTimeout             int                    `hcl:"exec_timeout" yaml:"exec_timeout"`
+	ExecMaxOutput           int                    `hcl:"exec_max_output" yaml:"exec_max_output"`
+	ExecMaxStdin            int                    `hcl:"exec_max_stdin" yaml:"exec_max_stdin"`
+	TLSPlaintextFallback    bool                   `hcl:"tls_plaintext_fallback" yaml:"tls_plaintext_fallback"`
+	ExecWhitelist           []string               `hcl:"exec_whitelist" yaml:"exec_whitelist"`
+	ExecAllowAny            bool                   `hcl:"exec_allow_any" yaml:"exec_allow_any"`
+	EnableClientConfig      bool                   `hcl:"enable_client_config" yaml:"enable_client_config"`
+	IdleTimeout             int                    `hcl:"idle_timeout" yaml:"idle_timeout"`
+	UserMapping             map[string]string      `hcl:"user_mapping" yaml:"user_mapping"`
+	RecordFifo              string                 `hcl:"record_fifo" yaml:"record_fifo"`
+	MaxSessionDuration      int                    `hcl:"max_session_duration" yaml:"max_session_duration"`
+	ShutdownTimeout         int                    `hcl:"shutdown_timeout" yaml:"shutdown_timeout"`
+	TLSCRLFile              string                 `hcl:"tls_crl_file" yaml:"tls_crl_file"`
+	ExecRateLimit           int                    `hcl:"exec_rate_limit" yaml:"exec_rate_limit"`
+	ExecRateBurst           int                    `hcl:"exec_rate_burst" yaml:"exec_rate_burst"`
+	LogFormat               string                 `hcl:"log_format" yaml:"log_format"`
+	StrictRunAsUser         bool                   `hcl:"strict_run_as_user" yaml:"strict_run_as_user"`
+	AccessLogFile           string                 `hcl:"access_log_file" yaml:"access_log_file"`
+	AccessLogMaxSizeMB      int                    `hcl:"access_log_max_size_mb" yaml:"access_log_max_size_mb"`
+	MaxConcurrentRecordings int                    `hcl:"max_concurrent_recordings" yaml:"max_concurrent_recordings"`
+	RecordingLimitPolicy    string                 `hcl:"recording_limit_policy" yaml:"recording_limit_policy"`
+	AuditLogFile            string                 `hcl:"audit_log_file" yaml:"audit_log_file"`
+	AllowIPs                []string               `hcl:"allow_ips" yaml:"allow_ips"`
+	DenyIPs                 []string               `hcl:"deny_ips" yaml:"deny_ips"`
+	TrustXForwardedFor      bool                   `hcl:"trust_x_forwarded_for" yaml:"trust_x_forwarded_for"`
+	TranscriptDir           string                 `hcl:"transcript_dir" yaml:"transcript_dir"`
+	EnableFallbackUI        bool                   `hcl:"enable_fallback_ui" yaml:"enable_fallback_ui"`
+	EnableBearerAuth        bool                   `hcl:"enable_bearer_auth" yaml:"enable_bearer_auth"`
+	BearerTokens            []string               `hcl:"bearer_tokens" yaml:"bearer_tokens"`
+	MaxPendingUpgrades      int                    `hcl:"max_pending_upgrades" yaml:"max_pending_upgrades"`
+	Umask                   string                 `hcl:"umask" yaml:"umask"`
+	AuthMaxAttempts         int                    `hcl:"auth_max_attempts" yaml:"auth_max_attempts"`
+	AuthLockoutSeconds      int                    `hcl:"auth_lockout_seconds" yaml:"auth_lockout_seconds"`
+	StatusPath              string                 `hcl:"status_path" yaml:"status_path"`
+	AdminAddress            string                 `hcl:"admin_address" yaml:"admin_address"`
+	EnablePprof             bool                   `hcl:"enable_pprof" yaml:"enable_pprof"`
+	SharedSession           bool                   `hcl:"shared_session" yaml:"shared_session"`
+	PtyReserve              int                    `hcl:"pty_reserve" yaml:"pty_reserve"`
+	SessionDurationBuckets  []float64              `hcl:"session_duration_buckets" yaml:"session_duration_buckets"`
+	RequestDurationBuckets  []float64              `hcl:"request_duration_buckets" yaml:"request_duration_buckets"`
+	Subprotocols            []string               `hcl:"subprotocols" yaml:"subprotocols"`
+	MinClientKeyBits        int                    `hcl:"min_client_key_bits" yaml:"min_client_key_bits"`
+	ExecStreamHeartbeat     int                    `hcl:"exec_stream_heartbeat" yaml:"exec_stream_heartbeat"`
+	UnixSocket              string                 `hcl:"unix_socket" yaml:"unix_socket"`
+	UnixSocketMode          string                 `hcl:"unix_socket_mode" yaml:"unix_socket_mode"`
+	Interface               string                 `hcl:"interface" yaml:"interface"`
+	ExitMessages            map[string]string      `hcl:"exit_messages" yaml:"exit_messages"`
+	AuditLogFlushInterval   int                    `hcl:"audit_log_flush_interval" yaml:"audit_log_flush_interval"`
+	NoNewPrivs              bool                   `hcl:"no_new_privs" yaml:"no_new_privs"`
+	Chroot                  string                 `hcl:"chroot" yaml:"chroot"`
 }
 
 var Version = "1.0.0"
 
 var DefaultOptions = Options{
-	RunAsUser:           "root",
-	Address:             "",
-	Port:                "8080",
-	PermitWrite:         false,
-	EnableBasicAuth:     false,
-	Credential:          "",
-	EnableRandomUrl:     false,
-	RandomUrlLength:     8,
-	IndexFile:           "",
-	EnableTLS:           false,
-	TLSCrtFile:          "~/.gotty.crt",
-	TLSKeyFile:          "~/.gotty.key",
-	EnableTLSClientAuth: false,
-	TLSCACrtFile:        "~/.gotty.ca.crt",
-	TitleFormat:         "GoTTY - {{ .Command }} ({{ .Hostname }})",
-	EnableReconnect:     false,
-	ReconnectTime:       10,
-	MaxConnection:       0,
-	Once:                false,
-	CloseSignal:         1, // syscall.SIGHUP
-	Preferences:         HtermPrefernces{},
-	Width:               0,
-	Height:              0,
+	RunAsUser:               "root",
+	Address:                 "",
+	Port:                    "8080",
+	PermitWrite:             false,
+	EnableBasicAuth:         false,
+	Credential:              "",
+	EnableRandomUrl:         false,
+	RandomUrlLength:         8,
+	IndexFile:               "",
+	EnableTLS:               false,
+	TLSCrtFile:              "~/.gotty.crt",
+	TLSKeyFile:              "~/.gotty.key",
+	EnableTLSClientAuth:     false,
+	TLSCACrtFile:            "~/.gotty.ca.crt",
+	TitleFormat:             "GoTTY - {{ .Command }} ({{ .Hostname }})",
+	EnableReconnect:         false,
+	ReconnectTime:           10,
+	MaxConnection:           0,
+	Once:                    false,
+	CloseSignal:             1, // syscall.SIGHUP
+	Preferences:             HtermPrefernces{},
+	Width:                   0,
+	Height:                  0,
+	EnableAdmin:             false,
+	AdminUsers:              []string{},
+	TrackCwd:                false,
+	MaxInitMessageSize:      64 * 1024,
+	LogFile:                 "",
+	LogMaxSizeMB:            100,
+	LogMaxBackups:           3,
+	LogMaxAgeDays:           0,
+	SessionLabelFormat:      "",
+	ExecJobMaxAge:           600,
+	ExecJobMaxCount:         100,
+	TrustedProxies:          []string{},
+	ForwardedProto:          "",
+	ReauthInterval:          0,
+	ReauthTimeout:           30,
+	MuteReplay:              false,
+	MuteBufferSize:          64 * 1024,
+	OutputOnly:              false,
+	PrintQR:                 false,
+	MaxResizesPerSecond:     0,
+	Commands:                map[string][]string{},
+	ListenRetries:           0,
+	ListenRetryDelay:        500,
+	ExecLogDir:              "",
+	CredentialHashed:        false,
+	SpawnCooldown:           0,
+	LogStreamMaxViewers:     2,
+	LogStreamRateLimit:      0,
+	LogStreamRateBurst:      100,
+	Credentials:             []string{},
+	EnableAutoCert:          false,
+	AutoCertDomains:         []string{},
+	AutoCertCacheDir:        "~/.gotty.autocert",
+	ArgumentTransform:       "",
+	PreSpawnValidate:        []string{},
+	PreSpawnValidateTimeout: 10,
+	GenerateSelfSignedCert:  false,
+	TLSMinVersion:           "",
+	TLSCipherSuites:         []string{},
+	MaxPasteBytes:           0,
+	PasteOverflow:           "reject",
+	StateFile:               "",
+	WSPingInterval:          0,
+	WSPongTimeout:           10,
+	AllowedOrigins:          []string{},
+	MaxClockSkew:            0,
+	PerUserHome:             false,
+	HomeBaseDir:             "",
+	RecordDir:               "",
+	CommandCloseSignals:     map[string]int{},
+	PublicURL:               "",
+	EnableMetrics:           false,
+	MetricsPath:             "/metrics",
+	MetricsSkipAuth:         false,
+	HealthCheckPath:         "/healthz",
+	WebhookURL:              "",
+	WebhookQueueSize:        1000,
+	WebhookMaxBackoff:       60,
+	EventSocket:             "",
+	ManifestArguments:       "redacted",
+	AllowedSignals:          []string{"SIGHUP", "SIGINT", "SIGTERM"},
+	StartupBufferSize:       64 * 1024,
+	EnableK8sEvents:         false,
+	Env:                     map[string]string{},
+	PermitEnv:               []string{},
+	ClearEnv:                false,
+	WorkingDir:              "",
+	ReconnectRate:           0,
+	ReconnectBurst:          10,
+	ExecMaxArguments:        0,
+	ExecMaxArgLength:        0,
+	ExecTimeout:             defaultExecTimeout,
+	ExecMaxOutput:           defaultExecMaxOutput,
+	ExecMaxStdin:            defaultExecMaxStdin,
+	TLSPlaintextFallback:    false,
+	ExecWhitelist:           []string{},
+	ExecAllowAny:            false,
+	EnableClientConfig:      false,
+	IdleTimeout:             0,
+	UserMapping:             map[string]string{},
+	RecordFifo:              "",
+	MaxSessionDuration:      0,
+	ShutdownTimeout:         0,
+	TLSCRLFile:              "",
+	ExecRateLimit:           0,
+	ExecRateBurst:           10,
+	LogFormat:               "text",
+	StrictRunAsUser:         false,
+	AccessLogFile:           "",
+	AccessLogMaxSizeMB:      100,
+	MaxConcurrentRecordings: 0,
+	RecordingLimitPolicy:    "skip",
+	AuditLogFile:            "",
+	AllowIPs:                []string{},
+	DenyIPs:                 []string{},
+	TrustXForwardedFor:      false,
+	TranscriptDir:           "",
+	EnableFallbackUI:        false,
+	EnableBearerAuth:        false,
+	BearerTokens:            []string{},
+	MaxPendingUpgrades:      0,
+	Umask:                   "",
+	AuthMaxAttempts:         0,
+	AuthLockoutSeconds:      300,
+	StatusPath:              "",
+	AdminAddress:            "",
+	EnablePprof:             false,
+	SharedSession:           false,
+	PtyReserve:              0,
+	SessionDurationBuckets:  []float64{},
+	RequestDurationBuckets:  []float64{},
+	Subprotocols:            []string{protocolGotty},
+	MinClientKeyBits:        0,
+	ExecStreamHeartbeat:     0,
+	UnixSocket:              "",
+	UnixSocketMode:          "0660",
+	Interface:               "",
+	ExitMessages:            map[string]string{},
+	AuditLogFlushInterval:   0,
+	NoNewPrivs:              false,
+	Chroot:                  "",
+}
+
+// Names of paths served by gotty itself, which can't be used as command names.
+var reservedPaths = map[string]bool{
+	"ws":            true,
+	"js":            true,
+	"auth_token.js": true,
+	"favicon.png":   true,
+	"rexec":         true,
+	"admin":         true,
+	"config.json":   true,
+}
+
+// configuredPaths returns the first segments of the paths of the status, the health check and the metrics,
+// which can't be used as command names either.
+func configuredPaths(options *Options) map[string]bool {
+	paths := []string{options.StatusPath, options.HealthCheckPath}
+	if options.EnableMetrics {
+		paths = append(paths, options.MetricsPath)
+	}
+	names := make(map[string]bool)
+	for _, path := range paths {
+		if path != "" {
+			names[strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]] = true
+		}
+	}
+	return names
 }
 
 func New(command []string, options *Options) (*App, error) {
@@ -136,23 +451,127 @@ func New(command []string, options *Options) (*App, error) {
 		return nil, errors.New("Title format string syntax error")
 	}
 
+	var labelTemplate *template.Template
+	if options.SessionLabelFormat != "" {
+		labelTemplate, err = template.New("label").Parse(options.SessionLabelFormat)
+		if err != nil {
+			return nil, errors.New("Session label format string syntax error")
+		}
+	}
+
+	var argsTemplate *template.Template
+	if options.ArgumentTransform != "" {
+		argsTemplate, err = template.New("argument").Parse(options.ArgumentTransform)
+		if err != nil {
+			return nil, errors.New("Argument transform template syntax error")
+		}
+		if err := argsTemplate.Execute(ioutil.Discard, &ArgumentVars{}); err != nil {
+			return nil, fmt.Errorf("Invalid argument transform template: %v", err)
+		}
+	}
+
+	trustedProxies, err := parseCIDRs(options.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	umask, err := parseUmask(options.Umask)
+	if err != nil {
+		return nil, err
+	}
+	allowIPs, err := parseCIDRs(options.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	denyIPs, err := parseCIDRs(options.DenyIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	var chroot string
+	if options.Chroot != "" {
+		chroot = ExpandHomeDir(options.Chroot)
+		if err := checkChroot(chroot, chrootCommands(command, options)); err != nil {
+			return nil, errors.New("Invalid chroot: " + err.Error())
+		}
+	}
+
 	connections := int64(0)
+	spawnCooldownUntil := int64(0)
 
-	return &App{
+	app := &App{
 		command: command,
 		options: options,
+		chroot:  chroot,
 
 		upgrader: &websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
-			Subprotocols:    []string{"gotty"},
+			Subprotocols:    options.Subprotocols,
 		},
 
 		titleTemplate: titleTemplate,
+		labelTemplate: labelTemplate,
+		argsTemplate:  argsTemplate,
+
+		trustedProxies: trustedProxies,
+		allowIPs:       allowIPs,
+		umask:          umask,
+		denyIPs:        denyIPs,
 
 		onceMutex:   umutex.New(),
 		connections: &connections,
-	}, nil
+
+		spawnCooldownUntil: &spawnCooldownUntil,
+
+		sessions:      make(map[string]*clientContext),
+		sessionsMutex: &sync.Mutex{},
+		stateMutex:    &sync.Mutex{},
+
+		execJobs: newExecJobStore(
+			time.Duration(options.ExecJobMaxAge)*time.Second,
+			options.ExecJobMaxCount,
+		),
+		authTokens: newAuthTokens(),
+
+		logStream: newLogStream(options.LogStreamMaxViewers),
+		metrics:   newMetrics(options.SessionDurationBuckets, options.RequestDurationBuckets, &connections),
+		startTime: time.Now(),
+
+		handshakeTimeout: defaultHandshakeTimeout,
+
+		sharedMutex:    &sync.Mutex{},
+		sharedSessions: make(map[string]*sharedSession),
+
+		quit:     make(chan struct{}),
+		quitOnce: &sync.Once{},
+	}
+	app.upgrader.CheckOrigin = app.checkOrigin
+	if options.ReconnectRate > 0 {
+		app.reconnectLimiter = newRateLimiter(options.ReconnectRate, options.ReconnectBurst)
+		app.recentClients = newRecentClients()
+	}
+	if options.MaxPendingUpgrades > 0 {
+		app.pendingUpgrades = make(chan struct{}, options.MaxPendingUpgrades)
+	}
+	if options.MaxConcurrentRecordings > 0 {
+		app.recordingSlots = make(chan struct{}, options.MaxConcurrentRecordings)
+	}
+	if options.ExecRateLimit > 0 {
+		app.execLimiters = newRateLimiters(options.ExecRateLimit, options.ExecRateBurst)
+	}
+	if options.WebhookURL != "" {
+		app.webhook = newWebhook(
+			options.WebhookURL,
+			options.WebhookQueueSize,
+			time.Duration(options.WebhookMaxBackoff)*time.Second,
+		)
+		app.metrics.watchWebhook(app.webhook)
+	}
+	if options.EventSocket != "" {
+		app.eventSocket = newEventSocket(ExpandHomeDir(options.EventSocket))
+	}
+
+	return app, nil
 }
 
 func ApplyConfigFile(options *Options, filePath string) error {
@@ -168,28 +587,332 @@ func ApplyConfigFile(options *Options, filePath string) error {
 		return err
 	}
 
-	if err := hcl.Decode(options, string(fileString)); err != nil {
-		return err
+	switch filepath.Ext(filePath) {
+	case "", ".hcl":
+		if err := hcl.Decode(options, string(fileString)); err != nil {
+			return err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(fileString, options); err != nil {
+			return err
+		}
+		// Preferences given in the file are passed through to hterm as they are.
+		var raw struct {
+			Preferences map[string]interface{} `yaml:"preferences"`
+		}
+		if err := yaml.Unmarshal(fileString, &raw); err != nil {
+			return err
+		}
+		if raw.Preferences != nil {
+			options.RawPreferences = raw.Preferences
+		}
+	default:
+		return errors.New("Unsupported config file format: " + filePath + " (use .hcl, .yaml or .yml)")
 	}
 
 	return nil
 }
 
 func CheckConfig(options *Options) error {
-	if options.EnableTLSClientAuth && !options.EnableTLS {
+	if options.EnableTLSClientAuth && !options.EnableTLS && !options.EnableAutoCert {
 		return errors.New("TLS client authentication is enabled, but TLS is not enabled")
 	}
+	if !increasing(options.SessionDurationBuckets) || !increasing(options.RequestDurationBuckets) {
+		return errors.New("Histogram buckets must be in increasing order")
+	}
+	if err := checkSubprotocols(options.Subprotocols); err != nil {
+		return err
+	}
+	for code := range options.ExitMessages {
+		if _, err := strconv.Atoi(code); err != nil {
+			return errors.New("Invalid exit code in exit messages: " + code)
+		}
+	}
+	if options.Interface != "" && (options.Address != "" || options.UnixSocket != "") {
+		return errors.New("Interface can't be used with an address or a unix socket")
+	}
+	if options.UnixSocket != "" {
+		if options.EnableTLS || options.EnableAutoCert {
+			return errors.New("TLS can't be used with a unix socket, terminate TLS on the proxy instead")
+		}
+		if _, err := parseSocketMode(options.UnixSocketMode); err != nil {
+			return err
+		}
+	}
+	if options.TLSCRLFile != "" && !options.EnableTLSClientAuth {
+		return errors.New("CRL file is given, but TLS client authentication is not enabled")
+	}
+	if options.MinClientKeyBits > 0 && !options.EnableTLSClientAuth {
+		return errors.New("Minimum client key size is given, but TLS client authentication is not enabled")
+	}
+	if options.TLSPlaintextFallback {
+		if !options.EnableTLS && !options.EnableAutoCert {
+			return errors.New("Plaintext fallback is enabled, but TLS is not enabled")
+		}
+		if options.EnableTLSClientAuth {
+			return errors.New("Plaintext fallback can't be used with TLS client authentication")
+		}
+	}
+	if options.EnableAutoCert {
+		if options.TLSCrtFile != DefaultOptions.TLSCrtFile || options.TLSKeyFile != DefaultOptions.TLSKeyFile {
+			return errors.New("ACME certificates are enabled, but TLS crt/key files are also specified")
+		}
+		if len(options.AutoCertDomains) == 0 {
+			return errors.New("ACME certificates are enabled, but no domain is given")
+		}
+	}
+	if options.EnableBearerAuth && len(options.BearerTokens) == 0 {
+		return errors.New("Bearer authentication is enabled, but no token is given")
+	}
+	if options.EnableBasicAuth && len(credentialList(options)) == 0 {
+		return errors.New("Basic authentication is enabled, but no credential is given")
+	}
+	if options.CredentialHashed {
+		for _, credential := range credentialList(options) {
+			_, hash, ok := splitCredential(credential)
+			if !ok {
+				return errors.New("Hashed credential must be in the form of user:bcrypt-hash")
+			}
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return errors.New("Hashed credential is not a valid bcrypt hash: " + err.Error())
+			}
+		}
+	}
+	if options.EnableAdmin && !options.EnableBasicAuth {
+		return errors.New("Admin API is enabled, but basic authentication is not enabled")
+	}
+	if options.EnableAdmin && len(options.AdminUsers) == 0 {
+		return errors.New("Admin API is enabled, but no admin user is given")
+	}
+	if _, err := parseCIDRs(options.TrustedProxies); err != nil {
+		return err
+	}
+	if options.TrustXForwardedFor && len(options.TrustedProxies) == 0 {
+		return errors.New("X-Forwarded-For is trusted, but no trusted proxy is given")
+	}
+	if options.AuthMaxAttempts > 0 && options.AuthLockoutSeconds <= 0 {
+		return errors.New("Authentication lockout is enabled, but its duration is not positive")
+	}
+	if _, err := parseUmask(options.Umask); err != nil {
+		return err
+	}
+	if _, err := parseCIDRs(options.AllowIPs); err != nil {
+		return err
+	}
+	if _, err := parseCIDRs(options.DenyIPs); err != nil {
+		return err
+	}
+	switch options.ForwardedProto {
+	case "", "http", "https":
+	default:
+		return errors.New("Forwarded proto must be either http or https")
+	}
+	configured := configuredPaths(options)
+	for name, command := range options.Commands {
+		if name == "" || strings.Contains(name, "/") || reservedPaths[name] || configured[name] {
+			return errors.New("Invalid command name: " + name)
+		}
+		if len(command) == 0 {
+			return errors.New("No command given for: " + name)
+		}
+	}
+	for name, signal := range options.CommandCloseSignals {
+		if _, ok := options.Commands[name]; !ok {
+			return errors.New("Close signal given for unknown command: " + name)
+		}
+		if signal <= 0 {
+			return errors.New("Invalid close signal for: " + name)
+		}
+	}
+	if options.ReauthInterval > 0 && options.ReauthTimeout <= 0 {
+		return errors.New("Re-authentication is enabled, but re-authentication timeout is not positive")
+	}
+	switch options.PasteOverflow {
+	case "reject", "truncate":
+	default:
+		return errors.New("Paste overflow must be either reject or truncate")
+	}
+	if _, err := parseTLSVersion(options.TLSMinVersion); err != nil {
+		return err
+	}
+	if _, err := parseCipherSuites(options.TLSCipherSuites); err != nil {
+		return err
+	}
+	for name := range options.Env {
+		if !validEnvName(name) {
+			return errors.New("Invalid environment variable name: " + name)
+		}
+	}
+	for _, name := range options.PermitEnv {
+		if !validEnvName(name) {
+			return errors.New("Invalid environment variable name: " + name)
+		}
+	}
+	if options.ReconnectRate < 0 {
+		return errors.New("Reconnect rate must not be negative")
+	}
+	if options.LogFormat != "" && options.LogFormat != "text" && options.LogFormat != "json" {
+		return errors.New("Unsupported log format: " + options.LogFormat + " (use text or json)")
+	}
+	if options.RecordingLimitPolicy != "" && options.RecordingLimitPolicy != "skip" && options.RecordingLimitPolicy != "queue" {
+		return errors.New("Unsupported recording limit policy: " + options.RecordingLimitPolicy + " (use skip or queue)")
+	}
+	if options.ExecRateLimit < 0 {
+		return errors.New("Exec rate limit must not be negative")
+	}
+	if options.LogStreamRateLimit < 0 {
+		return errors.New("Log stream rate limit must not be negative")
+	}
+	if options.StartupBufferSize < 0 {
+		return errors.New("Startup buffer size must not be negative")
+	}
+	if err := checkAllowedSignals(options.AllowedSignals); err != nil {
+		return err
+	}
+	if err := checkManifestArguments(options.ManifestArguments); err != nil {
+		return err
+	}
+	if options.WebhookURL != "" {
+		u, err := url.Parse(options.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("Webhook URL must be an absolute http or https URL")
+		}
+		if options.WebhookQueueSize <= 0 || options.WebhookMaxBackoff <= 0 {
+			return errors.New("Webhook queue size and max backoff must be positive")
+		}
+	}
+	if options.HealthCheckPath != "" && !strings.HasPrefix(options.HealthCheckPath, "/") {
+		return errors.New("Health check path must start with /")
+	}
+	if options.EnablePprof && options.AdminAddress == "" {
+		return errors.New("Pprof is enabled, but no admin address is given")
+	}
+	if options.EnablePprof && !isLoopbackAddress(options.AdminAddress) && !options.EnableBasicAuth {
+		return errors.New("Pprof is served on a non-loopback admin address, but basic authentication is not enabled")
+	}
+	if options.EnablePprof && options.EnableBasicAuth && len(options.AdminUsers) == 0 {
+		return errors.New("Pprof is enabled with basic authentication, but no admin user is given")
+	}
+	if options.StatusPath != "" && !strings.HasPrefix(options.StatusPath, "/") {
+		return errors.New("Status path must start with /")
+	}
+	if options.EnableMetrics && !strings.HasPrefix(options.MetricsPath, "/") {
+		return errors.New("Metrics path must start with /")
+	}
+	if options.PublicURL != "" {
+		u, err := url.Parse(options.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("Public URL must be an absolute http or https URL")
+		}
+		if len(options.AllowedOrigins) > 0 {
+			return errors.New("Public URL pins the origin, but allowed origins are also given")
+		}
+	}
+	for _, pattern := range options.AllowedOrigins {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New("Invalid allowed origin: " + pattern)
+		}
+	}
+	if options.PerUserHome {
+		if !options.EnableBasicAuth {
+			return errors.New("Per user home directories are enabled, but basic authentication is not enabled")
+		}
+		if options.HomeBaseDir == "" {
+			return errors.New("Per user home directories are enabled, but no base directory is given")
+		}
+	}
+	if options.Chroot != "" && options.PerUserHome {
+		return errors.New("Per user home directories can't be used with a chroot")
+	}
+	if len(options.UserMapping) > 0 && !options.EnableBasicAuth {
+		return errors.New("User mapping is given, but basic authentication is not enabled")
+	}
+	if options.WSPingInterval > 0 && options.WSPongTimeout <= 0 {
+		return errors.New("Websocket ping is enabled, but pong timeout is not positive")
+	}
+	if len(options.PreSpawnValidate) > 0 && options.PreSpawnValidateTimeout <= 0 {
+		return errors.New("Pre-spawn validation is enabled, but its timeout is not positive")
+	}
 	return nil
 }
 
 func (app *App) Run() error {
+	if app.options.LogFile != "" {
+		logFile, err := openRotatingFile(
+			ExpandHomeDir(app.options.LogFile),
+			int64(app.options.LogMaxSizeMB)*1024*1024,
+			app.options.LogMaxBackups,
+			time.Duration(app.options.LogMaxAgeDays)*24*time.Hour,
+		)
+		if err != nil {
+			return errors.New("Failed to open log file: " + err.Error())
+		}
+		defer logFile.Close()
+		log.Printf("Writing logs to %s", app.options.LogFile)
+		log.SetOutput(io.MultiWriter(logFile, app.logStream))
+		defer log.SetOutput(os.Stderr)
+	}
+	if app.options.AccessLogFile != "" {
+		// Backups are kept like those of LogFile.
+		accessLogFile, err := openRotatingFile(
+			ExpandHomeDir(app.options.AccessLogFile),
+			int64(app.options.AccessLogMaxSizeMB)*1024*1024,
+			app.options.LogMaxBackups,
+			time.Duration(app.options.LogMaxAgeDays)*24*time.Hour,
+		)
+		if err != nil {
+			return errors.New("Failed to open access log file: " + err.Error())
+		}
+		defer accessLogFile.Close()
+		log.Printf("Writing access logs to %s", app.options.AccessLogFile)
+		app.accessLog = log.New(accessLogFile, "", log.LstdFlags)
+	}
+	if app.options.AuditLogFile != "" {
+		auditLog, err := openAuditLog(
+			ExpandHomeDir(app.options.AuditLogFile),
+			time.Duration(app.options.AuditLogFlushInterval)*time.Millisecond,
+		)
+		if err != nil {
+			return errors.New("Failed to open audit log file: " + err.Error())
+		}
+		defer auditLog.Close()
+		log.Printf("Writing audit records to %s", app.options.AuditLogFile)
+		app.auditLog = auditLog
+	}
+
 	log.Printf("Signal %d will be sent to the command process when gotty close it.", app.options.CloseSignal)
+	for name, signal := range app.options.CommandCloseSignals {
+		log.Printf("Signal %d will be sent to the command process of %s instead.", signal, name)
+	}
 
-	uid, gid := app.lookupUidGid()
+	uid, gid, groups, err := app.lookupUidGid()
+	if err != nil {
+		return err
+	}
 	app.uid = uid
 	app.gid = gid
+	app.groups = groups
+	if app.options.NoNewPrivs {
+		log.Printf("Commands can't gain privileges through setuid binaries")
+	}
 
-	if app.options.PermitWrite {
+	userCredentials, err := resolveUserMapping(app.options.UserMapping)
+	if err != nil {
+		return err
+	}
+	app.userCredentials = userCredentials
+
+	if app.chroot != "" {
+		log.Printf("Commands run chrooted to %s", app.chroot)
+	}
+	app.workingDir = app.resolveWorkingDir()
+	if app.workingDir != "" {
+		log.Printf("Commands start in %s", app.workingDir)
+	}
+
+	if app.options.OutputOnly {
+		log.Printf("Output only mode, the command reads from %s and client input is dropped.", os.DevNull)
+	} else if app.options.PermitWrite {
 		log.Printf("Permitting clients to write input to the PTY.")
 	}
 
@@ -202,7 +925,15 @@ func (app *App) Run() error {
 		path += "/" + generateRandomString(app.options.RandomUrlLength)
 	}
 
-	endpoint := net.JoinHostPort(app.options.Address, app.options.Port)
+	address := app.options.Address
+	if app.options.Interface != "" {
+		address, err = interfaceAddress(app.options.Interface)
+		if err != nil {
+			return err
+		}
+		log.Printf("Listening on %s of network interface %s", address, app.options.Interface)
+	}
+	endpoint := net.JoinHostPort(address, app.options.Port)
 
 	wsHandler := http.HandlerFunc(app.handleWS)
 	customIndexHandler := http.HandlerFunc(app.handleCustomIndex)
@@ -214,58 +945,157 @@ func (app *App) Run() error {
 
 	var siteMux = http.NewServeMux()
 
+	fallbackUI := false
 	if app.options.IndexFile != "" {
 		log.Printf("Using index file at " + app.options.IndexFile)
-		siteMux.Handle(path+"/", customIndexHandler)
-	} else {
-		siteMux.Handle(path+"/", http.StripPrefix(path+"/", staticHandler))
+	} else if app.options.EnableFallbackUI && !bundledAssetsAvailable() {
+		log.Printf("Bundled frontend is not available, serving the fallback terminal")
+		fallbackUI = true
+	}
+	handleTerminal := func(prefix string) {
+		if app.options.IndexFile != "" {
+			siteMux.Handle(prefix+"/", exactPath(prefix+"/", customIndexHandler))
+		} else if fallbackUI {
+			siteMux.Handle(prefix+"/", exactPath(prefix+"/", http.HandlerFunc(app.handleFallbackIndex)))
+		} else {
+			siteMux.Handle(prefix+"/", exactPath(prefix+"/", http.StripPrefix(prefix+"/", staticHandler)))
+		}
+		siteMux.Handle(prefix+"/auth_token.js", authTokenHandler)
+		if app.options.EnableClientConfig {
+			siteMux.Handle(prefix+"/config.json", http.HandlerFunc(app.handleClientConfig))
+		}
+		siteMux.Handle(prefix+"/js/", http.StripPrefix(prefix+"/", staticHandler))
+		siteMux.Handle(prefix+"/favicon.png", http.StripPrefix(prefix+"/", staticHandler))
+	}
+	handleTerminal(path)
+	for name := range app.options.Commands {
+		handleTerminal(path + "/" + name)
+	}
+	if path != "" {
+		siteMux.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			index := app.canonicalURL(r, path+"/")
+			index.RawQuery = r.URL.RawQuery
+			http.Redirect(w, r, index.String(), http.StatusMovedPermanently)
+		}))
 	}
-	siteMux.Handle(path+"/auth_token.js", authTokenHandler)
-	siteMux.Handle(path+"/js/", http.StripPrefix(path+"/", staticHandler))
-	siteMux.Handle(path+"/favicon.png", http.StripPrefix(path+"/", staticHandler))
 	siteMux.Handle(path+"/rexec", remoteExecHandler)
+	if app.options.ExecAllowAny {
+		log.Printf("Remote exec accepts any command")
+	} else if len(app.options.ExecWhitelist) == 0 {
+		log.Printf("Remote exec denies all commands, none is whitelisted")
+	}
+
+	if app.options.EnableAdmin {
+		log.Printf("Admin API is available at %s/admin/ to %s", path, strings.Join(app.options.AdminUsers, ", "))
+		siteMux.Handle(path+"/admin/sessions", app.wrapAdmin(app.handleAdminSessions))
+		siteMux.Handle(path+"/admin/write", app.wrapAdmin(app.handleAdminWrite))
+		siteMux.Handle(path+"/admin/mute", app.wrapAdmin(app.handleAdminMute))
+		siteMux.Handle(path+"/admin/signal", app.wrapAdmin(app.handleAdminSignal))
+		siteMux.Handle(path+"/admin/broadcast", app.wrapAdmin(app.handleAdminBroadcast))
+		siteMux.Handle(path+"/admin/kill", app.wrapAdmin(app.handleAdminKill))
+		if app.options.LogFile != "" {
+			siteMux.Handle(path+"/admin/log", app.wrapAdmin(app.handleAdminLog))
+		}
+	}
+
+	if app.options.StatusPath != "" {
+		log.Printf("Server status is available at %s%s", path, app.options.StatusPath)
+		siteMux.Handle(path+app.options.StatusPath, http.HandlerFunc(app.handleStatus))
+	}
+
+	if app.options.EnableMetrics && !app.options.MetricsSkipAuth {
+		log.Printf("Metrics are available at %s", app.options.MetricsPath)
+		siteMux.Handle(app.options.MetricsPath, http.HandlerFunc(app.handleMetrics))
+	}
 
 	siteHandler := http.Handler(siteMux)
 
 	if app.options.EnableBasicAuth {
 		log.Printf("Using Basic Authentication")
-		siteHandler = wrapBasicAuth(siteHandler, app.options.Credential)
+		var lockout *authLockout
+		if app.options.AuthMaxAttempts > 0 {
+			lockout = newAuthLockout(app.options.AuthMaxAttempts, time.Duration(app.options.AuthLockoutSeconds)*time.Second, app.clientIP)
+			lockout.goCleanup(app.quit)
+		}
+		siteHandler = wrapBasicAuth(siteHandler, credentialList(app.options), app.options.CredentialHashed, lockout)
+	}
+	if app.options.EnableBearerAuth {
+		log.Printf("Using Bearer Authentication")
+		var otherwise http.Handler
+		if app.options.EnableBasicAuth {
+			otherwise = siteHandler
+		}
+		siteHandler = wrapBearerAuth(siteMux, app.options.BearerTokens, otherwise)
 	}
 
 	siteHandler = wrapHeaders(siteHandler)
 
 	wsMux := http.NewServeMux()
-	wsMux.Handle("/", siteHandler)
+	if path == "" {
+		wsMux.Handle("/", siteHandler)
+	} else {
+		// Requests outside the random path are answered with 404 before asking for credentials,
+		// except for the health check and metrics which are meant to be found.
+		wsMux.Handle("/", http.NotFoundHandler())
+		wsMux.Handle(path, siteHandler)
+		wsMux.Handle(path+"/", siteHandler)
+		if app.options.EnableMetrics && !app.options.MetricsSkipAuth {
+			wsMux.Handle(app.options.MetricsPath, siteHandler)
+		}
+	}
+	if app.options.EnableMetrics && app.options.MetricsSkipAuth {
+		log.Printf("Metrics are available at %s without authentication", app.options.MetricsPath)
+		wsMux.Handle(app.options.MetricsPath, wrapHeaders(http.HandlerFunc(app.handleMetrics)))
+	}
+	if app.options.HealthCheckPath != "" {
+		wsMux.Handle(app.options.HealthCheckPath, wrapHeaders(http.HandlerFunc(app.handleHealthCheck)))
+	}
 	wsMux.Handle(path+"/ws", wsHandler)
+	for name, command := range app.options.Commands {
+		wsMux.Handle(path+"/"+name+"/ws", app.commandWSHandler(name, command))
+	}
 	siteHandler = (http.Handler(wsMux))
 
-	siteHandler = wrapLogger(siteHandler)
+	if len(app.allowIPs) > 0 || len(app.denyIPs) > 0 {
+		log.Printf("Filtering clients by IP, allowed: %v, denied: %v", app.options.AllowIPs, app.options.DenyIPs)
+		siteHandler = app.wrapIPFilter(siteHandler)
+	}
+	siteHandler = app.wrapLogger(siteHandler)
 
 	scheme := "http"
-	if app.options.EnableTLS {
+	if app.options.EnableTLS || app.options.EnableAutoCert {
 		scheme = "https"
 	}
+	if app.options.ForwardedProto != "" {
+		scheme = app.options.ForwardedProto
+	}
 	log.Printf(
 		"Server is starting with command: %s",
 		strings.Join(app.command, " "),
 	)
-	if app.options.Address != "" {
-		log.Printf(
-			"URL: %s",
-			(&url.URL{Scheme: scheme, Host: endpoint, Path: path + "/"}).String(),
-		)
+	for name, command := range app.options.Commands {
+		log.Printf("Command %q is available at %s/%s/: %s", name, path, name, strings.Join(command, " "))
+	}
+	urls := []*url.URL{}
+	if app.options.UnixSocket != "" {
+		log.Printf("URL: unix:%s (path %s/)", app.options.UnixSocket, path)
+	} else if address != "" {
+		urls = append(urls, &url.URL{Scheme: scheme, Host: endpoint, Path: path + "/"})
 	} else {
 		for _, address := range listAddresses() {
-			log.Printf(
-				"URL: %s",
-				(&url.URL{
-					Scheme: scheme,
-					Host:   net.JoinHostPort(address, app.options.Port),
-					Path:   path + "/",
-				}).String(),
-			)
+			urls = append(urls, &url.URL{
+				Scheme: scheme,
+				Host:   net.JoinHostPort(address, app.options.Port),
+				Path:   path + "/",
+			})
 		}
 	}
+	for _, u := range urls {
+		log.Printf("URL: %s", u.String())
+	}
+	if app.options.PrintQR {
+		printQRCodes(os.Stdout, urls)
+	}
 
 	server, err := app.makeServer(endpoint, &siteHandler)
 	if err != nil {
@@ -275,6 +1105,32 @@ func (app *App) Run() error {
 		server,
 	)
 
+	app.execJobs.goSweep(app.quit)
+	if app.webhook != nil {
+		log.Printf("Sending session events to %s", app.options.WebhookURL)
+		app.webhook.goDeliver(app.quit)
+	}
+	if app.eventSocket != nil {
+		log.Printf("Sending session lifecycle events to %s", app.eventSocket.path)
+		app.eventSocket.goDeliver(app.quit)
+	}
+	if app.options.EnableK8sEvents {
+		k8s, err := newK8sEvents()
+		if err != nil {
+			log.Printf("Kubernetes events are disabled: %v", err)
+		} else {
+			log.Printf("Creating Kubernetes events for pod %s/%s", k8s.namespace, k8s.pod)
+			app.k8sEvents = k8s
+			app.k8sEvents.goDeliver(app.quit)
+		}
+	}
+
+	if app.options.StateFile != "" {
+		app.reportOrphanedSessions()
+		app.saveState()
+		defer app.removeState()
+	}
+
 	if app.options.Timeout > 0 {
 		app.timer = time.NewTimer(time.Duration(app.options.Timeout) * time.Second)
 		go func() {
@@ -283,16 +1139,59 @@ func (app *App) Run() error {
 		}()
 	}
 
-	if app.options.EnableTLS {
+	var tlsConfig *tls.Config
+	if app.options.EnableAutoCert {
+		tlsConfig = app.autoCertTLSConfig()
+	} else if app.options.EnableTLS {
 		crtFile := ExpandHomeDir(app.options.TLSCrtFile)
 		keyFile := ExpandHomeDir(app.options.TLSKeyFile)
 		log.Printf("TLS crt file: " + crtFile)
 		log.Printf("TLS key file: " + keyFile)
 
-		err = app.server.ListenAndServeTLS(crtFile, keyFile)
+		if app.options.GenerateSelfSignedCert && missingCertFiles(crtFile, keyFile) {
+			if err := generateSelfSignedCert(crtFile, keyFile); err != nil {
+				return errors.New("Failed to generate self-signed certificate: " + err.Error())
+			}
+			log.Printf("Generated a self-signed certificate at %s with its key at %s", crtFile, keyFile)
+		}
+
+		tlsConfig, err = app.loadTLSConfig(crtFile, keyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var listener net.Listener
+	if app.options.UnixSocket != "" {
+		mode, _ := parseSocketMode(app.options.UnixSocketMode)
+		listener, err = listenUnix(ExpandHomeDir(app.options.UnixSocket), mode)
+		if err == nil {
+			// In case the listener is not closed, e.g. by a forced exit
+			defer os.Remove(ExpandHomeDir(app.options.UnixSocket))
+		}
 	} else {
-		err = app.server.ListenAndServe()
+		listener, err = app.listen("tcp", endpoint)
+	}
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil && app.options.TLSPlaintextFallback {
+		log.Printf("Accepting plaintext connections on the TLS port")
+		listener = newTLSFallbackListener(listener, tlsConfig)
+	} else if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
 	}
+
+	if app.options.AdminAddress != "" {
+		adminServer, err := app.serveAdmin()
+		if err != nil {
+			listener.Close()
+			return errors.New("Failed to start admin listener: " + err.Error())
+		}
+		defer adminServer.Close()
+	}
+
+	err = app.server.Serve(listener)
 	if err != nil {
 		return err
 	}
@@ -302,12 +1201,61 @@ func (app *App) Run() error {
 	return nil
 }
 
+// loadTLSConfig builds the configuration for the TLS listener
+// on top of the one prepared by makeServer.
+func (app *App) loadTLSConfig(crtFile string, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if app.server.TLSConfig != nil {
+		config = app.server.TLSConfig.Clone()
+	}
+	if config.NextProtos == nil {
+		config.NextProtos = []string{"http/1.1"}
+	}
+
+	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config.Certificates = []tls.Certificate{cert}
+	return config, nil
+}
+
+// listen opens the listener, retrying up to ListenRetries times while the address is still in use,
+// e.g. by a previous instance being restarted. Go sets SO_REUSEADDR on TCP listeners,
+// so sockets left in TIME_WAIT don't block us.
+func (app *App) listen(network string, address string) (net.Listener, error) {
+	delay := time.Duration(app.options.ListenRetryDelay) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		listener, err := net.Listen(network, address)
+		if err == nil || attempt > app.options.ListenRetries || !errors.Is(err, syscall.EADDRINUSE) {
+			return listener, err
+		}
+		log.Printf("Address %s is in use, retrying in %v (%d/%d)", address, delay, attempt, app.options.ListenRetries)
+		time.Sleep(delay)
+	}
+}
+
 func (app *App) makeServer(addr string, handler *http.Handler) (*http.Server, error) {
 	server := &http.Server{
 		Addr:    addr,
 		Handler: *handler,
 	}
 
+	minVersion, err := parseTLSVersion(app.options.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := parseCipherSuites(app.options.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	if minVersion != 0 || cipherSuites != nil {
+		server.TLSConfig = &tls.Config{
+			MinVersion:   minVersion,
+			CipherSuites: cipherSuites,
+		}
+	}
+
 	if app.options.EnableTLSClientAuth {
 		caFile := ExpandHomeDir(app.options.TLSCACrtFile)
 		log.Printf("CA file: " + caFile)
@@ -319,11 +1267,27 @@ func (app *App) makeServer(addr string, handler *http.Handler) (*http.Server, er
 		if !caCertPool.AppendCertsFromPEM(caCert) {
 			return nil, errors.New("Could not parse CA crt file data in " + caFile)
 		}
-		tlsConfig := &tls.Config{
-			ClientCAs:  caCertPool,
-			ClientAuth: tls.RequireAndVerifyClientCert,
+		if server.TLSConfig == nil {
+			server.TLSConfig = &tls.Config{}
+		}
+		server.TLSConfig.ClientCAs = caCertPool
+		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+
+		var verifiers []peerVerifier
+		if app.options.TLSCRLFile != "" {
+			checker, err := newCRLChecker(ExpandHomeDir(app.options.TLSCRLFile))
+			if err != nil {
+				return nil, err
+			}
+			checker.goReload(app.quit)
+			verifiers = append(verifiers, checker.verifyPeerCertificate)
+		}
+		if app.options.MinClientKeyBits > 0 {
+			verifiers = append(verifiers, minKeyBitsVerifier(app.options.MinClientKeyBits))
+		}
+		if len(verifiers) > 0 {
+			server.TLSConfig.VerifyPeerCertificate = verifyPeerWith(verifiers)
 		}
-		server.TLSConfig = tlsConfig
 	}
 
 	return server, nil
@@ -342,48 +1306,149 @@ func (app *App) restartTimer() {
 }
 
 func (app *App) handleWS(w http.ResponseWriter, r *http.Request) {
-	app.stopTimer()
+	app.serveWS(w, r, nil, 0)
+}
 
-	connections := atomic.AddInt64(app.connections, 1)
-	if int64(app.options.MaxConnection) != 0 {
-		if connections >= int64(app.options.MaxConnection) {
-			log.Printf("Reached max connection: %d", app.options.MaxConnection)
-			return
+func (app *App) commandWSHandler(name string, command []string) http.Handler {
+	closeSignal := app.commandCloseSignal(name)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.serveWS(w, r, command, closeSignal)
+	})
+}
+
+// How long clients have to send the init message after upgrading.
+const defaultHandshakeTimeout = 10 * time.Second
+
+// handshake upgrades the connection and reads the init message, with which the client authenticates.
+// At most MaxPendingUpgrades handshakes run at once, others are rejected with 503.
+// On failure, the connection is closed and the caller releases its slot.
+func (app *App) handshake(w http.ResponseWriter, r *http.Request) (*websocket.Conn, InitMessage, bool) {
+	var init InitMessage
+	if app.pendingUpgrades != nil {
+		select {
+		case app.pendingUpgrades <- struct{}{}:
+			defer func() { <-app.pendingUpgrades }()
+		default:
+			log.Printf("Too many pending upgrades, rejected %s", app.clientAddr(r))
+			http.Error(w, "Too many pending connections", http.StatusServiceUnavailable)
+			return nil, init, false
 		}
 	}
-	log.Printf("New client connected: %s", r.RemoteAddr)
 
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", 405)
-		return
+	if !app.acceptsSubprotocol(r) {
+		log.Printf("Rejected %s requesting unsupported subprotocols %q", app.clientAddr(r), websocket.Subprotocols(r))
+		http.Error(w, "Unsupported websocket subprotocol", http.StatusBadRequest)
+		return nil, init, false
 	}
-
 	conn, err := app.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Print("Failed to upgrade connection: " + err.Error())
-		return
+		return nil, init, false
 	}
 
+	switch protocol := connectionProtocol(conn); protocol {
+	case protocolGotty:
+		// The only wire format so far, future ones such as protocolGotty2 branch off here.
+	default:
+		log.Printf("Subprotocol %s is not implemented, closing connection of %s", protocol, app.clientAddr(r))
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, "Unsupported subprotocol"), time.Now().Add(time.Second))
+		conn.Close()
+		return nil, init, false
+	}
+
+	// Bound what an unauthenticated client can make us buffer.
+	// The session itself is not limited.
+	if app.options.MaxInitMessageSize > 0 {
+		conn.SetReadLimit(int64(app.options.MaxInitMessageSize))
+	}
+	// Nor hold a pending upgrade slot forever by never sending the init message.
+	conn.SetReadDeadline(time.Now().Add(app.handshakeTimeout))
 	_, stream, err := conn.ReadMessage()
 	if err != nil {
-		log.Print("Failed to authenticate websocket connection")
+		log.Printf("Failed to authenticate websocket connection: %v", err)
 		conn.Close()
-		return
+		return nil, init, false
 	}
-	var init InitMessage
+	conn.SetReadDeadline(time.Time{})
+	conn.SetReadLimit(0)
 
 	err = json.Unmarshal(stream, &init)
 	if err != nil {
 		log.Printf("Failed to parse init message %v", err)
 		conn.Close()
-		return
+		return nil, init, false
 	}
-	if init.AuthToken != app.options.Credential {
+	if !app.checkAuthToken(init.AuthToken) {
 		log.Print("Failed to authenticate websocket connection")
 		conn.Close()
+		return nil, init, false
+	}
+	if skew, ok := app.checkClockSkew(init.Timestamp); !ok {
+		log.Printf("Rejected websocket connection from %s with clock skew of %v", r.RemoteAddr, skew)
+		conn.Close()
+		return nil, init, false
+	}
+	return conn, init, true
+}
+
+// serveWS runs a session of the command. When command is nil,
+// the client can select one of Commands, see selectCommand.
+func (app *App) serveWS(w http.ResponseWriter, r *http.Request, command []string, closeSignal int) {
+	if app.rejectDuringCooldown(w) {
+		return
+	}
+
+	app.stopTimer()
+
+	// MaxConnection is the number of clients allowed at once, the slot taken here
+	// is released when the session ends or when it fails to start.
+	connections := atomic.AddInt64(app.connections, 1)
+	if int64(app.options.MaxConnection) != 0 {
+		if connections > int64(app.options.MaxConnection) {
+			app.releaseConnection()
+			log.Printf("Reached max connection: %d, rejected %s", app.options.MaxConnection, app.clientAddr(r))
+			app.rejectOverLimit(w)
+			return
+		}
+	}
+	app.logRecord(logFields{
+		"event":       "connect",
+		"remote_addr": r.RemoteAddr,
+		"client_ip":   ipString(app.clientIP(r)),
+		"connections": connections,
+	}, "New client connected: %s", app.clientAddr(r))
+	app.emitLifecycleEvent(SessionEvent{Event: "connect", Time: time.Now(), RemoteAddr: r.RemoteAddr})
+
+	if r.Method != "GET" {
+		app.releaseConnection()
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	conn, init, ok := app.handshake(w, r)
+	if !ok {
+		app.releaseConnection()
+		return
+	}
+	var authUser string
+	if app.options.EnableBasicAuth {
+		authUser = app.authenticatedUser(r)
+	}
+	app.emitLifecycleEvent(SessionEvent{Event: "auth", Time: time.Now(), RemoteAddr: r.RemoteAddr, User: authUser})
+	if !app.throttleReconnect(conn, r) {
 		return
 	}
-	argv := app.command[1:]
+	if command == nil {
+		name, selected, ok := app.selectCommand(r, &init)
+		if !ok {
+			log.Printf("Rejected unknown command %q requested by %s", name, r.RemoteAddr)
+			app.refuseSession(conn, "Unknown command: "+name)
+			return
+		}
+		command = selected
+		closeSignal = app.commandCloseSignal(name)
+	}
+	argv := command[1:]
 	if app.options.PermitArguments {
 		if init.Arguments == "" {
 			init.Arguments = "?"
@@ -391,15 +1456,71 @@ func (app *App) handleWS(w http.ResponseWriter, r *http.Request) {
 		query, err := url.Parse(init.Arguments)
 		if err != nil {
 			log.Print("Failed to parse arguments")
+			app.releaseConnection()
+			conn.Close()
+			return
+		}
+		params, err := app.transformArguments(query.Query()["arg"])
+		if err != nil {
+			log.Printf("Failed to transform arguments: %v", err)
+			app.releaseConnection()
 			conn.Close()
 			return
 		}
-		params := query.Query()["arg"]
 		if len(params) != 0 {
 			argv = append(argv, params...)
 		}
 	}
 
+	var sharedKey string
+	var sharedStarted *bool
+	if app.options.SharedSession {
+		sharedKey = sharedSessionKey(command[0], argv)
+		if app.joinSharedSession(conn, r, sharedKey) {
+			return
+		}
+		started := false
+		defer func() {
+			if !started {
+				app.leaveSharedSession(sharedKey)
+			}
+		}()
+		sharedStarted = &started
+	}
+
+	if message, err := app.validatePreSpawn(); err != nil {
+		log.Printf("Pre-spawn validation failed: %v", err)
+		app.refuseSession(conn, message)
+		return
+	}
+
+	if err := app.checkPtyReserve(); err != nil {
+		log.Printf("Not starting command for %s: %v", r.RemoteAddr, err)
+		app.refuseSession(conn, err.Error())
+		return
+	}
+
+	credential := app.sessionCredential(authUser)
+
+	if app.workingDir != "" {
+		if err := checkWorkingDir(filepath.Join(app.chroot, app.workingDir), credential); err != nil {
+			log.Printf("Working directory is not accessible: %v", err)
+			app.refuseSession(conn, "Working directory is not accessible")
+			return
+		}
+	}
+
+	var err error
+	var home string
+	if app.options.PerUserHome {
+		home, err = app.userHome(authUser, credential)
+		if err != nil {
+			log.Printf("Failed to prepare home directory: %v", err)
+			app.refuseSession(conn, "Failed to prepare home directory")
+			return
+		}
+	}
+
 	app.server.StartRoutine()
 
 	if app.options.Once {
@@ -408,37 +1529,139 @@ func (app *App) handleWS(w http.ResponseWriter, r *http.Request) {
 			app.server.Close()
 		} else {
 			log.Printf("Server is already closing.")
-			conn.Close()
+			app.server.FinishRoutine()
+			app.refuseSession(conn, "Server is closing")
+			return
+		}
+	}
+
+	size := app.initialWindowSize()
+
+	var rec *recorder
+	if app.options.RecordDir != "" && !app.acquireRecordingSlot() {
+		if app.options.RecordingLimitPolicy == "queue" {
+			log.Printf("Timed out waiting for a recording slot for %s", r.RemoteAddr)
+			app.server.FinishRoutine()
+			app.refuseSession(conn, "Too many sessions are being recorded")
+			return
+		}
+		log.Printf("Not recording session of %s, %d sessions are being recorded already", r.RemoteAddr, app.options.MaxConcurrentRecordings)
+	} else if app.options.RecordDir != "" {
+		rec, err = newRecorder(ExpandHomeDir(app.options.RecordDir), r.RemoteAddr, command, size)
+		if err != nil {
+			app.releaseRecordingSlot()
+			log.Printf("Failed to start recording: %v", err)
+			app.server.FinishRoutine()
+			app.refuseSession(conn, "Failed to start recording")
 			return
 		}
+		rec.release = app.releaseRecordingSlot
+		log.Printf("Recording session of %s to %s", r.RemoteAddr, rec.file.Name())
 	}
 
-	cmd := exec.Command(app.command[0], argv...)
+	cmd := exec.Command(command[0], argv...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{}
-	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: app.uid, Gid: app.gid}
-	ptyIo, err := pty.Start(cmd)
+	cmd.SysProcAttr.Credential = credential
+	cmd.Env = app.commandEnv(init.Env, home, r.RemoteAddr)
+	cmd.Dir = app.workingDir
+	if err := app.applyChroot(cmd, command[0]); err != nil {
+		if rec != nil {
+			rec.Close()
+		}
+		app.handleSpawnError(conn, err)
+		return
+	}
+	ptyIo, err := startPty(cmd, size, app.options.OutputOnly, app.umask, app.options.NoNewPrivs)
 	if err != nil {
-		log.Print("Failed to execute command")
+		if rec != nil {
+			rec.Close()
+		}
+		app.handleSpawnError(conn, err)
 		return
 	}
 
+	context := &clientContext{
+		app:         app,
+		id:          generateRandomString(16),
+		commandLine: command,
+		closeSignal: closeSignal,
+		request:     r,
+		user:        authUser,
+		connection:  conn,
+		command:     cmd,
+		pty:         ptyIo,
+		writeMutex:  &sync.Mutex{},
+		startTime:   time.Now(),
+		recorder:    rec,
+		done:        make(chan struct{}),
+
+		reauthResult: make(chan bool, 1),
+		muteMutex:    &sync.Mutex{},
+		resizeMutex:  &sync.Mutex{},
+		appliedSize:  *size,
+	}
+	if app.options.RecordFifo != "" {
+		fifo, err := newFifoOutput(ExpandHomeDir(app.options.RecordFifo), context.id)
+		if err != nil {
+			log.Printf("Failed to create output FIFO: %v", err)
+		} else {
+			log.Printf("Copying output of %s to %s", r.RemoteAddr, fifo.path)
+			context.fifo = fifo
+		}
+	}
+	if app.options.TranscriptDir != "" {
+		transcript, err := newTranscript(ExpandHomeDir(app.options.TranscriptDir), r.RemoteAddr)
+		if err != nil {
+			log.Printf("Failed to start transcript: %v", err)
+		} else {
+			log.Printf("Writing transcript of %s to %s", r.RemoteAddr, transcript.file.Name())
+			context.transcript = transcript
+		}
+	}
+	if app.labelTemplate != nil {
+		labelBuffer := new(bytes.Buffer)
+		if err := app.labelTemplate.Execute(labelBuffer, context.vars()); err != nil {
+			log.Printf("Failed to render session label: %v", err)
+		}
+		context.label = labelBuffer.String()
+	}
+	app.audit(AuditRecord{
+		Kind:       "session",
+		SessionID:  context.id,
+		Label:      context.label,
+		RemoteAddr: r.RemoteAddr,
+		User:       authUser,
+		Uid:        credential.Uid,
+		Gid:        credential.Gid,
+		Command:    cmd.Path,
+		Arguments:  cmd.Args[1:],
+		Pid:        cmd.Process.Pid,
+	})
+	context.goReadPty()
+	if app.options.PermitWrite {
+		context.permitWrite = 1
+	}
+
+	client := r.RemoteAddr
+	if context.label != "" {
+		client = fmt.Sprintf("%s [%s]", r.RemoteAddr, context.label)
+	}
 	if app.options.MaxConnection != 0 {
 		log.Printf("Command is running for client %s with PID %d (args=%q), connections: %d/%d",
-			r.RemoteAddr, cmd.Process.Pid, strings.Join(argv, " "), connections, app.options.MaxConnection)
+			client, cmd.Process.Pid, strings.Join(argv, " "), connections, app.options.MaxConnection)
 	} else {
 		log.Printf("Command is running for client %s with PID %d (args=%q), connections: %d",
-			r.RemoteAddr, cmd.Process.Pid, strings.Join(argv, " "), connections)
+			client, cmd.Process.Pid, strings.Join(argv, " "), connections)
 	}
 
-	context := &clientContext{
-		app:        app,
-		request:    r,
-		connection: conn,
-		command:    cmd,
-		pty:        ptyIo,
-		writeMutex: &sync.Mutex{},
+	app.addSession(context)
+	if sharedStarted != nil {
+		*sharedStarted = true
+		app.startSharedSession(sharedKey, context)
 	}
-
+	app.metrics.sessionStarted()
+	app.emitSessionEvent(context.event("connect"))
+	app.emitLifecycleEvent(context.event("start"))
 	context.goHandleClient()
 }
 
@@ -448,177 +1671,117 @@ func (app *App) handleCustomIndex(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) handleAuthToken(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/javascript")
-	w.Write([]byte("var gotty_auth_token = '" + app.options.Credential + "';"))
-}
-
-func (app *App) handleRemoteExec(w http.ResponseWriter, r *http.Request) {
-	// allow cross domain AJAX requests
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS, DELETE, POST")
-	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
-	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
-
-	if r.Method != http.MethodPost {
-		return
-	}
-
-	decoder := json.NewDecoder(r.Body)
-	var req ExecMessageReq
-	if err := decoder.Decode(&req); err != nil {
-		http.Error(w, "", http.StatusBadRequest)
-		return
-	}
-
-	const MaxOutputSize = 40960
-	var err error
-	var stdout io.ReadCloser
-	var stderr io.ReadCloser
-	var bufout bytes.Buffer
-	var buferr bytes.Buffer
-	var readStdout func()
-	var readStderr func()
-	rsp := ExecMessageRsp{
-		ExecMessageReq: &req,
-	}
-	exit := make(chan bool, 2)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	log.Printf("Exec %+v", req)
-
-	cmd := exec.CommandContext(ctx, req.Command, req.Arguments...)
-	cmd.SysProcAttr = &syscall.SysProcAttr{}
-	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: app.uid, Gid: app.gid}
-	if stdout, err = cmd.StdoutPipe(); err != nil {
-		rsp.Error = fmt.Sprintf("Can not connect to stdout for command %q: %v", req.Command, err)
-		goto Error
-	}
-	if stderr, err = cmd.StderrPipe(); err != nil {
-		rsp.Error = fmt.Sprintf("Can not connect to stderr for command %q: %v", req.Command, err)
-		goto Error
-	}
-	if err := cmd.Start(); err != nil {
-		rsp.Error = fmt.Sprintf("Can not start command %q: %v", req.Command, err)
-		goto Error
-	}
-	bufout.Grow(4096)
-	buferr.Grow(1024)
-
-	readStdout = func() {
-		for bufout.Len() < MaxOutputSize {
-			if _, err := io.CopyN(&bufout, stdout, 1024); err != nil {
-				if err != io.EOF {
-					bufout.WriteString(fmt.Sprintf("...<Error occurred while reading stdout for command %q: %v>", req.Command, err))
-				}
-				return
-			}
-		}
-		bufout.WriteString("...<More contents were truncated>")
-	}
-	readStderr = func() {
-		for buferr.Len() < MaxOutputSize {
-			if _, err := io.CopyN(&buferr, stderr, 1024); err != nil {
-				if err != io.EOF {
-					buferr.WriteString(fmt.Sprintf("...<Error occurred while reading stderr for command %q: %v>", req.Command, err))
-				}
-				return
-			}
-		}
-		buferr.WriteString("...<More contents were truncated>")
-	}
-	go func() {
-		defer func() { exit <- true }()
-		readStdout()
-	}()
-	go func() {
-		defer func() { exit <- true }()
-		readStderr()
-	}()
-
-	<-exit
-	cancel()
-	if err := cmd.Wait(); err != nil {
-		rsp.Error = fmt.Sprintf("Exit with error for command %q: %v", req.Command, err)
-	}
-	rsp.Output1 = bufout.String()
-	rsp.Output2 = buferr.String()
-
-Error:
-	encoder := json.NewEncoder(w)
-	if err := encoder.Encode(rsp); err != nil {
-		http.Error(w, "", http.StatusInternalServerError)
+	if token, ok := bearerToken(r); ok && app.options.EnableBearerAuth {
+		// Checked by wrapBearerAuth, give it back for the init message.
+		encoded, _ := json.Marshal(token)
+		w.Write([]byte("var gotty_auth_token = " + string(encoded) + ";"))
 		return
 	}
+	encoded, _ := json.Marshal(app.issueAuthToken(r))
+	w.Write([]byte("var gotty_auth_token = " + string(encoded) + ";"))
 }
 
 func (app *App) Exit() (firstCall bool) {
+	app.quitOnce.Do(func() { close(app.quit) })
+	if app.auditLog != nil {
+		// Exit may be followed by os.Exit.
+		app.auditLog.flush()
+	}
+
 	if app.server != nil {
 		firstCall = app.server.Close()
 		if firstCall {
 			log.Printf("Received Exit command, waiting for all clients to close sessions...")
+			if app.options.ShutdownTimeout > 0 {
+				app.goShutdownTimeout()
+			}
 		}
 		return firstCall
 	}
 	return true
 }
 
-func (app *App) lookupUidGid() (uid, gid uint32) {
+// lookupUidGid returns the ids of RunAsUser and of the groups it's a member of.
+// Ids of the default user which can't be resolved default to 0, i.e. root,
+// unless StrictRunAsUser is set. A user given explicitly must be resolved.
+func (app *App) lookupUidGid() (uid, gid uint32, groups []uint32, err error) {
 	uid = 0
 	gid = 0
+	strict := app.options.StrictRunAsUser || app.options.RunAsUser != DefaultOptions.RunAsUser
 	u, err := user.Lookup(app.options.RunAsUser)
 	if err != nil {
+		if strict {
+			return 0, 0, nil, fmt.Errorf("Failed to look up user %q: %v", app.options.RunAsUser, err)
+		}
 		log.Printf("lookupUidGid for user %q got (%d, %d): %v", app.options.RunAsUser, uid, gid, err)
-		return
+		log.Printf("WARNING: Commands will run as root, set strict_run_as_user to refuse starting instead")
+		return uid, gid, nil, nil
 	}
-	if decimal, err := strconv.ParseUint(u.Uid, 10, 32); err == nil {
+	decimal, uidErr := strconv.ParseUint(u.Uid, 10, 32)
+	if uidErr == nil {
 		uid = uint32(decimal)
 	}
-	if decimal, err := strconv.ParseUint(u.Gid, 10, 32); err == nil {
+	decimal, gidErr := strconv.ParseUint(u.Gid, 10, 32)
+	if gidErr == nil {
 		gid = uint32(decimal)
 	}
-	log.Printf("lookupUidGid for user %q got (%d, %d)", app.options.RunAsUser, uid, gid)
-	return
+	if uidErr != nil || gidErr != nil {
+		if strict {
+			return 0, 0, nil, fmt.Errorf("User %q has non-numeric ids: uid %q, gid %q", app.options.RunAsUser, u.Uid, u.Gid)
+		}
+		log.Printf("WARNING: Non-numeric ids of user %q (uid %q, gid %q) default to 0", app.options.RunAsUser, u.Uid, u.Gid)
+	}
+	groups, err = lookupGroups(u)
+	if err != nil {
+		if strict {
+			return 0, 0, nil, fmt.Errorf("Failed to look up groups of user %q: %v", app.options.RunAsUser, err)
+		}
+		log.Printf("WARNING: Failed to look up groups of user %q, commands run without supplementary groups: %v", app.options.RunAsUser, err)
+	}
+	log.Printf("lookupUidGid for user %q got (%d, %d), groups %v", app.options.RunAsUser, uid, gid, groups)
+	return uid, gid, groups, nil
 }
 
-func wrapLogger(handler http.Handler) http.Handler {
+func (app *App) wrapLogger(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rw := &responseWrapper{w, 200}
+		start := time.Now()
 		handler.ServeHTTP(rw, r)
-		log.Printf("%s %d %s %s", r.RemoteAddr, rw.status, r.Method, r.URL.Path)
+		duration := time.Since(start)
+		if rw.status != http.StatusSwitchingProtocols {
+			app.metrics.requestFinished(duration)
+		}
+		logger := log.Default()
+		if app.accessLog != nil {
+			logger = app.accessLog
+		}
+		app.logRecordTo(logger, logFields{
+			"event":       "request",
+			"remote_addr": r.RemoteAddr,
+			"client_ip":   ipString(app.clientIP(r)),
+			"status":      rw.status,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"duration":    duration.Seconds(),
+		}, "%s %d %s %s", app.clientAddr(r), rw.status, r.Method, r.URL.Path)
 	})
 }
 
-func wrapHeaders(handler http.Handler) http.Handler {
+// exactPath serves only the given path and returns 404 for anything else
+// falling into the same subtree.
+func exactPath(path string, handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Server", "GoTTY/"+Version)
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
 		handler.ServeHTTP(w, r)
 	})
 }
 
-func wrapBasicAuth(handler http.Handler, credential string) http.Handler {
+func wrapHeaders(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
-
-		if len(token) != 2 || strings.ToLower(token[0]) != "basic" {
-			w.Header().Set("WWW-Authenticate", `Basic realm="GoTTY"`)
-			http.Error(w, "Bad Request", http.StatusUnauthorized)
-			return
-		}
-
-		payload, err := base64.StdEncoding.DecodeString(token[1])
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-
-		if credential != string(payload) {
-			w.Header().Set("WWW-Authenticate", `Basic realm="GoTTY"`)
-			http.Error(w, "authorization failed", http.StatusUnauthorized)
-			return
-		}
-
-		log.Printf("Basic Authentication Succeeded: %s", r.RemoteAddr)
+		w.Header().Set("Server", "GoTTY/"+Version)
 		handler.ServeHTTP(w, r)
 	})
 }
@@ -654,10 +1817,18 @@ func listAddresses() (addresses []string) {
 	return
 }
 
+// ExpandHomeDir replaces a leading ~ of path with the home directory,
+// which is $HOME, or the one of the current user in the passwd database when it's unset.
 func ExpandHomeDir(path string) string {
-	if path[0:2] == "~/" {
-		return os.Getenv("HOME") + path[1:]
-	} else {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
 		return path
 	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		// $HOME is unset, e.g. under some service managers.
+		if u, err := user.Current(); err == nil {
+			home = u.HomeDir
+		}
+	}
+	return home + path[1:]
 }
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/braintree/manners"
+	"github.com/gorilla/websocket"
+)
+
+// testOptions returns a copy of DefaultOptions which tests can modify.
+func testOptions() *Options {
+	options := DefaultOptions
+	return &options
+}
+
+// newTestApp returns an App running cat, ready to serve websocket requests without Run().
+func newTestApp(t *testing.T, options *Options) *App {
+	return newTestCommandApp(t, []string{"cat"}, options)
+}
+
+// newTestCommandApp is newTestApp running the command.
+func newTestCommandApp(t *testing.T, command []string, options *Options) *App {
+	app, err := New(command, options)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	app.server = manners.NewWithServer(&http.Server{})
+	// Sessions still logging would write to the logs captured by the next tests.
+	t.Cleanup(func() {
+		waitSessions(t, app, 0)
+	})
+	return app
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+}
+
+// startTestServer serves websocket sessions of the app at /ws.
+func startTestServer(app *App) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(app.handleWS))
+}
+
+// dialTestSession connects to the server and sends the init message.
+func dialTestSession(t *testing.T, server *httptest.Server, init InitMessage) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	message, _ := json.Marshal(init)
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		t.Fatalf("Failed to send init message: %v", err)
+	}
+	return conn
+}
+
+// dialAuthenticatedSession connects with the Basic Authentication credentials and sends the auth token.
+func dialAuthenticatedSession(t *testing.T, server *httptest.Server, user, password, token string) *websocket.Conn {
+	header := http.Header{}
+	(&http.Request{Header: header}).SetBasicAuth(user, password)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(server), header)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	message, _ := json.Marshal(InitMessage{AuthToken: token})
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		t.Fatalf("Failed to send init message: %v", err)
+	}
+	return conn
+}
+
+// readMessage returns the payload of the next message of the type, skipping others.
+func readMessage(t *testing.T, conn *websocket.Conn, messageType byte) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read message %q: %v", messageType, err)
+		}
+		if len(data) > 0 && data[0] == messageType {
+			return string(data[1:])
+		}
+	}
+}
+
+// readOutput reads output messages until the decoded output contains expected.
+func readOutput(t *testing.T, conn *websocket.Conn, expected string) string {
+	output := ""
+	for !strings.Contains(output, expected) {
+		decoded, err := base64.StdEncoding.DecodeString(readMessage(t, conn, Output))
+		if err != nil {
+			t.Fatal(err)
+		}
+		output += string(decoded)
+	}
+	return output
+}
+
+// waitClosed returns the close error of the connection once the server closed it.
+func waitClosed(t *testing.T, conn *websocket.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				t.Fatal("connection was not closed")
+			}
+			return err
+		}
+	}
+}
+
+// closeCode returns the code of the close message the connection failed with, -1 without one.
+func closeCode(err error) int {
+	if closeErr, ok := err.(*websocket.CloseError); ok {
+		return closeErr.Code
+	}
+	return -1
+}
+
+// syncBuffer is a bytes.Buffer which can be logged to while being read.
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+// captureLog returns what is logged while f runs.
+func captureLog(f func()) string {
+	buffer := &syncBuffer{}
+	log.SetOutput(buffer)
+	defer log.SetOutput(os.Stderr)
+	f()
+	return buffer.String()
+}
+
+// waitSessions waits for the count of sessions to be running and returns them.
+func waitSessions(t *testing.T, app *App, count int) []SessionInfo {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sessions := app.Sessions()
+		if len(sessions) == count {
+			return sessions
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d sessions, got %d", count, len(sessions))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// postForm sends the form to the handler and returns the response.
+func postForm(handler http.HandlerFunc, values url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// waitConnections waits for the count of connections, which are released after the client sees them closed.
+func waitConnections(t *testing.T, app *App, count int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(app.connections) != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d connections, got %d", count, atomic.LoadInt64(app.connections))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExpandHomeDir(t *testing.T) {
+	t.Setenv("HOME", "/home/alice")
+	for path, expected := range map[string]string{
+		"":     "",
+		"~":    "/home/alice",
+		"~/x":  "/home/alice/x",
+		"/abs": "/abs",
+		"x":    "x",
+		"~bob": "~bob",
+	} {
+		if expanded := ExpandHomeDir(path); expanded != expected {
+			t.Errorf("%q: expected %q, got %q", path, expected, expanded)
+		}
+	}
+
+	// Restored by t.Setenv afterwards.
+	os.Unsetenv("HOME")
+	if expanded := ExpandHomeDir("~/x"); expanded == "/x" || !strings.HasSuffix(expanded, "/x") {
+		t.Errorf("~/x expanded to %q without HOME", expanded)
+	}
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"bytes"
+)
+
+// ArgumentVars is the data given to the argument transform template for each client argument.
+type ArgumentVars struct {
+	Arg   string
+	Index int
+}
+
+// transformArguments maps the arguments given by a client through the argument transform template.
+// The arguments are returned untouched when no template is configured.
+func (app *App) transformArguments(args []string) ([]string, error) {
+	if app.argsTemplate == nil {
+		return args, nil
+	}
+
+	transformed := make([]string, 0, len(args))
+	for i, arg := range args {
+		buf := new(bytes.Buffer)
+		if err := app.argsTemplate.Execute(buf, &ArgumentVars{Arg: arg, Index: i}); err != nil {
+			return nil, err
+		}
+		transformed = append(transformed, buf.String())
+	}
+	return transformed, nil
+}
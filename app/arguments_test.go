@@ -0,0 +1,47 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransformArguments(t *testing.T) {
+	options := testOptions()
+	app := newTestApp(t, options)
+	if args, _ := app.transformArguments([]string{"a", "b"}); !reflect.DeepEqual(args, []string{"a", "b"}) {
+		t.Errorf("arguments changed without a template: %q", args)
+	}
+
+	options.ArgumentTransform = "--opt{{.Index}}={{.Arg}}"
+	app = newTestApp(t, options)
+	args, err := app.transformArguments([]string{"a", "b c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"--opt0=a", "--opt1=b c"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestArgumentTransformValidation(t *testing.T) {
+	for _, format := range []string{"{{.Arg", "{{.Missing}}"} {
+		options := testOptions()
+		options.ArgumentTransform = format
+		if _, err := New([]string{"cat"}, options); err == nil {
+			t.Errorf("invalid template %q was accepted", format)
+		}
+	}
+}
+
+func TestArgumentTransformSession(t *testing.T) {
+	options := testOptions()
+	options.PermitArguments = true
+	options.ArgumentTransform = "transformed-{{.Arg}}"
+	app := newTestCommandApp(t, []string{"echo"}, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{Arguments: "?arg=x&arg=y"})
+	defer conn.Close()
+	readOutput(t, conn, "transformed-x transformed-y")
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a command started by gotty, for security review.
+type AuditRecord struct {
+	Kind       string // "session" or "exec"
+	Time       time.Time
+	SessionID  string `json:",omitempty"`
+	Label      string `json:",omitempty"` // see SessionLabelFormat
+	RemoteAddr string
+	User       string `json:",omitempty"`
+	Uid        uint32
+	Gid        uint32
+	Command    string
+	Arguments  []string
+	Pid        int    `json:",omitempty"`
+	Denied     string `json:",omitempty"` // why an exec request was refused
+}
+
+// Number of records waiting to be written when they are batched
+const auditQueueSize = 1024
+
+// auditLog appends records to AuditLogFile as JSON lines.
+// Each record is synced to the disk before returning, so that it survives a crash,
+// unless AuditLogFlushInterval is set. Records are then queued and written in batches
+// by a background writer, which syncs them at that interval, on flush and on Close.
+type auditLog struct {
+	mutex *sync.Mutex
+	file  *os.File
+
+	records chan []byte
+	flushes chan chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+func openAuditLog(path string, flushInterval time.Duration) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	audit := &auditLog{
+		mutex: &sync.Mutex{},
+		file:  file,
+	}
+	if flushInterval > 0 {
+		audit.records = make(chan []byte, auditQueueSize)
+		audit.flushes = make(chan chan struct{})
+		audit.done = make(chan struct{})
+		go audit.writeBatches(flushInterval)
+	}
+	return audit, nil
+}
+
+func (audit *auditLog) write(record AuditRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Failed to encode audit record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+	if audit.closed {
+		log.Printf("Dropped audit record written after closing the audit log: %s", line)
+		return
+	}
+	if audit.records != nil {
+		// Blocks when the writer falls behind rather than losing records.
+		audit.records <- line
+		return
+	}
+	if _, err := audit.file.Write(line); err != nil {
+		log.Printf("Failed to write audit record: %v", err)
+		return
+	}
+	if err := audit.file.Sync(); err != nil {
+		log.Printf("Failed to sync audit log: %v", err)
+	}
+}
+
+// writeBatches writes the queued records until the queue is closed.
+func (audit *auditLog) writeBatches(flushInterval time.Duration) {
+	defer close(audit.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	writer := bufio.NewWriter(audit.file)
+	pending := false
+	syncPending := func() {
+		if !pending {
+			return
+		}
+		if err := writer.Flush(); err != nil {
+			log.Printf("Failed to write audit records: %v", err)
+		}
+		if err := audit.file.Sync(); err != nil {
+			log.Printf("Failed to sync audit log: %v", err)
+		}
+		pending = false
+	}
+	defer syncPending()
+
+	for {
+		select {
+		case line, ok := <-audit.records:
+			if !ok {
+				return
+			}
+			writer.Write(line)
+			pending = true
+		case <-ticker.C:
+			syncPending()
+		case flushed := <-audit.flushes:
+			// Take what was queued before the flush was requested.
+			for len(audit.records) > 0 {
+				writer.Write(<-audit.records)
+				pending = true
+			}
+			syncPending()
+			close(flushed)
+		}
+	}
+}
+
+// flush waits until the queued records are on the disk.
+func (audit *auditLog) flush() {
+	if audit.records == nil {
+		return
+	}
+	flushed := make(chan struct{})
+	select {
+	case audit.flushes <- flushed:
+		<-flushed
+	case <-audit.done:
+	}
+}
+
+func (audit *auditLog) Close() error {
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+	if audit.closed {
+		return nil
+	}
+	audit.closed = true
+	if audit.records != nil {
+		close(audit.records)
+		<-audit.done
+	}
+	return audit.file.Close()
+}
+
+// audit writes the record to AuditLogFile, if any.
+func (app *App) audit(record AuditRecord) {
+	if app.auditLog == nil {
+		return
+	}
+	record.Time = time.Now()
+	app.auditLog.write(record)
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func readAuditRecords(t *testing.T, path string) []AuditRecord {
+	records := []AuditRecord{}
+	for _, line := range strings.Split(strings.TrimSpace(readFile(t, path)), "\n") {
+		if line == "" {
+			continue
+		}
+		var record AuditRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid audit record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func newAuditedApp(t *testing.T, options *Options) (*App, string) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	app := newTestApp(t, options)
+	auditLog, err := openAuditLog(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+	app.auditLog = auditLog
+	return app, path
+}
+
+func TestAuditExec(t *testing.T) {
+	options := testOptions()
+	options.ExecWhitelist = []string{"echo"}
+	app, path := newAuditedApp(t, options)
+
+	postExecRequest(t, app, ExecMessageReq{Command: "echo", Arguments: []string{"hello"}})
+	postExecRequest(t, app, ExecMessageReq{Command: "rm", Arguments: []string{"-rf", "/"}})
+
+	records := readAuditRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %+v", records)
+	}
+	if records[0].Kind != "exec" || records[0].Command != "echo" || records[0].Arguments[0] != "hello" || records[0].Denied != "" {
+		t.Errorf("unexpected record of the allowed request %+v", records[0])
+	}
+	if records[1].Command != "rm" || records[1].Denied == "" {
+		t.Errorf("unexpected record of the denied request %+v", records[1])
+	}
+}
+
+func TestAuditSession(t *testing.T) {
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	options.SessionLabelFormat = "{{ .User }}@{{ .Pid }}"
+	app, path := newAuditedApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+	token := fetchAuthToken(t, app, "alice", "secret")
+
+	// Websocket requests aren't behind basic authentication, only verified users are recorded.
+	for i, password := range []string{"secret", "guessed"} {
+		header := http.Header{}
+		(&http.Request{Header: header}).SetBasicAuth("alice", password)
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL(server), header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		message, _ := json.Marshal(InitMessage{AuthToken: token})
+		conn.WriteMessage(websocket.TextMessage, message)
+		waitSessions(t, app, i+1)
+	}
+
+	records := readAuditRecords(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %+v", records)
+	}
+	for i, user := range []string{"alice", ""} {
+		if record := records[i]; record.Kind != "session" || record.SessionID == "" || record.Pid == 0 || record.User != user {
+			t.Errorf("unexpected record %+v, expected user %q", record, user)
+		}
+		if record := records[i]; record.Label != fmt.Sprintf("%s@%d", user, record.Pid) {
+			t.Errorf("record of session labeled %q", record.Label)
+		}
+	}
+}
+
+func TestAuditLogBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := openAuditLog(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, testOptions())
+	app.auditLog = auditLog
+	// Not serving, Close would wait for it.
+	app.server = nil
+
+	auditLog.write(AuditRecord{Kind: "exec", Command: "first"})
+	if records := readAuditRecords(t, path); len(records) != 0 {
+		t.Fatalf("expected the record to be queued, got %+v", records)
+	}
+	// Exit flushes the queue, as the process may exit right after.
+	app.Exit()
+	if records := readAuditRecords(t, path); len(records) != 1 || records[0].Command != "first" {
+		t.Fatalf("expected the record after exiting, got %+v", records)
+	}
+
+	auditLog.write(AuditRecord{Kind: "exec", Command: "second"})
+	auditLog.Close()
+	records := readAuditRecords(t, path)
+	if len(records) != 2 || records[1].Command != "second" {
+		t.Fatalf("expected the record after closing, got %+v", records)
+	}
+
+	logged := captureLog(func() { auditLog.write(AuditRecord{Kind: "exec", Command: "third"}) })
+	if !strings.Contains(logged, "Dropped audit record") || !strings.Contains(logged, "third") {
+		t.Errorf("expected the late record to be logged, got %q", logged)
+	}
+	auditLog.flush()
+}
+
+func TestAuditLogFlushInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := openAuditLog(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+
+	for _, command := range []string{"first", "second"} {
+		auditLog.write(AuditRecord{Kind: "exec", Command: command})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(readAuditRecords(t, path)) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("records were not written at the flush interval: %+v", readAuditRecords(t, path))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// wrapBasicAuth requires one of the credentials (user:pass) with Basic Authentication.
+// When hashed is set, the password part of the credentials is a bcrypt hash.
+// Clients failing too often are answered with 429 while lockout, if given, locks them out.
+func wrapBasicAuth(handler http.Handler, credentials []string, hashed bool, lockout *authLockout) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lockout != nil {
+			if wait, locked := lockout.locked(r, time.Now()); locked {
+				w.Header().Set("Retry-After", retryAfter(wait))
+				http.Error(w, "Too many failed authentication attempts", http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		token := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+
+		if len(token) != 2 || strings.ToLower(token[0]) != "basic" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="GoTTY"`)
+			http.Error(w, "Bad Request", http.StatusUnauthorized)
+			return
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(token[1])
+		if err != nil {
+			if lockout != nil && lockout.fail(r, time.Now()) {
+				log.Printf("Locked out %s after %d failed authentication attempts", r.RemoteAddr, lockout.maxAttempts)
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="GoTTY"`)
+			http.Error(w, "Bad Request", http.StatusUnauthorized)
+			return
+		}
+
+		matched := false
+		for _, credential := range credentials {
+			if matchCredential(credential, hashed, string(payload)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			if lockout != nil && lockout.fail(r, time.Now()) {
+				log.Printf("Locked out %s after %d failed authentication attempts", r.RemoteAddr, lockout.maxAttempts)
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="GoTTY"`)
+			http.Error(w, "authorization failed", http.StatusUnauthorized)
+			return
+		}
+		if lockout != nil {
+			lockout.succeed(r)
+		}
+
+		log.Printf("Basic Authentication Succeeded: %s (user: %s)", r.RemoteAddr, requestUser(r))
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// matchCredential checks the user:pass payload sent by a client against the credential.
+func matchCredential(credential string, hashed bool, payload string) bool {
+	user, password, ok := splitCredential(payload)
+	if !ok || user == "" {
+		return false
+	}
+
+	if !hashed {
+		return subtle.ConstantTimeCompare([]byte(credential), []byte(payload)) == 1
+	}
+
+	expectedUser, hash, ok := splitCredential(credential)
+	if !ok {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(expectedUser), []byte(user)) != 1 {
+		return false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if err != bcrypt.ErrMismatchedHashAndPassword {
+			log.Printf("Failed to verify hashed credential: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// authenticatedUser returns the user of the Basic Authentication credentials sent with the request.
+// Websocket requests are not behind wrapBasicAuth, so the credentials are verified here.
+// It is empty when they don't match any of the configured credentials.
+func (app *App) authenticatedUser(r *http.Request) string {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return ""
+	}
+	for _, credential := range credentialList(app.options) {
+		if matchCredential(credential, app.options.CredentialHashed, user+":"+password) {
+			return user
+		}
+	}
+	return ""
+}
+
+// credentialList merges Credential into Credentials.
+func credentialList(options *Options) []string {
+	credentials := make([]string, 0, len(options.Credentials)+1)
+	if options.Credential != "" {
+		credentials = append(credentials, options.Credential)
+	}
+	for _, credential := range options.Credentials {
+		if credential != "" && credential != options.Credential {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials
+}
+
+func splitCredential(credential string) (user string, password string, ok bool) {
+	parts := strings.SplitN(credential, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// checkAuthToken verifies the token sent by clients in the init message,
+// which is one issued by auth_token.js with Basic Authentication.
+// Bearer tokens are accepted as well, so that clients can reuse the one they use for HTTP.
+func (app *App) checkAuthToken(token string) bool {
+	if app.options.EnableBearerAuth && matchBearerToken(app.options.BearerTokens, token) {
+		return true
+	}
+	if !app.options.EnableBasicAuth {
+		return !app.options.EnableBearerAuth
+	}
+
+	credential, ok := app.authTokens.lookup(token, time.Now())
+	if !ok {
+		return false
+	}
+	// The credential may have been removed since the token was issued.
+	for _, configured := range credentialList(app.options) {
+		if subtle.ConstantTimeCompare([]byte(configured), []byte(credential)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// requestUser returns the basic authentication username of the request, if any.
+func requestUser(r *http.Request) string {
+	username, _, _ := r.BasicAuth()
+	return username
+}
+
+// checkClockSkew rejects init messages whose timestamp is further than MaxClockSkew
+// from the server time, so that captured handshakes can't be replayed later.
+// Clients which don't send a timestamp are accepted.
+func (app *App) checkClockSkew(timestamp int64) (time.Duration, bool) {
+	if app.options.MaxClockSkew <= 0 || timestamp == 0 {
+		return 0, true
+	}
+	skew := time.Since(time.Unix(0, timestamp*int64(time.Millisecond)))
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew, skew <= time.Duration(app.options.MaxClockSkew)*time.Second
+}
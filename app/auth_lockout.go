@@ -0,0 +1,111 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Interval at which expired entries are removed from authLockout
+const authLockoutCleanupInterval = time.Minute
+
+// authLockout locks out client IPs after AuthMaxAttempts failed authentications
+// within AuthLockoutSeconds, for AuthLockoutSeconds.
+type authLockout struct {
+	maxAttempts int
+	duration    time.Duration
+	clientIP    func(*http.Request) net.IP
+
+	mutex   *sync.Mutex
+	clients map[string]*authFailures
+}
+
+type authFailures struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+func newAuthLockout(maxAttempts int, duration time.Duration, clientIP func(*http.Request) net.IP) *authLockout {
+	return &authLockout{
+		maxAttempts: maxAttempts,
+		duration:    duration,
+		clientIP:    clientIP,
+		mutex:       &sync.Mutex{},
+		clients:     make(map[string]*authFailures),
+	}
+}
+
+func (lockout *authLockout) key(r *http.Request) string {
+	if ip := lockout.clientIP(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// locked returns how long the client of the request remains locked out, if it is.
+func (lockout *authLockout) locked(r *http.Request, now time.Time) (time.Duration, bool) {
+	lockout.mutex.Lock()
+	defer lockout.mutex.Unlock()
+
+	failures, ok := lockout.clients[lockout.key(r)]
+	if !ok || !now.Before(failures.lockedUntil) {
+		return 0, false
+	}
+	return failures.lockedUntil.Sub(now), true
+}
+
+// fail counts a failed attempt and reports whether the client got locked out.
+func (lockout *authLockout) fail(r *http.Request, now time.Time) bool {
+	lockout.mutex.Lock()
+	defer lockout.mutex.Unlock()
+
+	key := lockout.key(r)
+	failures, ok := lockout.clients[key]
+	if !ok || now.Sub(failures.first) > lockout.duration {
+		failures = &authFailures{first: now}
+		lockout.clients[key] = failures
+	}
+	failures.count++
+	if failures.count >= lockout.maxAttempts {
+		failures.lockedUntil = now.Add(lockout.duration)
+		return true
+	}
+	return false
+}
+
+// succeed forgets the failures of the client.
+func (lockout *authLockout) succeed(r *http.Request) {
+	lockout.mutex.Lock()
+	defer lockout.mutex.Unlock()
+	delete(lockout.clients, lockout.key(r))
+}
+
+// goCleanup removes the entries which expired until quit is closed.
+func (lockout *authLockout) goCleanup(quit <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(authLockoutCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				lockout.mutex.Lock()
+				for key, failures := range lockout.clients {
+					if now.Sub(failures.first) > lockout.duration && !now.Before(failures.lockedUntil) {
+						delete(lockout.clients, key)
+					}
+				}
+				lockout.mutex.Unlock()
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int((wait + time.Second - 1) / time.Second))
+}
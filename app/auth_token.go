@@ -0,0 +1,89 @@
+package app
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// How long an auth token stays valid after its last use
+const authTokenLifetime = 24 * time.Hour
+
+// Maximum number of auth tokens kept, the least recently used ones are dropped beyond it
+const maxAuthTokens = 1024
+
+// authTokens are issued in auth_token.js to clients which passed Basic Authentication,
+// so that they can authenticate their websocket connections without sending a credential.
+// Each token remembers the credential it was issued for and is valid only while it's configured.
+type authTokens struct {
+	mutex  *sync.Mutex
+	tokens map[string]*authTokenEntry
+}
+
+type authTokenEntry struct {
+	credential string
+	expires    time.Time
+}
+
+func newAuthTokens() *authTokens {
+	return &authTokens{
+		mutex:  &sync.Mutex{},
+		tokens: make(map[string]*authTokenEntry),
+	}
+}
+
+func (tokens *authTokens) issue(credential string, now time.Time) string {
+	tokens.mutex.Lock()
+	defer tokens.mutex.Unlock()
+
+	for token, entry := range tokens.tokens {
+		if now.After(entry.expires) {
+			delete(tokens.tokens, token)
+		}
+	}
+	// Every fetch of auth_token.js issues a token, don't let them pile up.
+	for len(tokens.tokens) >= maxAuthTokens {
+		oldest := ""
+		for token, entry := range tokens.tokens {
+			if oldest == "" || entry.expires.Before(tokens.tokens[oldest].expires) {
+				oldest = token
+			}
+		}
+		delete(tokens.tokens, oldest)
+	}
+	token := generateRandomString(32)
+	tokens.tokens[token] = &authTokenEntry{credential: credential, expires: now.Add(authTokenLifetime)}
+	return token
+}
+
+// lookup returns the credential the token was issued for and extends its lifetime.
+func (tokens *authTokens) lookup(token string, now time.Time) (string, bool) {
+	tokens.mutex.Lock()
+	defer tokens.mutex.Unlock()
+
+	entry, ok := tokens.tokens[token]
+	if !ok {
+		return "", false
+	}
+	if now.After(entry.expires) {
+		delete(tokens.tokens, token)
+		return "", false
+	}
+	entry.expires = now.Add(authTokenLifetime)
+	return entry.credential, true
+}
+
+// issueAuthToken returns a new token for the credential the request passed Basic Authentication with,
+// or an empty one when the credential doesn't match, e.g. without Basic Authentication.
+func (app *App) issueAuthToken(r *http.Request) string {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return ""
+	}
+	for _, credential := range credentialList(app.options) {
+		if matchCredential(credential, app.options.CredentialHashed, user+":"+password) {
+			return app.authTokens.issue(credential, time.Now())
+		}
+	}
+	return ""
+}
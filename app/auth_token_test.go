@@ -0,0 +1,123 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var authTokenPattern = regexp.MustCompile(`^var gotty_auth_token = "([^"]*)";$`)
+
+func fetchAuthToken(t *testing.T, app *App, user, password string) string {
+	r := httptest.NewRequest("GET", "/auth_token.js", nil)
+	if user != "" {
+		r.SetBasicAuth(user, password)
+	}
+	w := httptest.NewRecorder()
+	app.handleAuthToken(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("auth_token.js answered %d", w.Code)
+	}
+	match := authTokenPattern.FindStringSubmatch(w.Body.String())
+	if match == nil {
+		t.Fatalf("unexpected auth_token.js: %q", w.Body.String())
+	}
+	return match[1]
+}
+
+func TestAuthTokenHashedCredential(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential := "alice:" + string(hash)
+
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = credential
+	options.CredentialHashed = true
+	app := newTestApp(t, options)
+
+	token := fetchAuthToken(t, app, "alice", "secret")
+	if token == "" {
+		t.Fatal("no token issued for a valid credential")
+	}
+	if strings.Contains(token, string(hash)) || strings.Contains(token, "secret") {
+		t.Fatalf("auth_token.js exposes the credential: %q", token)
+	}
+	if !app.checkAuthToken(token) {
+		t.Error("issued token is rejected")
+	}
+
+	for _, forged := range []string{credential, string(hash), "alice:secret", ""} {
+		if app.checkAuthToken(forged) {
+			t.Errorf("accepted %q as auth token", forged)
+		}
+	}
+
+	if token := fetchAuthToken(t, app, "alice", "wrong"); token != "" {
+		t.Errorf("issued token %q for a wrong password", token)
+	}
+}
+
+func TestAuthTokenPerLogin(t *testing.T) {
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = "alice:a"
+	options.Credentials = []string{"bob:b"}
+	app := newTestApp(t, options)
+
+	alice := fetchAuthToken(t, app, "alice", "a")
+	bob := fetchAuthToken(t, app, "bob", "b")
+	if alice == "" || bob == "" || alice == bob {
+		t.Fatalf("expected distinct tokens, got %q and %q", alice, bob)
+	}
+	if alice == options.Credential || bob == options.Credential {
+		t.Fatal("auth_token.js echoes the primary credential")
+	}
+	if !app.checkAuthToken(alice) || !app.checkAuthToken(bob) {
+		t.Error("issued tokens are rejected")
+	}
+	if app.checkAuthToken(options.Credential) {
+		t.Error("primary credential is accepted as auth token")
+	}
+
+	// Removing a credential revokes the tokens issued for it.
+	options.Credentials = nil
+	if app.checkAuthToken(bob) {
+		t.Error("token of a removed credential is accepted")
+	}
+	if !app.checkAuthToken(alice) {
+		t.Error("token of a kept credential is rejected")
+	}
+}
+
+func TestAuthTokensLimit(t *testing.T) {
+	tokens := newAuthTokens()
+	now := time.Now()
+	first := tokens.issue("alice:secret", now)
+	second := tokens.issue("alice:secret", now.Add(time.Second))
+	for i := 2; i < maxAuthTokens; i++ {
+		tokens.issue("alice:secret", now.Add(time.Minute))
+	}
+
+	// Using a token keeps it, the least recently used one makes room for the new token.
+	if _, ok := tokens.lookup(first, now.Add(time.Hour)); !ok {
+		t.Fatal("first token was not issued")
+	}
+	tokens.issue("alice:secret", now.Add(time.Hour))
+	if len(tokens.tokens) != maxAuthTokens {
+		t.Errorf("expected %d tokens, got %d", maxAuthTokens, len(tokens.tokens))
+	}
+	if _, ok := tokens.lookup(first, now.Add(time.Hour)); !ok {
+		t.Error("recently used token was dropped")
+	}
+	if _, ok := tokens.lookup(second, now.Add(time.Hour)); ok {
+		t.Error("least recently used token was kept")
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Address to serve ACME HTTP-01 challenges, which are always sent to port 80.
+const autoCertChallengeAddress = ":80"
+
+// autoCertTLSConfig returns a TLS configuration obtaining and renewing certificates
+// for AutoCertDomains from Let's Encrypt. It also starts the HTTP-01 challenge listener.
+func (app *App) autoCertTLSConfig() *tls.Config {
+	cacheDir := ExpandHomeDir(app.options.AutoCertCacheDir)
+	log.Printf("Using ACME certificates for %v, cached in %s", app.options.AutoCertDomains, cacheDir)
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(app.options.AutoCertDomains...),
+		Cache:      autocert.DirCache(cacheDir),
+	}
+
+	go func() {
+		log.Printf("Serving ACME challenges at %s", autoCertChallengeAddress)
+		if err := http.ListenAndServe(autoCertChallengeAddress, manager.HTTPHandler(nil)); err != nil {
+			log.Printf("ACME challenge listener failed: %v", err)
+		}
+	}()
+
+	return app.autoCertConfig(manager)
+}
+
+// autoCertConfig returns the TLS configuration of the server taking its certificates from manager.
+// Client authentication is kept as is, including the CRL and key strength checks.
+func (app *App) autoCertConfig(manager *autocert.Manager) *tls.Config {
+	config := &tls.Config{}
+	if app.server.TLSConfig != nil {
+		config = app.server.TLSConfig.Clone()
+	}
+	managed := manager.TLSConfig()
+	config.GetCertificate = managed.GetCertificate
+	config.NextProtos = managed.NextProtos
+	return config
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/braintree/manners"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Domain of the certificate served by serveAutoCert
+const autoCertTestDomain = "gotty.test"
+
+// serveAutoCert serves handler over TLS with the configuration used with EnableAutoCert.
+// The certificate is taken from the cache of the manager, so that no ACME server is involved.
+func serveAutoCert(t *testing.T, app *App, handler http.Handler) net.Listener {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: autoCertTestDomain},
+		DNSNames:     []string{autoCertTestDomain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// Far enough from the renewal window, which would contact the ACME server.
+		NotAfter:    time.Now().Add(90 * 24 * time.Hour),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached bytes.Buffer
+	pem.Encode(&cached, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	pem.Encode(&cached, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	cache := autocert.DirCache(t.TempDir())
+	if err := cache.Put(context.Background(), autoCertTestDomain, cached.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(autoCertTestDomain),
+		Cache:      cache,
+	}
+
+	server, err := app.makeServer("127.0.0.1:0", &handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.server = manners.NewWithServer(server)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(tls.NewListener(listener, app.autoCertConfig(manager)))
+	t.Cleanup(func() { server.Close() })
+	return listener
+}
+
+// getWithClientCert requests the root of the server at address with the client certificate.
+func getWithClientCert(address string, cert tls.Certificate) error {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			ServerName:         autoCertTestDomain,
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{cert},
+		}},
+	}
+	resp, err := client.Get("https://" + address + "/")
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func TestAutoCertClientAuth(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: big.NewInt(3), RevocationTime: time.Now()}},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	options := testOptions()
+	options.EnableAutoCert = true
+	options.AutoCertDomains = []string{autoCertTestDomain}
+	options.EnableTLSClientAuth = true
+	options.TLSCACrtFile = filepath.Join(dir, "ca.crt")
+	options.TLSCRLFile = filepath.Join(dir, "crl.pem")
+	ioutil.WriteFile(options.TLSCACrtFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+	ioutil.WriteFile(options.TLSCRLFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0600)
+
+	app := newTestApp(t, options)
+	listener := serveAutoCert(t, app, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	address := listener.Addr().String()
+
+	if err := getWithClientCert(address, issueTestClientKeyPair(t, ca, caKey, 2, clientKey)); err != nil {
+		t.Errorf("valid client certificate was rejected: %v", err)
+	}
+	var revokedErr error
+	captureLog(func() { revokedErr = getWithClientCert(address, issueTestClientKeyPair(t, ca, caKey, 3, clientKey)) })
+	if revokedErr == nil {
+		t.Error("revoked client certificate was accepted")
+	}
+	if err := getWithClientCert(address, tls.Certificate{}); err == nil {
+		t.Error("client without a certificate was accepted")
+	}
+}
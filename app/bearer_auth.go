@@ -0,0 +1,60 @@
+package app
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type authError struct {
+	Error string
+}
+
+// wrapBearerAuth requires one of the tokens in an "Authorization: Bearer" header.
+// Requests using another scheme are passed to otherwise when it's given,
+// which lets basic authentication be used as well.
+// Failures are answered with a JSON body rather than a browser prompt.
+func wrapBearerAuth(handler http.Handler, tokens []string, otherwise http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok && otherwise != nil {
+			otherwise.ServeHTTP(w, r)
+			return
+		}
+
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="GoTTY"`)
+			writeJSON(w, http.StatusUnauthorized, authError{Error: "Bearer token required"})
+			return
+		}
+		if !matchBearerToken(tokens, token) {
+			log.Printf("Bearer Authentication Failed: %s", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="GoTTY", error="invalid_token"`)
+			writeJSON(w, http.StatusUnauthorized, authError{Error: "Invalid bearer token"})
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", false
+	}
+	return strings.TrimSpace(parts[1]), true
+}
+
+// matchBearerToken compares the token with all the tokens in constant time.
+func matchBearerToken(tokens []string, token string) bool {
+	matched := 0
+	for _, candidate := range tokens {
+		if candidate != "" {
+			matched |= subtle.ConstantTimeCompare([]byte(token), []byte(candidate))
+		}
+	}
+	return matched == 1
+}
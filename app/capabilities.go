@@ -0,0 +1,42 @@
+package app
+
+// Optional features known to this build of gotty.
+// Features listed with false are not implemented by this build and are
+// always reported as unavailable, regardless of the options.
+var builtinFeatures = map[string]bool{
+	"reconnect":   true,
+	"write":       true,
+	"cwd":         true,
+	"reauth":      true,
+	"mute":        true,
+	"admin":       true,
+	"remote_exec": true,
+	"commands":    true,
+	"manifest":    true,
+	"recording":   true,
+	"upload":      false,
+	"multiplex":   false,
+}
+
+// capabilities reports which optional features are available to the client,
+// combining what this build supports with the runtime options.
+func (app *App) capabilities() map[string]bool {
+	enabled := map[string]bool{
+		"reconnect":   app.options.EnableReconnect,
+		"write":       app.options.PermitWrite && !app.options.OutputOnly,
+		"cwd":         app.options.TrackCwd,
+		"reauth":      app.options.ReauthInterval > 0,
+		"mute":        app.options.EnableAdmin,
+		"admin":       app.options.EnableAdmin,
+		"remote_exec": app.options.ExecAllowAny || len(app.options.ExecWhitelist) > 0,
+		"commands":    len(app.options.Commands) > 0,
+		"manifest":    true,
+		"recording":   app.options.RecordDir != "",
+	}
+
+	capabilities := make(map[string]bool, len(builtinFeatures))
+	for feature, built := range builtinFeatures {
+		capabilities[feature] = built && enabled[feature]
+	}
+	return capabilities
+}
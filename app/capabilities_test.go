@@ -0,0 +1,54 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.TrackCwd = true
+	app := newTestApp(t, options)
+
+	capabilities := app.capabilities()
+	if len(capabilities) != len(builtinFeatures) {
+		t.Errorf("expected %d features, got %v", len(builtinFeatures), capabilities)
+	}
+	for feature, expected := range map[string]bool{
+		"write":     true,
+		"cwd":       true,
+		"manifest":  true,
+		"reconnect": false,
+		"admin":     false,
+		"upload":    false,
+	} {
+		if capabilities[feature] != expected {
+			t.Errorf("%s: expected %t, got %t", feature, expected, capabilities[feature])
+		}
+	}
+
+	options.OutputOnly = true
+	if app.capabilities()["write"] {
+		t.Error("write is reported for output-only sessions")
+	}
+}
+
+func TestCapabilitiesMessage(t *testing.T) {
+	options := testOptions()
+	options.EnableReconnect = true
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+
+	var capabilities map[string]bool
+	if err := json.Unmarshal([]byte(readMessage(t, conn, SetCapabilities)), &capabilities); err != nil {
+		t.Fatal(err)
+	}
+	if !capabilities["reconnect"] || capabilities["write"] {
+		t.Errorf("unexpected capabilities %v", capabilities)
+	}
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// checkChroot verifies that root is a directory containing the executables of the commands,
+// which are looked up inside it when sessions start.
+func checkChroot(root string, commands [][]string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("Not a directory: " + root)
+	}
+	for _, command := range commands {
+		if len(command) == 0 {
+			continue
+		}
+		if _, err := lookPathInRoot(root, command[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookPathInRoot searches file like exec.LookPath, but in the directories of PATH inside root.
+// It returns the path of the executable as seen by a command chrooted to root.
+func lookPathInRoot(root, file string) (string, error) {
+	if strings.Contains(file, "/") {
+		path := filepath.Join("/", file)
+		if isExecutable(filepath.Join(root, path)) {
+			return path, nil
+		}
+		return "", errors.New("Executable not found in " + root + ": " + file)
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		path := filepath.Join(dir, file)
+		if isExecutable(filepath.Join(root, path)) {
+			return path, nil
+		}
+	}
+	return "", errors.New("Executable not found in PATH of " + root + ": " + file)
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// applyChroot makes cmd run inside Chroot, if it's given.
+// The executable is resolved inside Chroot, exec.Command looked it up outside.
+func (app *App) applyChroot(cmd *exec.Cmd, file string) error {
+	if app.chroot == "" {
+		return nil
+	}
+	path, err := lookPathInRoot(app.chroot, file)
+	if err != nil {
+		return err
+	}
+	cmd.Path = path
+	cmd.Err = nil
+	cmd.SysProcAttr.Chroot = app.chroot
+	if cmd.Dir == "" {
+		// The working directory would be left outside the chroot.
+		cmd.Dir = "/"
+	}
+	return nil
+}
+
+// chrootCommands returns the commands which may run inside Chroot.
+func chrootCommands(command []string, options *Options) [][]string {
+	commands := [][]string{command}
+	for _, selectable := range options.Commands {
+		commands = append(commands, selectable)
+	}
+	return commands
+}
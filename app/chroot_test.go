@@ -0,0 +1,84 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckChroot(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "bin"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "bin", "tool"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "bin", "data"), []byte{}, 0644)
+	t.Setenv("PATH", "/usr/bin:/bin")
+
+	if err := checkChroot(root, [][]string{{"tool", "-x"}, {"/bin/tool"}}); err != nil {
+		t.Errorf("valid chroot was rejected: %v", err)
+	}
+	if path, err := lookPathInRoot(root, "tool"); err != nil || path != "/bin/tool" {
+		t.Errorf("tool resolved to %q, %v", path, err)
+	}
+	for _, command := range []string{"missing", "data", "/usr/bin/tool"} {
+		if err := checkChroot(root, [][]string{{command}}); err == nil {
+			t.Errorf("%s was accepted", command)
+		}
+	}
+	if err := checkChroot(filepath.Join(root, "bin", "tool"), nil); err == nil {
+		t.Error("file was accepted as a chroot")
+	}
+}
+
+func TestChrootSession(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("chroot requires root")
+	}
+	root := t.TempDir()
+	buildStaticProbe(t, filepath.Join(root, "probe"))
+	ioutil.WriteFile(filepath.Join(root, "inside"), []byte{}, 0644)
+	outside := filepath.Join(t.TempDir(), "outside")
+	ioutil.WriteFile(outside, []byte{}, 0644)
+
+	options := testOptions()
+	options.Chroot = root
+	app := newTestCommandApp(t, []string{"/probe", "/inside", outside}, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	output := readOutput(t, conn, outside)
+	if output != "found /inside\r\nmissing "+outside+"\r\n" {
+		t.Errorf("command in chroot saw %q", output)
+	}
+}
+
+// buildStaticProbe builds a command reporting whether the files given as arguments exist,
+// which runs in an empty chroot.
+func buildStaticProbe(t *testing.T, binary string) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("building the probe requires go")
+	}
+	source := filepath.Join(t.TempDir(), "probe.go")
+	ioutil.WriteFile(source, []byte(`package main
+
+import "os"
+
+func main() {
+	for _, path := range os.Args[1:] {
+		if _, err := os.Stat(path); err == nil {
+			os.Stdout.WriteString("found " + path + "\n")
+		} else {
+			os.Stdout.WriteString("missing " + path + "\n")
+		}
+	}
+}
+`), 0644)
+	build := exec.Command("go", "build", "-o", binary, source)
+	build.Env = append(os.Environ(), "CGO_ENABLED=0", "GO111MODULE=off")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build the probe: %v\n%s", err, output)
+	}
+}
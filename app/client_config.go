@@ -0,0 +1,29 @@
+package app
+
+import (
+	"net/http"
+)
+
+// ClientConfig is served at config.json for custom clients to fetch their settings
+// without opening a session. It's made of client-facing options only,
+// so that no credential or server-side setting is exposed.
+type ClientConfig struct {
+	Preferences  map[string]interface{}
+	PermitWrite  bool
+	Reconnect    int // seconds, 0 when reconnection is disabled
+	Capabilities map[string]bool
+}
+
+func (app *App) handleClientConfig(w http.ResponseWriter, r *http.Request) {
+	config := ClientConfig{
+		Preferences:  app.htermPreferences(),
+		PermitWrite:  app.options.PermitWrite && !app.options.OutputOnly,
+		Capabilities: app.capabilities(),
+	}
+	if app.options.EnableReconnect {
+		config.Reconnect = app.options.ReconnectTime
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, config)
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientConfig(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.EnableReconnect = true
+	options.ReconnectTime = 5
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	options.Preferences.FontSize = 15
+	options.RawPreferences = map[string]interface{}{"font_size": 15}
+	app := newTestApp(t, options)
+
+	w := httptest.NewRecorder()
+	app.handleClientConfig(w, httptest.NewRequest("GET", "/config.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("config.json answered %d with Cache-Control %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("credential was exposed: %s", w.Body.String())
+	}
+
+	var config ClientConfig
+	if err := json.NewDecoder(w.Body).Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	// Remote exec denies all commands without a whitelist.
+	if !config.PermitWrite || config.Reconnect != 5 || !config.Capabilities["write"] || config.Capabilities["remote_exec"] {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if len(config.Preferences) != 1 || config.Preferences["font-size"] != float64(15) {
+		t.Errorf("only the given preferences are expected by their hterm names, got %v", config.Preferences)
+	}
+
+	options.OutputOnly = true
+	w = httptest.NewRecorder()
+	app.handleClientConfig(w, httptest.NewRequest("GET", "/config.json", nil))
+	if err := json.NewDecoder(w.Body).Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if config.PermitWrite {
+		t.Error("output-only sessions are reported as writable")
+	}
+}
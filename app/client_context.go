@@ -2,7 +2,6 @@ package app
 
 import (
 	"bytes"
-	"encoding/base64"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -12,33 +11,88 @@ import (
 	"sync"
 	"sync/atomic"
 	"syscall"
-	"unsafe"
+	"time"
 
-	"github.com/fatih/structs"
 	"github.com/gorilla/websocket"
 )
 
 type clientContext struct {
-	app        *App
-	request    *http.Request
-	connection *websocket.Conn
-	command    *exec.Cmd
-	pty        *os.File
-	writeMutex *sync.Mutex
+	app         *App
+	id          string
+	label       string
+	commandLine []string
+	closeSignal int
+	request     *http.Request
+	connection  *websocket.Conn
+	command     *exec.Cmd
+	pty         *os.File
+	writeMutex  *sync.Mutex
+	startTime   time.Time
+	recorder    *recorder
+	fifo        *fifoOutput
+	transcript  *transcript
+	output      chan []byte
+
+	// The session shared with spectators, of which this is the owner
+	shared *sharedSession
+
+	// The Basic Authentication user verified against the credentials, empty otherwise.
+	// Websocket requests aren't behind wrapBasicAuth, so their header alone can't be trusted.
+	user string
+
+	// Set by goReadPty before closing output when reading the PTY failed
+	// for another reason than the command closing it.
+	ptyErr error
+
+	// Closed when the session is finished.
+	done chan struct{}
+
+	// Toggled by the admin API while the session is running.
+	// Use atomic operations.
+	permitWrite int32
+
+	// Unix time in nanoseconds of the last input of the client, for IdleTimeout.
+	// Use atomic operations.
+	lastInput int64
+
+	// Set while waiting for the answer to a re-authentication challenge.
+	// Use atomic operations.
+	reauthWaiting int32
+	reauthResult  chan bool
+
+	// Output is held back from muted clients.
+	muteMutex  *sync.Mutex
+	muted      bool
+	muteBuffer []byte
+
+	// Resize requests are coalesced when they come faster than MaxResizesPerSecond,
+	// and dropped when they repeat the size last applied to the PTY.
+	resizeMutex   *sync.Mutex
+	lastResize    time.Time
+	pendingResize *windowSize
+	appliedSize   windowSize
+	resizeTimer   *time.Timer
 }
 
 const (
 	Input          = '0'
 	Ping           = '1'
 	ResizeTerminal = '2'
+	ReauthResponse = '3'
 )
 
 const (
-	Output         = '0'
-	Pong           = '1'
-	SetWindowTitle = '2'
-	SetPreferences = '3'
-	SetReconnect   = '4'
+	Output          = '0'
+	Pong            = '1'
+	SetWindowTitle  = '2'
+	SetPreferences  = '3'
+	SetReconnect    = '4'
+	SetWritePermit  = '5'
+	SetCwd          = '6'
+	ReauthChallenge = '7'
+	SetMuted        = '8'
+	SetCapabilities = '9'
+	SetManifest     = 'A'
 )
 
 type argResizeTerminal struct {
@@ -51,73 +105,149 @@ type ContextVars struct {
 	Pid        int
 	Hostname   string
 	RemoteAddr string
+	User       string
+	SessionID  string
 }
 
 func (context *clientContext) goHandleClient() {
 	exit := make(chan bool, 2)
 
+	if context.app.options.WSPingInterval > 0 {
+		context.goPing()
+	}
+
 	go func() {
-		defer func() { exit <- true }()
+		commandExited := false
+		defer func() { exit <- commandExited }()
 
-		context.processSend()
+		commandExited = context.processSend()
 	}()
 
 	go func() {
-		defer func() { exit <- true }()
+		defer func() { exit <- false }()
 
 		context.processReceive()
 	}()
 
+	if context.app.options.ReauthInterval > 0 {
+		context.goReauth()
+	}
+
+	if context.app.options.IdleTimeout > 0 {
+		context.goIdleTimeout()
+	}
+
+	if context.app.options.MaxSessionDuration > 0 {
+		context.goSessionDeadline()
+	}
+
 	go func() {
 		defer context.app.server.FinishRoutine()
+		defer context.app.removeSession(context)
+		defer context.app.endSharedSession(context)
 		defer func() {
-			connections := atomic.AddInt64(context.app.connections, -1)
-
+			connections := context.app.releaseConnection()
+
+			fields := logFields{
+				"event":       "disconnect",
+				"remote_addr": context.request.RemoteAddr,
+				"client_ip":   ipString(context.app.clientIP(context.request)),
+				"pid":         context.command.Process.Pid,
+				"session_id":  context.id,
+				"connections": connections,
+			}
 			if context.app.options.MaxConnection != 0 {
-				log.Printf("Connection closed: %s, connections: %d/%d",
-					context.request.RemoteAddr, connections, context.app.options.MaxConnection)
+				context.app.logRecord(fields, "Connection closed: %s, connections: %d/%d",
+					context.app.clientAddr(context.request), connections, context.app.options.MaxConnection)
 			} else {
-				log.Printf("Connection closed: %s, connections: %d",
-					context.request.RemoteAddr, connections)
-			}
-
-			if connections == 0 {
-				context.app.restartTimer()
+				context.app.logRecord(fields, "Connection closed: %s, connections: %d",
+					context.app.clientAddr(context.request), connections)
 			}
 		}()
 
-		<-exit
-		context.pty.Close()
+		commandExited := <-exit
+		close(context.done)
+		context.closePty()
 
 		// Even if the PTY has been closed,
 		// Read(0 in processSend() keeps blocking and the process doen't exit
 		//context.command.Process.Signal(syscall.Signal(context.app.options.CloseSignal))
 		// https://medium.com/@felixge/killing-a-child-process-and-all-of-its-children-in-go-54079af94773
-		syscall.Kill(-context.command.Process.Pid, syscall.Signal(context.app.options.CloseSignal))
+		syscall.Kill(-context.command.Process.Pid, syscall.Signal(context.closeSignal))
 
 		context.command.Wait()
+		if commandExited {
+			context.closeWithExitReason()
+		}
 		context.connection.Close()
+		// The other side stops now that both the PTY and the connection are closed.
+		// Waiting for it means that the session is over once removed.
+		<-exit
+		if context.recorder != nil {
+			context.recorder.Close()
+		}
+		if context.fifo != nil {
+			context.fifo.Close()
+		}
+		if context.transcript != nil {
+			context.transcript.Close()
+		}
+		context.app.metrics.sessionFinished(time.Since(context.startTime))
+		context.app.rememberDisconnect(context.request)
+		context.app.emitSessionEvent(context.event("disconnect"))
+		context.app.emitLifecycleEvent(context.event("exit"))
 	}()
 }
 
-func (context *clientContext) processSend() {
+// processSend delivers the output of the command to the client.
+// It returns true when the command side ended rather than the connection.
+func (context *clientContext) processSend() bool {
 	if err := context.sendInitialize(); err != nil {
-		log.Printf(err.Error())
-		return
+		log.Print(err)
+		return false
 	}
 
-	buf := make([]byte, 1024)
+	var tracker *cwdTracker
+	if context.app.options.TrackCwd {
+		tracker = &cwdTracker{}
+	}
 
 	for {
-		size, err := context.pty.Read(buf)
-		if err != nil {
-			log.Printf("Command exited for: %s", context.request.RemoteAddr)
-			return
+		data, ok := <-context.output
+		if !ok {
+			context.app.logRecord(logFields{
+				"event":       "exit",
+				"remote_addr": context.request.RemoteAddr,
+				"pid":         context.command.Process.Pid,
+				"session_id":  context.id,
+			}, "Command exited for: %s", context.request.RemoteAddr)
+			return true
 		}
-		safeMessage := base64.StdEncoding.EncodeToString([]byte(buf[:size]))
-		if err = context.write(append([]byte{Output}, []byte(safeMessage)...)); err != nil {
-			log.Printf(err.Error())
-			return
+		if context.recorder != nil {
+			context.recorder.record(data)
+		}
+		if context.fifo != nil {
+			context.fifo.write(data)
+		}
+		if context.transcript != nil {
+			context.transcript.write(data)
+		}
+		if err := context.sendOutput(data); err != nil {
+			log.Print(err)
+			return false
+		}
+		if context.app.options.SharedSession {
+			context.app.shareOutput(context, data)
+		}
+
+		if tracker != nil {
+			for _, cwd := range tracker.Feed(data) {
+				cwdMessage, _ := json.Marshal(cwd)
+				if err := context.write(append([]byte{SetCwd}, cwdMessage...)); err != nil {
+					log.Print(err)
+					return false
+				}
+			}
 		}
 	}
 }
@@ -128,47 +258,85 @@ func (context *clientContext) write(data []byte) error {
 	return context.connection.WriteMessage(websocket.TextMessage, data)
 }
 
-func (context *clientContext) sendInitialize() error {
+func (context *clientContext) vars() ContextVars {
 	hostname, _ := os.Hostname()
-	titleVars := ContextVars{
-		Command:    strings.Join(context.app.command, " "),
+	return ContextVars{
+		Command:    strings.Join(context.commandLine, " "),
 		Pid:        context.command.Process.Pid,
 		Hostname:   hostname,
 		RemoteAddr: context.request.RemoteAddr,
+		User:       context.user,
+		SessionID:  context.id,
 	}
+}
 
-	titleBuffer := new(bytes.Buffer)
-	if err := context.app.titleTemplate.Execute(titleBuffer, titleVars); err != nil {
-		return err
+func (context *clientContext) writable() bool {
+	if context.app.options.OutputOnly {
+		return false
 	}
-	if err := context.write(append([]byte{SetWindowTitle}, titleBuffer.Bytes()...)); err != nil {
-		return err
+	return atomic.LoadInt32(&context.permitWrite) == 1
+}
+
+func (context *clientContext) setWritable(permit bool) error {
+	value := int32(0)
+	if permit {
+		value = 1
 	}
+	atomic.StoreInt32(&context.permitWrite, value)
 
-	prefStruct := structs.New(context.app.options.Preferences)
-	prefMap := prefStruct.Map()
-	htermPrefs := make(map[string]interface{})
-	for key, value := range prefMap {
-		rawKey := prefStruct.Field(key).Tag("hcl")
-		if _, ok := context.app.options.RawPreferences[rawKey]; ok {
-			htermPrefs[strings.Replace(rawKey, "_", "-", -1)] = value
+	permitWrite, _ := json.Marshal(permit)
+	return context.write(append([]byte{SetWritePermit}, permitWrite...))
+}
+
+func (context *clientContext) sendInitialize() error {
+	messages, err := context.initialMessages(context.writable())
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if err := context.write(message); err != nil {
+			return err
 		}
 	}
-	prefs, err := json.Marshal(htermPrefs)
+	return nil
+}
+
+// initialMessages returns the messages setting up a client of the session,
+// which are also sent to spectators of a shared session.
+func (context *clientContext) initialMessages(writable bool) ([][]byte, error) {
+	messages := [][]byte{}
+
+	capabilities, _ := json.Marshal(context.app.capabilities())
+	messages = append(messages, append([]byte{SetCapabilities}, capabilities...))
+
+	htermPrefs := context.app.htermPreferences()
+
+	manifest, err := json.Marshal(context.manifest(htermPrefs))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	messages = append(messages, append([]byte{SetManifest}, manifest...))
 
-	if err := context.write(append([]byte{SetPreferences}, prefs...)); err != nil {
-		return err
+	titleBuffer := new(bytes.Buffer)
+	if err := context.app.titleTemplate.Execute(titleBuffer, context.vars()); err != nil {
+		return nil, err
+	}
+	messages = append(messages, append([]byte{SetWindowTitle}, titleBuffer.Bytes()...))
+
+	prefs, err := json.Marshal(htermPrefs)
+	if err != nil {
+		return nil, err
 	}
+	messages = append(messages, append([]byte{SetPreferences}, prefs...))
+
+	permitWrite, _ := json.Marshal(writable)
+	messages = append(messages, append([]byte{SetWritePermit}, permitWrite...))
+
 	if context.app.options.EnableReconnect {
 		reconnect, _ := json.Marshal(context.app.options.ReconnectTime)
-		if err := context.write(append([]byte{SetReconnect}, reconnect...)); err != nil {
-			return err
-		}
+		messages = append(messages, append([]byte{SetReconnect}, reconnect...))
 	}
-	return nil
+	return messages, nil
 }
 
 func (context *clientContext) processReceive() {
@@ -185,15 +353,24 @@ func (context *clientContext) processReceive() {
 
 		switch data[0] {
 		case Input:
-			if !context.app.options.PermitWrite {
+			context.touchInput()
+			if !context.writable() || context.reauthPending() {
+				break
+			}
+
+			input, ok := context.limitPaste(data[1:])
+			if !ok {
 				break
 			}
 
-			_, err := context.pty.Write(data[1:])
+			_, err := context.pty.Write(input)
 			if err != nil {
 				return
 			}
 
+		case ReauthResponse:
+			context.handleReauth(data[1:])
+
 		case Ping:
 			if err := context.write([]byte{Pong}); err != nil {
 				log.Print(err.Error())
@@ -217,23 +394,7 @@ func (context *clientContext) processReceive() {
 				columns = uint16(args.Columns)
 			}
 
-			window := struct {
-				row uint16
-				col uint16
-				x   uint16
-				y   uint16
-			}{
-				rows,
-				columns,
-				0,
-				0,
-			}
-			syscall.Syscall(
-				syscall.SYS_IOCTL,
-				context.pty.Fd(),
-				syscall.TIOCSWINSZ,
-				uintptr(unsafe.Pointer(&window)),
-			)
+			context.resize(rows, columns)
 
 		default:
 			log.Print("Unknown message type")
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		maxClockSkew int
+		timestamp    int64
+		ok           bool
+	}{
+		{0, millis(now.Add(-time.Hour)), true},
+		{30, 0, true},
+		{30, millis(now), true},
+		{30, millis(now.Add(-10 * time.Second)), true},
+		{30, millis(now.Add(10 * time.Second)), true},
+		{30, millis(now.Add(-time.Minute)), false},
+		{30, millis(now.Add(time.Minute)), false},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.MaxClockSkew = test.maxClockSkew
+		app := newTestApp(t, options)
+		if skew, ok := app.checkClockSkew(test.timestamp); ok != test.ok {
+			t.Errorf("max %ds, skew %v: expected %v", test.maxClockSkew, skew, test.ok)
+		}
+	}
+}
+
+func TestClockSkewRejectsSession(t *testing.T) {
+	options := testOptions()
+	options.MaxClockSkew = 30
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{Timestamp: millis(time.Now().Add(-time.Hour))})
+	defer conn.Close()
+	waitClosed(t, conn)
+	if sessions := app.Sessions(); len(sessions) != 0 {
+		t.Errorf("skewed client got a session: %+v", sessions)
+	}
+
+	conn = dialTestSession(t, server, InitMessage{Timestamp: millis(time.Now())})
+	defer conn.Close()
+	waitSessions(t, app, 1)
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCommandCloseSignal(t *testing.T) {
+	options := testOptions()
+	options.Commands = map[string][]string{"top": {"top"}, "vim": {"vim"}}
+	options.CommandCloseSignals = map[string]int{"vim": int(syscall.SIGTERM)}
+	app := newTestApp(t, options)
+
+	for name, expected := range map[string]int{"": options.CloseSignal, "top": options.CloseSignal, "vim": int(syscall.SIGTERM)} {
+		if signal := app.commandCloseSignal(name); signal != expected {
+			t.Errorf("%q: expected signal %d, got %d", name, expected, signal)
+		}
+	}
+}
+
+func TestCheckConfigCommandCloseSignals(t *testing.T) {
+	tests := []struct {
+		signals map[string]int
+		ok      bool
+	}{
+		{map[string]int{"vim": 15}, true},
+		{map[string]int{"emacs": 15}, false},
+		{map[string]int{"vim": 0}, false},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.Commands = map[string][]string{"vim": {"vim"}}
+		options.CommandCloseSignals = test.signals
+		if err := CheckConfig(options); (err == nil) != test.ok {
+			t.Errorf("%v: unexpected result %v", test.signals, err)
+		}
+	}
+}
+
+func TestCommandClosedWithItsSignal(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "signal")
+	// Closing the PTY hangs the command up before it is signaled, so SIGHUP is ignored.
+	script := "trap '' HUP; trap 'echo TERM > " + marker + "; exit' TERM; echo ready; while :; do sleep 0.1; done"
+	options := testOptions()
+	options.Commands = map[string][]string{"trap": {"sh", "-c", script}}
+	options.CommandCloseSignals = map[string]int{"trap": int(syscall.SIGTERM)}
+	app := newTestApp(t, options)
+	server := httptest.NewServer(app.commandWSHandler("trap", options.Commands["trap"]))
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	readOutput(t, conn, "ready")
+	conn.Close()
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if content, err := ioutil.ReadFile(marker); err == nil && strings.TrimSpace(string(content)) == "TERM" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("command was not closed with SIGTERM")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// commandEnv builds the environment of a session command.
+// It consists of gotty's own environment unless ClearEnv is set, the variables given by Env,
+// HOME for per user home directories, then the variables sent by the client which are listed in PermitEnv.
+// Later entries take precedence.
+func (app *App) commandEnv(clientEnv map[string]string, home string, remoteAddr string) []string {
+	env := []string{}
+	if !app.options.ClearEnv {
+		env = append(env, os.Environ()...)
+	}
+
+	env = append(env, sortedEnv(app.options.Env)...)
+
+	if home != "" {
+		env = append(env, "HOME="+home)
+	}
+
+	permitted := map[string]string{}
+	for name, value := range clientEnv {
+		if !app.envPermitted(name) || strings.ContainsRune(value, 0) {
+			log.Printf("Ignored environment variable %q sent by %s", name, remoteAddr)
+			continue
+		}
+		permitted[name] = value
+	}
+	return append(env, sortedEnv(permitted)...)
+}
+
+func (app *App) envPermitted(name string) bool {
+	for _, permitted := range app.options.PermitEnv {
+		if name == permitted {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedEnv(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(vars))
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+	return env
+}
+
+func validEnvName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "=\x00")
+}
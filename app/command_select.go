@@ -0,0 +1,30 @@
+package app
+
+import (
+	"net/http"
+)
+
+// selectCommand returns the command chosen by a client connecting to the default endpoint,
+// either by the Command field of the init message or by the command query parameter.
+// Clients choosing nothing get the command given on the command line.
+// It fails when the chosen name is not one of Commands, which also maps the URL paths
+// of commands, so that a name selects the same command either way.
+func (app *App) selectCommand(r *http.Request, init *InitMessage) (string, []string, bool) {
+	name := init.Command
+	if name == "" {
+		name = r.URL.Query().Get("command")
+	}
+	if name == "" {
+		return "", app.command, true
+	}
+	command, ok := app.options.Commands[name]
+	return name, command, ok
+}
+
+// commandCloseSignal returns the signal to close the named command with.
+func (app *App) commandCloseSignal(name string) int {
+	if signal, ok := app.options.CommandCloseSignals[name]; ok {
+		return signal
+	}
+	return app.options.CloseSignal
+}
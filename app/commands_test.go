@@ -0,0 +1,74 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCommandWSHandler(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.Commands = map[string][]string{"upper": {"tr", "a-z", "A-Z"}}
+	app := newTestApp(t, options)
+	server := httptest.NewServer(app.commandWSHandler("upper", options.Commands["upper"]))
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	conn.WriteMessage(websocket.TextMessage, []byte("0path\n"))
+	readOutput(t, conn, "PATH")
+}
+
+func TestCheckConfigCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		ok      bool
+	}{
+		{"top", []string{"top"}, true},
+		{"", []string{"top"}, false},
+		{"a/b", []string{"top"}, false},
+		{"ws", []string{"top"}, false},
+		{"auth_token.js", []string{"top"}, false},
+		{"config.json", []string{"top"}, false},
+		{"empty", []string{}, false},
+		// Configured paths, the health check is at /healthz by default.
+		{"healthz", []string{"top"}, false},
+		{"status", []string{"top"}, false},
+		{"metrics", []string{"top"}, false},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.StatusPath = "/status/gotty"
+		options.EnableMetrics = true
+		options.Commands = map[string][]string{test.name: test.command}
+		if err := CheckConfig(options); (err == nil) != test.ok {
+			t.Errorf("command %q: unexpected result %v", test.name, err)
+		}
+	}
+
+	// Paths which aren't served don't prevent naming commands after them.
+	options := testOptions()
+	options.HealthCheckPath = ""
+	options.Commands = map[string][]string{"healthz": {"top"}, "metrics": {"top"}}
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestExactPath(t *testing.T) {
+	handler := exactPath("/top/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("index"))
+	}))
+
+	for path, expected := range map[string]int{"/top/": http.StatusOK, "/top/other": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != expected {
+			t.Errorf("%s: expected %d, got %d", path, expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Interval at which the CRL file is checked for changes
+const crlReloadInterval = time.Minute
+
+// crlChecker rejects client certificates revoked by the CRL in TLSCRLFile.
+// The file is reloaded when it changes, e.g. when the CA publishes a new CRL.
+type crlChecker struct {
+	path string
+
+	mutex   *sync.RWMutex
+	list    *x509.RevocationList
+	revoked map[string]bool // serial numbers
+	modTime time.Time
+}
+
+func newCRLChecker(path string) (*crlChecker, error) {
+	checker := &crlChecker{
+		path:  path,
+		mutex: &sync.RWMutex{},
+	}
+	if err := checker.load(); err != nil {
+		return nil, err
+	}
+	return checker, nil
+}
+
+// load reads the CRL file, which can be PEM or DER encoded.
+func (checker *crlChecker) load() error {
+	info, err := os.Stat(checker.path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(checker.path)
+	if err != nil {
+		return err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return errors.New("Unexpected PEM block in CRL file " + checker.path + ": " + block.Type)
+		}
+		data = block.Bytes
+	}
+	list, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return errors.New("Could not parse CRL file " + checker.path + ": " + err.Error())
+	}
+
+	revoked := make(map[string]bool, len(list.RevokedCertificateEntries))
+	for _, entry := range list.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.String()] = true
+	}
+
+	checker.mutex.Lock()
+	checker.list = list
+	checker.revoked = revoked
+	checker.modTime = info.ModTime()
+	checker.mutex.Unlock()
+
+	log.Printf("Loaded CRL file %s with %d revoked certificates", checker.path, len(revoked))
+	if !list.NextUpdate.IsZero() && list.NextUpdate.Before(time.Now()) {
+		log.Printf("CRL file %s is outdated since %v", checker.path, list.NextUpdate)
+	}
+	return nil
+}
+
+// goReload reloads the CRL file when it changes until quit is closed.
+// The previous list is kept when the new one can't be loaded.
+func (checker *crlChecker) goReload(quit <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(crlReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+
+			info, err := os.Stat(checker.path)
+			if err != nil {
+				log.Printf("Failed to check CRL file %s: %v", checker.path, err)
+				continue
+			}
+			checker.mutex.RLock()
+			changed := !info.ModTime().Equal(checker.modTime)
+			checker.mutex.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := checker.load(); err != nil {
+				log.Printf("Failed to reload CRL file, keeping the previous one: %v", err)
+			}
+		}
+	}()
+}
+
+// verifyPeerCertificate is used as tls.Config.VerifyPeerCertificate,
+// which runs after the chain has been verified against the CA.
+func (checker *crlChecker) verifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	checker.mutex.RLock()
+	defer checker.mutex.RUnlock()
+
+	for _, chain := range verifiedChains {
+		if len(chain) == 0 {
+			continue
+		}
+		cert := chain[0]
+		if bytes.Equal(cert.RawIssuer, checker.list.RawIssuer) && checker.revoked[cert.SerialNumber.String()] {
+			log.Printf("Rejected revoked client certificate %q (serial %s)", cert.Subject.CommonName, cert.SerialNumber)
+			return errors.New("Client certificate has been revoked")
+		}
+	}
+	return nil
+}
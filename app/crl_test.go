@@ -0,0 +1,123 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCA returns a CA certificate and its key.
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+func newTestClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCRLChecker(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	revoked := newTestClientCert(t, ca, caKey, 2)
+	valid := newTestClientCert(t, ca, caKey, 3)
+	otherCA, otherKey := newTestCA(t, "Other CA")
+	otherIssuer := newTestClientCert(t, otherCA, otherKey, 2)
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: big.NewInt(2), RevocationTime: time.Now()}},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"crl.pem": pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}),
+		"crl.der": crl,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		checker, err := newCRLChecker(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if err := checker.verifyPeerCertificate(nil, [][]*x509.Certificate{{revoked, ca}}); err == nil {
+			t.Errorf("%s: revoked certificate was accepted", name)
+		}
+		if err := checker.verifyPeerCertificate(nil, [][]*x509.Certificate{{valid, ca}}); err != nil {
+			t.Errorf("%s: valid certificate was rejected: %v", name, err)
+		}
+		// Serial numbers are only unique per issuer.
+		if err := checker.verifyPeerCertificate(nil, [][]*x509.Certificate{{otherIssuer, otherCA}}); err != nil {
+			t.Errorf("%s: certificate of another issuer was rejected: %v", name, err)
+		}
+	}
+
+	path := filepath.Join(dir, "cert.pem")
+	ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+	if _, err := newCRLChecker(path); err == nil {
+		t.Error("certificate was loaded as a CRL")
+	}
+}
+
+func TestCheckConfigCRLFile(t *testing.T) {
+	options := testOptions()
+	options.TLSCRLFile = "/etc/gotty/crl.pem"
+	if err := CheckConfig(options); err == nil {
+		t.Error("CRL file was accepted without client authentication")
+	}
+}
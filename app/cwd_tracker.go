@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bytes"
+	"net/url"
+)
+
+// Shells report their working directory with an OSC 7 sequence:
+// ESC ] 7 ; file://host/path BEL (or ST instead of BEL).
+var oscCwdPrefix = []byte("\x1b]7;")
+
+// Sequences longer than this are treated as garbage and dropped.
+const maxOscCwdLength = 4096
+
+type cwdTracker struct {
+	pending []byte
+	current string
+}
+
+// Feed scans PTY output for OSC 7 sequences and returns the working
+// directories that differ from the last reported one.
+// Sequences split across reads are carried over to the next call.
+func (tracker *cwdTracker) Feed(data []byte) []string {
+	if len(tracker.pending) > 0 {
+		data = append(tracker.pending, data...)
+		tracker.pending = nil
+	}
+
+	var changes []string
+	for {
+		start := bytes.Index(data, oscCwdPrefix)
+		if start < 0 {
+			tracker.keepPartialPrefix(data)
+			return changes
+		}
+
+		body := data[start+len(oscCwdPrefix):]
+		end, size := oscTerminator(body)
+		if end < 0 {
+			if len(body) < maxOscCwdLength {
+				tracker.pending = append([]byte{}, data[start:]...)
+			}
+			return changes
+		}
+
+		if path, ok := parseCwdURL(string(body[:end])); ok && path != tracker.current {
+			tracker.current = path
+			changes = append(changes, path)
+		}
+		data = body[end+size:]
+	}
+}
+
+func (tracker *cwdTracker) keepPartialPrefix(data []byte) {
+	for i := len(oscCwdPrefix) - 1; i > 0; i-- {
+		if bytes.HasSuffix(data, oscCwdPrefix[:i]) {
+			tracker.pending = append([]byte{}, oscCwdPrefix[:i]...)
+			return
+		}
+	}
+}
+
+// oscTerminator returns the index and the length of the BEL or ST
+// terminating an OSC sequence, or -1 when it has not arrived yet.
+func oscTerminator(body []byte) (index int, size int) {
+	for i, b := range body {
+		switch b {
+		case '\a':
+			return i, 1
+		case '\x1b':
+			if i+1 == len(body) {
+				return -1, 0
+			}
+			if body[i+1] == '\\' {
+				return i, 2
+			}
+		}
+	}
+	return -1, 0
+}
+
+func parseCwdURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	return u.Path, true
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCwdTrackerFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected []string
+	}{
+		{"none", []string{"ls\r\n"}, nil},
+		{"bel", []string{"\x1b]7;file://host/tmp\a$ "}, []string{"/tmp"}},
+		{"st", []string{"\x1b]7;file://host/tmp\x1b\\$ "}, []string{"/tmp"}},
+		{"escaped", []string{"\x1b]7;file://host/a%20b\a"}, []string{"/a b"}},
+		{"unchanged", []string{"\x1b]7;file://host/tmp\a", "\x1b]7;file://host/tmp\a"}, []string{"/tmp"}},
+		{"several", []string{"\x1b]7;file://host/a\aout\x1b]7;file://host/b\a"}, []string{"/a", "/b"}},
+		{"split body", []string{"\x1b]7;file://ho", "st/tmp\a"}, []string{"/tmp"}},
+		{"split prefix", []string{"out\x1b]", "7;file://host/tmp\a"}, []string{"/tmp"}},
+		{"split st", []string{"\x1b]7;file://host/tmp\x1b", "\\"}, []string{"/tmp"}},
+		{"not file", []string{"\x1b]7;http://host/tmp\a"}, nil},
+	}
+
+	for _, test := range tests {
+		tracker := &cwdTracker{}
+		var changes []string
+		for _, chunk := range test.chunks {
+			changes = append(changes, tracker.Feed([]byte(chunk))...)
+		}
+		if !reflect.DeepEqual(changes, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, changes)
+		}
+	}
+}
+
+func TestCwdTrackerDropsOverlongSequences(t *testing.T) {
+	tracker := &cwdTracker{}
+	garbage := make([]byte, maxOscCwdLength+1)
+	for i := range garbage {
+		garbage[i] = 'x'
+	}
+	tracker.Feed(append([]byte("\x1b]7;"), garbage...))
+	if len(tracker.pending) != 0 {
+		t.Errorf("kept %d bytes of an overlong sequence", len(tracker.pending))
+	}
+}
+
+func TestTrackCwdSession(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.TrackCwd = true
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+
+	// cat writes the sequence back as if a shell reported its directory.
+	conn.WriteMessage(websocket.TextMessage, []byte("0\x1b]7;file://host/tmp\a\n"))
+	if cwd := readMessage(t, conn, SetCwd); cwd != `"/tmp"` {
+		t.Errorf("expected /tmp, got %s", cwd)
+	}
+}
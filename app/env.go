@@ -0,0 +1,105 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Prefix of environment variables overriding options.
+const envPrefix = "GOTTY_"
+
+// EnvName returns the environment variable overriding the option with the hcl tag.
+func EnvName(tag string) string {
+	return envPrefix + strings.ToUpper(tag)
+}
+
+// ApplyEnv overrides options with environment variables named after their hcl tags,
+// e.g. GOTTY_PORT for port and GOTTY_PERMIT_WRITE for permit_write.
+// Booleans accept 1/true/yes/on and 0/false/no/off, and lists of strings or numbers,
+// such as histogram buckets, are separated by commas.
+// Options which are maps or blocks, like preferences, can only be given in config files,
+// setting their variables is an error.
+func ApplyEnv(options *Options) error {
+	value := reflect.ValueOf(options).Elem()
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		tag := typ.Field(i).Tag.Get("hcl")
+		if tag == "" {
+			continue
+		}
+		name := EnvName(tag)
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		field := value.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			// The port is a string as in config files, but must still be a number.
+			if tag == "port" {
+				if _, err := strconv.ParseUint(env, 10, 16); err != nil {
+					return errors.New("Invalid value for " + name + ": " + env + " is not a port number")
+				}
+			}
+			field.SetString(env)
+		case reflect.Bool:
+			b, err := parseEnvBool(env)
+			if err != nil {
+				return errors.New("Invalid value for " + name + ": " + err.Error())
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(strings.TrimSpace(env))
+			if err != nil {
+				return errors.New("Invalid value for " + name + ": " + env + " is not an integer")
+			}
+			field.SetInt(int64(n))
+		case reflect.Slice:
+			items := splitEnvList(env)
+			switch field.Type().Elem().Kind() {
+			case reflect.String:
+				field.Set(reflect.ValueOf(items))
+			case reflect.Float64:
+				numbers := make([]float64, len(items))
+				for j, item := range items {
+					number, err := strconv.ParseFloat(item, 64)
+					if err != nil {
+						return errors.New("Invalid value for " + name + ": " + item + " is not a number")
+					}
+					numbers[j] = number
+				}
+				field.Set(reflect.ValueOf(numbers))
+			default:
+				return errors.New(name + " is not supported, give " + tag + " in a config file instead")
+			}
+		default:
+			return errors.New(name + " is not supported, give " + tag + " in a config file instead")
+		}
+	}
+	return nil
+}
+
+func parseEnvBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off", "":
+		return false, nil
+	}
+	return false, errors.New(s + " is not a boolean")
+}
+
+// splitEnvList splits a comma separated list, dropping empty items.
+func splitEnvList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
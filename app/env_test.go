@@ -0,0 +1,73 @@
+package app
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("GOTTY_TITLE_FORMAT", "{{ .Command }}")
+	t.Setenv("GOTTY_RANDOM_URL_LENGTH", " 12 ")
+	t.Setenv("GOTTY_PERMIT_WRITE", "yes")
+	t.Setenv("GOTTY_ENABLE_RECONNECT", "0")
+	t.Setenv("GOTTY_ALLOW_IPS", "10.0.0.0/8, ,192.168.0.0/16")
+	t.Setenv("GOTTY_SESSION_DURATION_BUCKETS", "1,2.5, 60")
+
+	options := testOptions()
+	options.EnableReconnect = true
+	if err := ApplyEnv(options); err != nil {
+		t.Fatal(err)
+	}
+	if options.TitleFormat != "{{ .Command }}" {
+		t.Errorf("unexpected title format %q", options.TitleFormat)
+	}
+	if options.RandomUrlLength != 12 {
+		t.Errorf("unexpected random URL length %d", options.RandomUrlLength)
+	}
+	if !options.PermitWrite || options.EnableReconnect {
+		t.Errorf("booleans were not applied: permit write %v, reconnect %v", options.PermitWrite, options.EnableReconnect)
+	}
+	if expected := []string{"10.0.0.0/8", "192.168.0.0/16"}; !reflect.DeepEqual(options.AllowIPs, expected) {
+		t.Errorf("expected %q, got %q", expected, options.AllowIPs)
+	}
+	if expected := []float64{1, 2.5, 60}; !reflect.DeepEqual(options.SessionDurationBuckets, expected) {
+		t.Errorf("expected %v, got %v", expected, options.SessionDurationBuckets)
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	for name, value := range map[string]string{
+		"GOTTY_PORT":                     "abc",
+		"GOTTY_PERMIT_WRITE":             "maybe",
+		"GOTTY_REQUEST_DURATION_BUCKETS": "0.1,fast",
+		// Maps and blocks can only be given in config files.
+		"GOTTY_ENV":         "TERM=xterm",
+		"GOTTY_PREFERENCES": "font_size=12",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if err := ApplyEnv(testOptions()); err == nil {
+				t.Errorf("%s=%s was accepted", name, value)
+			}
+		})
+	}
+}
+
+func TestApplyEnvOverridesConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gotty.hcl")
+	ioutil.WriteFile(path, []byte("port = \"9000\"\naddress = \"127.0.0.1\"\n"), 0600)
+	t.Setenv("GOTTY_PORT", "9001")
+
+	options := testOptions()
+	if err := ApplyConfigFile(options, path); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyEnv(options); err != nil {
+		t.Fatal(err)
+	}
+	if options.Port != "9001" || options.Address != "127.0.0.1" {
+		t.Errorf("expected the port of the environment and the address of the file, got %s:%s", options.Address, options.Port)
+	}
+}
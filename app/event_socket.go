@@ -0,0 +1,92 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Events waiting to be written to the event socket, more are dropped.
+const eventSocketQueueSize = 64
+
+// eventSocket publishes session lifecycle events as JSON datagrams to the unix datagram socket
+// at EventSocket, one event per datagram, for a supervisor running on the same host.
+// Events are queued and written in order by a single goroutine. When the receiver
+// doesn't keep up or isn't listening, events are dropped rather than blocking sessions.
+type eventSocket struct {
+	path  string
+	queue chan SessionEvent
+	conn  *net.UnixConn
+
+	// Use atomic operations.
+	dropped int64
+}
+
+func newEventSocket(path string) *eventSocket {
+	return &eventSocket{
+		path:  path,
+		queue: make(chan SessionEvent, eventSocketQueueSize),
+	}
+}
+
+func (socket *eventSocket) enqueue(event SessionEvent) {
+	select {
+	case socket.queue <- event:
+	default:
+		dropped := atomic.AddInt64(&socket.dropped, 1)
+		log.Printf("Event socket queue is full, dropped %s event (%d dropped in total)", event.Event, dropped)
+	}
+}
+
+func (socket *eventSocket) goDeliver(quit <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case event := <-socket.queue:
+				if err := socket.write(event); err != nil {
+					dropped := atomic.AddInt64(&socket.dropped, 1)
+					log.Printf("Failed to write %s event to %s, dropped it (%d dropped in total): %v", event.Event, socket.path, dropped, err)
+				}
+			case <-quit:
+				if socket.conn != nil {
+					socket.conn.Close()
+				}
+				return
+			}
+		}
+	}()
+}
+
+// write sends the event, connecting first if the receiver wasn't listening before
+// or went away since.
+func (socket *eventSocket) write(event SessionEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if socket.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket.path, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		socket.conn = conn
+	}
+	// A full receive buffer of the receiver blocks the write.
+	socket.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err := socket.conn.Write(payload); err != nil {
+		socket.conn.Close()
+		socket.conn = nil
+		return err
+	}
+	return nil
+}
+
+// emitLifecycleEvent publishes an event of the steps of a session:
+// "connect", "auth", "start" and "exit", to EventSocket. It never blocks.
+func (app *App) emitLifecycleEvent(event SessionEvent) {
+	if app.eventSocket != nil {
+		app.eventSocket.enqueue(event)
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventSocketSessionEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.sock")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	options := testOptions()
+	options.EventSocket = path
+	app := newTestCommandApp(t, []string{"sh", "-c", "exit 3"}, options)
+	quit := make(chan struct{})
+	defer close(quit)
+	app.eventSocket.goDeliver(quit)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+
+	var id string
+	buffer := make([]byte, 65536)
+	for _, expected := range []string{"connect", "auth", "start", "exit"} {
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := listener.Read(buffer)
+		if err != nil {
+			t.Fatalf("no %s event was received: %v", expected, err)
+		}
+		var event SessionEvent
+		if err := json.Unmarshal(buffer[:n], &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Event != expected {
+			t.Fatalf("expected %s event, got %+v", expected, event)
+		}
+		switch expected {
+		case "start":
+			if event.SessionID == "" || event.Pid == 0 {
+				t.Errorf("start event without session %+v", event)
+			}
+			id = event.SessionID
+		case "exit":
+			if event.SessionID != id || event.ExitCode == nil || *event.ExitCode != 3 {
+				t.Errorf("unexpected exit event %+v", event)
+			}
+		}
+	}
+}
+
+func TestEventSocketDropsWithoutReceiver(t *testing.T) {
+	socket := newEventSocket(filepath.Join(t.TempDir(), "missing.sock"))
+	for i := 0; i < eventSocketQueueSize+1; i++ {
+		socket.enqueue(SessionEvent{Event: "connect"})
+	}
+	if socket.dropped != 1 {
+		t.Errorf("expected 1 dropped event, got %d", socket.dropped)
+	}
+	if err := socket.write(SessionEvent{Event: "connect"}); err == nil {
+		t.Error("event was written without a receiver")
+	}
+}
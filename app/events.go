@@ -0,0 +1,51 @@
+package app
+
+import (
+	"time"
+)
+
+// SessionEvent describes a change in the lifecycle of a session,
+// delivered to the configured event sinks.
+type SessionEvent struct {
+	Event      string // "connect" or "disconnect", see also emitLifecycleEvent
+	Time       time.Time
+	SessionID  string
+	Label      string `json:",omitempty"`
+	RemoteAddr string
+	User       string   `json:",omitempty"`
+	Command    []string `json:",omitempty"`
+	Pid        int      `json:",omitempty"`
+	Duration   float64  `json:",omitempty"` // seconds, set once the command exited
+	ExitCode   *int     `json:",omitempty"` // set once the command exited
+}
+
+func (context *clientContext) event(name string) SessionEvent {
+	event := SessionEvent{
+		Event:      name,
+		Time:       time.Now(),
+		SessionID:  context.id,
+		Label:      context.label,
+		RemoteAddr: context.request.RemoteAddr,
+		User:       context.user,
+		Command:    context.command.Args,
+		Pid:        context.command.Process.Pid,
+	}
+	if name == "disconnect" || name == "exit" {
+		event.Duration = time.Since(context.startTime).Seconds()
+		if state := context.command.ProcessState; state != nil {
+			exitCode := state.ExitCode()
+			event.ExitCode = &exitCode
+		}
+	}
+	return event
+}
+
+// emitSessionEvent hands the event to the sinks. It never blocks the session.
+func (app *App) emitSessionEvent(event SessionEvent) {
+	if app.webhook != nil {
+		app.webhook.enqueue(event)
+	}
+	if app.k8sEvents != nil {
+		app.k8sEvents.enqueue(event)
+	}
+}
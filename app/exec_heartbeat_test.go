@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// streamExecRequest runs a streamed remote exec and returns the events sent.
+func streamExecRequest(t *testing.T, app *App, req ExecMessageReq) string {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest("POST", "/rexec?stream=1", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	captureLog(func() { app.handleRemoteExec(w, r) })
+	return w.Body.String()
+}
+
+func TestExecStreamHeartbeat(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat int
+		script    string
+		expected  bool
+	}{
+		{"quiet command", 1, "sleep 1.5; echo done", true},
+		{"disabled", 0, "sleep 1.2; echo done", false},
+		{"busy command", 1, "for i in 1 2 3 4 5 6 7 8 9 10 11 12; do echo $i; sleep 0.1; done; echo done", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testOptions()
+			options.ExecWhitelist = []string{"sh"}
+			options.ExecStreamHeartbeat = test.heartbeat
+			app := newTestApp(t, options)
+
+			events := streamExecRequest(t, app, ExecMessageReq{Command: "sh", Arguments: []string{"-c", test.script}})
+			keepalive := strings.Index(events, ": keepalive\n\n")
+			if (keepalive >= 0) != test.expected {
+				t.Fatalf("Unexpected heartbeats in %q", events)
+			}
+			done := strings.Index(events, "\"done\\n\"")
+			if done < 0 || !strings.Contains(events[done:], "event: exit") {
+				t.Fatalf("Expected the output and exit events in %q", events)
+			}
+			if test.expected && keepalive > done {
+				t.Errorf("Expected heartbeats before the output in %q", events)
+			}
+		})
+	}
+}
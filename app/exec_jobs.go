@@ -0,0 +1,135 @@
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type execJob struct {
+	created  time.Time
+	finished time.Time
+	rsp      ExecMessageRsp
+}
+
+// execJobStore keeps the results of asynchronous remote exec requests
+// until they are fetched, expire or get evicted by newer jobs.
+type execJobStore struct {
+	maxAge   time.Duration
+	maxCount int
+
+	mutex *sync.Mutex
+	jobs  map[string]*execJob
+}
+
+func newExecJobStore(maxAge time.Duration, maxCount int) *execJobStore {
+	return &execJobStore{
+		maxAge:   maxAge,
+		maxCount: maxCount,
+		mutex:    &sync.Mutex{},
+		jobs:     make(map[string]*execJob),
+	}
+}
+
+// add registers a running job and returns its ID.
+// It fails when the store is full of jobs which are still running.
+func (store *execJobStore) add(req *ExecMessageReq) (string, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.maxCount > 0 && len(store.jobs) >= store.maxCount {
+		store.evictOldest(len(store.jobs) - store.maxCount + 1)
+		if len(store.jobs) >= store.maxCount {
+			return "", false
+		}
+	}
+
+	id := generateRandomString(16)
+	rsp := newExecRsp(req)
+	rsp.Job = id
+	rsp.Running = true
+	store.jobs[id] = &execJob{
+		created: time.Now(),
+		rsp:     rsp,
+	}
+	return id, true
+}
+
+func (store *execJobStore) finish(id string, rsp ExecMessageRsp) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	job, ok := store.jobs[id]
+	if !ok {
+		return
+	}
+	rsp.Job = id
+	job.rsp = rsp
+	job.finished = time.Now()
+}
+
+// take returns the state of a job.
+// Finished jobs are removed so that their output is released right after being delivered.
+func (store *execJobStore) take(id string) (ExecMessageRsp, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	job, ok := store.jobs[id]
+	if !ok {
+		return ExecMessageRsp{}, false
+	}
+	if !job.finished.IsZero() {
+		delete(store.jobs, id)
+	}
+	return job.rsp, true
+}
+
+// sweep removes finished jobs older than maxAge.
+func (store *execJobStore) sweep(now time.Time) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for id, job := range store.jobs {
+		if !job.finished.IsZero() && now.Sub(job.finished) > store.maxAge {
+			delete(store.jobs, id)
+		}
+	}
+}
+
+// evictOldest removes up to count finished jobs, oldest first.
+func (store *execJobStore) evictOldest(count int) {
+	finished := make([]string, 0, len(store.jobs))
+	for id, job := range store.jobs {
+		if !job.finished.IsZero() {
+			finished = append(finished, id)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return store.jobs[finished[i]].created.Before(store.jobs[finished[j]].created)
+	})
+	for i := 0; i < count && i < len(finished); i++ {
+		delete(store.jobs, finished[i])
+	}
+}
+
+func (store *execJobStore) goSweep(quit <-chan struct{}) {
+	if store.maxAge <= 0 {
+		return
+	}
+	interval := store.maxAge / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				store.sweep(now)
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
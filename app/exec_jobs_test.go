@@ -0,0 +1,88 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExecJobStore(t *testing.T) {
+	store := newExecJobStore(time.Minute, 2)
+
+	first, ok := store.add(&ExecMessageReq{Command: "first"})
+	if !ok {
+		t.Fatal("failed to add a job")
+	}
+	if rsp, ok := store.take(first); !ok || !rsp.Running {
+		t.Errorf("running job: %+v (found %v)", rsp, ok)
+	}
+	// Running jobs stay until they finish.
+	if _, ok := store.take(first); !ok {
+		t.Error("running job was removed when taken")
+	}
+
+	second, _ := store.add(&ExecMessageReq{Command: "second"})
+	if _, ok := store.add(&ExecMessageReq{Command: "third"}); ok {
+		t.Error("added a job to a store full of running jobs")
+	}
+
+	// A finished job is evicted for a new one.
+	store.finish(first, ExecMessageRsp{ExitCode: 0})
+	third, ok := store.add(&ExecMessageReq{Command: "third"})
+	if !ok {
+		t.Fatal("finished job was not evicted")
+	}
+	if _, ok := store.take(first); ok {
+		t.Error("evicted job is still found")
+	}
+
+	// Finished jobs are delivered once.
+	store.finish(second, ExecMessageRsp{ExitCode: 3})
+	if rsp, ok := store.take(second); !ok || rsp.Running || rsp.ExitCode != 3 || rsp.Job != second {
+		t.Errorf("finished job: %+v (found %v)", rsp, ok)
+	}
+	if _, ok := store.take(second); ok {
+		t.Error("finished job was delivered twice")
+	}
+
+	// Unfetched finished jobs expire.
+	store.finish(third, ExecMessageRsp{})
+	store.sweep(time.Now().Add(2 * time.Minute))
+	if _, ok := store.take(third); ok {
+		t.Error("expired job is still found")
+	}
+}
+
+func TestExecAsync(t *testing.T) {
+	options := testOptions()
+	options.ExecWhitelist = []string{"echo"}
+	app := newTestApp(t, options)
+
+	code, rsp := postExecRequest(t, app, ExecMessageReq{Command: "echo", Arguments: []string{"async"}, Async: true})
+	if code != http.StatusOK || rsp.Job == "" || !rsp.Running {
+		t.Fatalf("unexpected response %d: %+v", code, rsp)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		app.handleRemoteExec(w, httptest.NewRequest("GET", "/rexec?job="+rsp.Job, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("job lookup answered %d", w.Code)
+		}
+		var result ExecMessageRsp
+		json.NewDecoder(w.Body).Decode(&result)
+		if !result.Running {
+			if result.Output1 != "async\n" {
+				t.Errorf("unexpected output %q", result.Output1)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job didn't finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Outputs longer than this are truncated in exec logs.
+const execLogMaxOutput = 4096
+
+type execClient struct {
+	RemoteAddr string
+	User       string
+}
+
+// logExec writes a record of the remote exec request to a new file in ExecLogDir.
+// The file is written in background not to delay the response.
+func (app *App) logExec(client execClient, rsp ExecMessageRsp) {
+	if app.options.ExecLogDir == "" {
+		return
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("exec-%s-%s.log", now.Format("20060102-150405.000000"), generateRandomString(4))
+	path := filepath.Join(ExpandHomeDir(app.options.ExecLogDir), name)
+
+	go func() {
+		record := new(bytes.Buffer)
+		fmt.Fprintf(record, "time: %s\n", now.Format(time.RFC3339Nano))
+		fmt.Fprintf(record, "remote_addr: %s\n", client.RemoteAddr)
+		fmt.Fprintf(record, "user: %s\n", client.User)
+		fmt.Fprintf(record, "command: %s\n", rsp.Command)
+		fmt.Fprintf(record, "arguments: %q\n", rsp.Arguments)
+		fmt.Fprintf(record, "exit_code: %d\n", rsp.ExitCode)
+		if rsp.TimedOut {
+			fmt.Fprintf(record, "timed_out: true\n")
+		}
+		if rsp.Error != "" {
+			fmt.Fprintf(record, "error: %s\n", rsp.Error)
+		}
+		fmt.Fprintf(record, "--- stdout ---\n%s\n", truncate(rsp.Output1, execLogMaxOutput))
+		fmt.Fprintf(record, "--- stderr ---\n%s\n", truncate(rsp.Output2, execLogMaxOutput))
+
+		if err := ioutil.WriteFile(path, record.Bytes(), 0600); err != nil {
+			log.Printf("Failed to write exec log %s: %v", path, err)
+		}
+	}()
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "...<truncated>"
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecLog(t *testing.T) {
+	options := testOptions()
+	options.ExecWhitelist = []string{"echo"}
+	options.ExecLogDir = t.TempDir()
+	app := newTestApp(t, options)
+
+	if code, _ := postExecRequest(t, app, ExecMessageReq{Command: "echo", Arguments: []string{"logged"}}); code != http.StatusOK {
+		t.Fatalf("exec answered %d", code)
+	}
+
+	// Written in background.
+	var files []string
+	deadline := time.Now().Add(5 * time.Second)
+	for len(files) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("exec log was not written")
+		}
+		time.Sleep(10 * time.Millisecond)
+		files, _ = filepath.Glob(filepath.Join(options.ExecLogDir, "exec-*.log"))
+	}
+
+	record := readFile(t, files[0])
+	for _, expected := range []string{
+		"command: echo\n",
+		"arguments: [\"logged\"]\n",
+		"exit_code: 0\n",
+		"--- stdout ---\nlogged\n",
+	} {
+		if !strings.Contains(record, expected) {
+			t.Errorf("exec log doesn't contain %q:\n%s", expected, record)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if s := truncate("abc", 3); s != "abc" {
+		t.Errorf("short string was changed: %q", s)
+	}
+	if s := truncate("abcd", 3); s != "abc...<truncated>" {
+		t.Errorf("unexpected truncated string %q", s)
+	}
+}
@@ -0,0 +1,20 @@
+package app
+
+import (
+	"net/http"
+)
+
+// execIdentity returns the authenticated user of an exec request, or its address without one.
+// The user is trusted only once its credential has been verified with Basic Authentication,
+// otherwise anyone could get a fresh bucket by sending a different user name.
+func (app *App) execIdentity(r *http.Request) string {
+	if app.options.EnableBasicAuth {
+		if user := app.authenticatedUser(r); user != "" {
+			return "user:" + user
+		}
+	}
+	if ip := app.clientIP(r); ip != nil {
+		return "ip:" + ip.String()
+	}
+	return "addr:" + r.RemoteAddr
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postExec(app *App, remoteAddr, user, password string) int {
+	r := httptest.NewRequest("POST", "/rexec", strings.NewReader("{"))
+	r.RemoteAddr = remoteAddr
+	if user != "" {
+		r.SetBasicAuth(user, password)
+	}
+	w := httptest.NewRecorder()
+	app.handleRemoteExec(w, r)
+	return w.Code
+}
+
+func TestExecRateLimitThrottles(t *testing.T) {
+	limiters := newRateLimiters(1, 2)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if !limiters.allow("ip:192.0.2.1", now) {
+			t.Fatalf("request %d within the burst was throttled", i+1)
+		}
+	}
+	if limiters.allow("ip:192.0.2.1", now) {
+		t.Error("request beyond the burst was allowed")
+	}
+	if !limiters.allow("ip:192.0.2.2", now) {
+		t.Error("another identity was throttled")
+	}
+	if !limiters.allow("ip:192.0.2.1", now.Add(time.Second)) {
+		t.Error("request was throttled after the bucket refilled")
+	}
+}
+
+func TestExecRateLimitIgnoresUnverifiedUsers(t *testing.T) {
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = "alice:a"
+	options.ExecRateLimit = 1
+	options.ExecRateBurst = 2
+	app := newTestApp(t, options)
+
+	// Rotating made-up user names must not give fresh buckets.
+	for i, user := range []string{"mallory1", "mallory2"} {
+		if code := postExec(app, "192.0.2.1:1000", user, "x"); code == http.StatusTooManyRequests {
+			t.Fatalf("request %d within the burst was throttled", i+1)
+		}
+	}
+	if code := postExec(app, "192.0.2.1:1000", "mallory3", "x"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for a spoofed user, got %d", code)
+	}
+
+	// A verified user has a bucket of its own.
+	if code := postExec(app, "192.0.2.1:1000", "alice", "a"); code == http.StatusTooManyRequests {
+		t.Error("authenticated user was throttled by the bucket of its address")
+	}
+}
+
+func TestExecIdentity(t *testing.T) {
+	options := testOptions()
+	options.Credential = "alice:a"
+	app := newTestApp(t, options)
+
+	r := httptest.NewRequest("POST", "/rexec", nil)
+	r.RemoteAddr = "192.0.2.1:1000"
+	r.SetBasicAuth("alice", "a")
+	if identity := app.execIdentity(r); identity != "ip:192.0.2.1" {
+		t.Errorf("without Basic Authentication, got identity %q", identity)
+	}
+
+	options.EnableBasicAuth = true
+	if identity := app.execIdentity(r); identity != "user:alice" {
+		t.Errorf("with a verified user, got identity %q", identity)
+	}
+	r.SetBasicAuth("alice", "wrong")
+	if identity := app.execIdentity(r); identity != "ip:192.0.2.1" {
+		t.Errorf("with a wrong password, got identity %q", identity)
+	}
+}
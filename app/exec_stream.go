@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// In the streaming mode of remote exec, outputs are sent as Server-Sent Events
+// named stdout and stderr, whose data is a chunk of the output as a JSON string.
+// An exit event carrying ExecStreamExit comes last.
+// Outputs are not buffered, so MaxOutput doesn't apply.
+// With ExecStreamHeartbeat, a comment is sent when the command has been quiet
+// for that many seconds, so that proxies don't close the stream.
+type ExecStreamExit struct {
+	ExitCode int      // -1 when the command couldn't be started or was killed by a signal
+	TimedOut bool     `json:",omitempty"`
+	Error    string   `json:",omitempty"` // failures of gotty only
+	Notes    []string `json:",omitempty"`
+}
+
+type execChunk struct {
+	event string
+	data  []byte
+}
+
+// wantsExecStream reports whether the client asks for the outputs to be streamed,
+// either with an Accept header or with the stream query parameter.
+func wantsExecStream(r *http.Request) bool {
+	if r.URL.Query().Get("stream") == "1" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamExec runs the requested command and sends its outputs while they are produced.
+// The command is killed when the client goes away.
+func (app *App) streamExec(w http.ResponseWriter, r *http.Request, req *ExecMessageReq, client execClient) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(req.Timeout)*time.Second)
+	defer cancel()
+
+	log.Printf("Exec %s streaming to %s", req.summary(), r.RemoteAddr)
+
+	rsp := newExecRsp(req)
+	defer func() {
+		app.metrics.execFinished(rsp.Error == "" && rsp.ExitCode == 0)
+		app.logExec(client, rsp)
+	}()
+
+	cmd := app.execCommand(ctx, req)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		rsp.Error = fmt.Sprintf("Can not connect to stdout for command %q: %v", req.Command, err)
+		http.Error(w, rsp.Error, http.StatusInternalServerError)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		rsp.Error = fmt.Sprintf("Can not connect to stderr for command %q: %v", req.Command, err)
+		http.Error(w, rsp.Error, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := cmd.Start(); err != nil {
+		rsp.Error = fmt.Sprintf("Can not start command %q: %v", req.Command, err)
+		writeExecEvent(w, "exit", ExecStreamExit{ExitCode: rsp.ExitCode, Error: rsp.Error, Notes: rsp.Notes})
+		flusher.Flush()
+		return
+	}
+
+	chunks := make(chan execChunk)
+	readers := 2
+	go readExecOutput("stdout", stdout, chunks)
+	go readExecOutput("stderr", stderr, chunks)
+
+	var heartbeat <-chan time.Time
+	if app.options.ExecStreamHeartbeat > 0 {
+		ticker := time.NewTicker(time.Duration(app.options.ExecStreamHeartbeat) * time.Second)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	// The beginning of the outputs is kept for the exec log.
+	var head [2]strings.Builder
+	quiet := true
+	for readers > 0 {
+		var chunk execChunk
+		select {
+		case chunk = <-chunks:
+		case <-heartbeat:
+			if quiet {
+				if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+					cancel()
+				}
+				flusher.Flush()
+			}
+			quiet = true
+			continue
+		}
+		quiet = false
+		if chunk.data == nil {
+			readers--
+			continue
+		}
+		builder := &head[0]
+		if chunk.event == "stderr" {
+			builder = &head[1]
+		}
+		if builder.Len() <= execLogMaxOutput {
+			builder.Write(chunk.data)
+		}
+		if err := writeExecEvent(w, chunk.event, string(chunk.data)); err != nil {
+			// Kill the command and drain its outputs.
+			cancel()
+			continue
+		}
+		flusher.Flush()
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok && err != ctx.Err() {
+			rsp.Error = fmt.Sprintf("Failed to wait for command %q: %v", req.Command, err)
+		}
+	}
+	rsp.ExitCode = cmd.ProcessState.ExitCode()
+	rsp.TimedOut = ctx.Err() == context.DeadlineExceeded
+	rsp.Output1 = head[0].String()
+	rsp.Output2 = head[1].String()
+
+	writeExecEvent(w, "exit", ExecStreamExit{ExitCode: rsp.ExitCode, TimedOut: rsp.TimedOut, Error: rsp.Error, Notes: rsp.Notes})
+	flusher.Flush()
+}
+
+// readExecOutput sends chunks of an output of the command until it's closed,
+// then a chunk without data. Reads are split on character boundaries.
+func readExecOutput(event string, output io.Reader, chunks chan<- execChunk) {
+	defer func() { chunks <- execChunk{event: event} }()
+	var pending []byte
+	for {
+		buf := make([]byte, 4096)
+		size, err := output.Read(buf)
+
+		// Keep multi-byte characters in one event.
+		data := append(pending, buf[:size]...)
+		cut := completeRunes(data)
+		if err != nil {
+			cut = len(data)
+		}
+		pending = append([]byte(nil), data[cut:]...)
+		if cut > 0 {
+			chunks <- execChunk{event: event, data: data[:cut]}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeExecEvent(w io.Writer, event string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+	return err
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestExitMessages(t *testing.T) {
+	tests := []struct {
+		script  string
+		message string
+		code    int
+		reason  string
+	}{
+		{"exit 3", "Your session has expired.", websocket.CloseNormalClosure, "Command exited with status 3: Your session has expired."},
+		// Signals are reported with the exit code of shells.
+		{"kill -SEGV $$", "The command crashed.", websocket.CloseInternalServerErr, "Command exited with status 139: The command crashed."},
+		{"exit 4", "", websocket.CloseNormalClosure, "Command exited with status 4"},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.ExitMessages = map[string]string{
+			"3":   "Your session has expired.",
+			"139": "The command crashed.",
+		}
+		app := newTestCommandApp(t, []string{"sh", "-c", test.script}, options)
+		server := startTestServer(app)
+
+		conn := dialTestSession(t, server, InitMessage{})
+		if test.message != "" {
+			readOutput(t, conn, "\r\n"+test.message+"\r\n")
+		}
+		err := waitClosed(t, conn)
+		if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != test.code || closeErr.Text != test.reason {
+			t.Errorf("%s: expected %d %q, got %v", test.script, test.code, test.reason, err)
+		}
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestCheckConfigExitMessages(t *testing.T) {
+	tests := []struct {
+		code  string
+		valid bool
+	}{
+		{"0", true},
+		{"130", true},
+		{"SIGINT", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.ExitMessages = map[string]string{test.code: "Bye"}
+		if err := CheckConfig(options); (err == nil) != test.valid {
+			t.Errorf("Exit code %q: unexpected error %v", test.code, err)
+		}
+	}
+}
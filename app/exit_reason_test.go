@@ -0,0 +1,47 @@
+package app
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCloseWithExitReason(t *testing.T) {
+	tests := []struct {
+		script string
+		code   int
+		reason string
+	}{
+		{"exit 0", websocket.CloseNormalClosure, "Command exited"},
+		{"exit 3", websocket.CloseNormalClosure, "Command exited with status 3"},
+		{"kill -SEGV $$", websocket.CloseInternalServerErr, "Command terminated abnormally: segmentation fault"},
+	}
+	for _, test := range tests {
+		app := newTestCommandApp(t, []string{"sh", "-c", test.script}, testOptions())
+		server := startTestServer(app)
+
+		conn := dialTestSession(t, server, InitMessage{})
+		err := waitClosed(t, conn)
+		if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != test.code || closeErr.Text != test.reason {
+			t.Errorf("%s: expected %d %q, got %v", test.script, test.code, test.reason, err)
+		}
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestIsPtyClosed(t *testing.T) {
+	for err, expected := range map[error]bool{
+		io.EOF: true,
+		&os.PathError{Op: "read", Err: syscall.EIO}: true,
+		os.ErrClosed: true,
+		&os.PathError{Op: "read", Err: syscall.EBADF}: false,
+	} {
+		if closed := isPtyClosed(err); closed != expected {
+			t.Errorf("%v: expected %v", err, expected)
+		}
+	}
+}
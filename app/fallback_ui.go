@@ -0,0 +1,138 @@
+package app
+
+import (
+	"net/http"
+)
+
+// bundledAssetsAvailable reports whether the bundled frontend has been built into the binary.
+// Embedders of this package may replace resource.go with one that lacks it.
+func bundledAssetsAvailable() bool {
+	for _, name := range []string{"static/index.html", "static/js/hterm.js", "static/js/gotty.js"} {
+		if _, err := Asset(name); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (app *App) handleFallbackIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(fallbackIndexHTML))
+}
+
+// fallbackIndexHTML is a minimal terminal speaking the gotty protocol, without hterm.
+// Escape sequences are dropped from the output, so full screen programs won't render,
+// but line oriented commands are usable.
+const fallbackIndexHTML = `<!doctype html>
+<html>
+  <head>
+    <meta charset="utf-8">
+    <title>GoTTY</title>
+    <style>
+      body {margin: 0; background: #000; color: #ddd;}
+      #terminal {position: absolute; height: 100%; width: 100%; margin: 0; padding: 4px; box-sizing: border-box;
+                 overflow-y: auto; white-space: pre-wrap; word-break: break-all; font: 14px monospace; outline: none;}
+      #measure {position: absolute; visibility: hidden; font: 14px monospace;}
+    </style>
+  </head>
+  <body>
+    <pre id="terminal" tabindex="0"></pre>
+    <span id="measure">M</span>
+    <script src="./auth_token.js"></script>
+    <script>
+    (function() {
+        var terminal = document.getElementById("terminal");
+        var scheme = window.location.protocol == "https:" ? "wss://" : "ws://";
+        var ws = new WebSocket(scheme + window.location.host + window.location.pathname + "ws", ["gotty"]);
+        var decoder = new TextDecoder("utf-8");
+        var escape = /\x1b(\[[0-?]*[ -\/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[ -\/]*[0-Z\\^-~])/g;
+        var complete = new RegExp("^(?:" + escape.source + ")");
+        var pending = "";
+
+        var write = function(text) {
+            text = pending + text;
+            // Keep an incomplete escape sequence for the next output.
+            var last = text.lastIndexOf("\x1b");
+            pending = "";
+            if (last >= 0 && !complete.test(text.slice(last))) {
+                pending = text.slice(last);
+                text = text.slice(0, last);
+            }
+            text = text.replace(escape, "").replace(/\r\n/g, "\n").replace(/\r/g, "");
+            var content = terminal.textContent;
+            for (var i = 0; i < text.length; i++) {
+                if (text[i] == "\b") {
+                    content = content.slice(0, -1);
+                } else if (text[i] == "\n" || text[i] == "\t" || text[i] >= " ") {
+                    content += text[i];
+                }
+            }
+            terminal.textContent = content;
+            terminal.scrollTop = terminal.scrollHeight;
+        };
+
+        var resize = function() {
+            var measure = document.getElementById("measure").getBoundingClientRect();
+            ws.send("2" + JSON.stringify({
+                columns: Math.floor((terminal.clientWidth - 8) / measure.width),
+                rows: Math.floor((terminal.clientHeight - 8) / measure.height),
+            }));
+        };
+
+        var keys = {
+            Enter: "\r", Backspace: "\x7f", Tab: "\t", Escape: "\x1b",
+            ArrowUp: "\x1b[A", ArrowDown: "\x1b[B", ArrowRight: "\x1b[C", ArrowLeft: "\x1b[D",
+            Home: "\x1b[H", End: "\x1b[F", Delete: "\x1b[3~",
+        };
+
+        terminal.addEventListener("keydown", function(event) {
+            var input = keys[event.key];
+            if (!input && event.ctrlKey && event.key.length == 1) {
+                var code = event.key.toUpperCase().charCodeAt(0);
+                if (code >= 64 && code < 96) {
+                    input = String.fromCharCode(code - 64);
+                }
+            } else if (!input && !event.metaKey && event.key.length == 1) {
+                input = event.key;
+            }
+            if (input) {
+                ws.send("0" + input);
+                event.preventDefault();
+            }
+        });
+        terminal.addEventListener("paste", function(event) {
+            ws.send("0" + event.clipboardData.getData("text"));
+            event.preventDefault();
+        });
+
+        ws.onopen = function() {
+            ws.send(JSON.stringify({Arguments: window.location.search, AuthToken: gotty_auth_token}));
+            resize();
+            window.addEventListener("resize", resize);
+            setInterval(function() { ws.send("1"); }, 30 * 1000);
+            terminal.focus();
+        };
+        ws.onmessage = function(event) {
+            var data = event.data.slice(1);
+            switch (event.data[0]) {
+            case "0":
+                var binary = window.atob(data);
+                var bytes = new Uint8Array(binary.length);
+                for (var i = 0; i < binary.length; i++) {
+                    bytes[i] = binary.charCodeAt(i);
+                }
+                write(decoder.decode(bytes, {stream: true}));
+                break;
+            case "2":
+                document.title = data;
+                break;
+            }
+        };
+        ws.onclose = function() {
+            write("\n[Connection Closed]\n");
+        };
+    })();
+    </script>
+  </body>
+</html>
+`
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBundledAssetsAvailable(t *testing.T) {
+	if !bundledAssetsAvailable() {
+		t.Error("bundled frontend is reported missing from resource.go")
+	}
+}
+
+func TestFallbackIndex(t *testing.T) {
+	app := newTestApp(t, testOptions())
+	w := httptest.NewRecorder()
+	app.handleFallbackIndex(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("fallback index answered %d with %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	// The page speaks the gotty protocol on its own, without hterm.
+	body := w.Body.String()
+	for _, expected := range []string{`src="./auth_token.js"`, `pathname + "ws"`, "AuthToken: gotty_auth_token"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("fallback index does not contain %q", expected)
+		}
+	}
+	if strings.Contains(body, "hterm") {
+		t.Error("fallback index depends on hterm")
+	}
+}
+
+var fallbackWSPattern = regexp.MustCompile(`new WebSocket\(scheme \+ window\.location\.host \+ window\.location\.pathname \+ "(\w+)", \["(\w+)"\]\)`)
+
+// The fallback page gets its token from auth_token.js next to it and opens
+// the websocket next to it as well, as served by handleTerminal.
+func TestFallbackIndexSession(t *testing.T) {
+	app := newTestCommandApp(t, []string{"echo", "hello from the fallback terminal"}, testOptions())
+	mux := http.NewServeMux()
+	mux.Handle("/", exactPath("/", http.HandlerFunc(app.handleFallbackIndex)))
+	mux.HandleFunc("/auth_token.js", app.handleAuthToken)
+	mux.HandleFunc("/ws", app.handleWS)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	page := fetchBody(t, server.URL+"/")
+	if !strings.Contains(page, `<script src="./auth_token.js"></script>`) {
+		t.Fatal("fallback index doesn't load auth_token.js")
+	}
+	match := authTokenPattern.FindStringSubmatch(fetchBody(t, server.URL+"/auth_token.js"))
+	if match == nil {
+		t.Fatal("auth_token.js doesn't define the token")
+	}
+	ws := fallbackWSPattern.FindStringSubmatch(page)
+	if ws == nil {
+		t.Fatal("fallback index doesn't open a websocket")
+	}
+
+	dialer := websocket.Dialer{Subprotocols: []string{ws[2]}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/"+ws[1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	message, _ := json.Marshal(InitMessage{Arguments: "", AuthToken: match[1]})
+	conn.WriteMessage(websocket.TextMessage, message)
+	readOutput(t, conn, "hello from the fallback terminal")
+}
+
+func fetchBody(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s answered %d", url, resp.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body)
+}
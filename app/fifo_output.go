@@ -0,0 +1,77 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// fifoOutput copies the output of a session to a named pipe
+// for external processes such as monitoring dashboards.
+// It never blocks the session: output is dropped while no reader has the pipe open
+// or while the reader falls behind.
+type fifoOutput struct {
+	path string
+
+	mutex *sync.Mutex
+	fd    int // -1 while no reader is connected
+}
+
+// newFifoOutput creates the named pipe of a session in dir.
+func newFifoOutput(dir string, sessionID string) (*fifoOutput, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, sessionID+".fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return nil, &os.PathError{Op: "mkfifo", Path: path, Err: err}
+	}
+	return &fifoOutput{
+		path:  path,
+		mutex: &sync.Mutex{},
+		fd:    -1,
+	}, nil
+}
+
+func (fifo *fifoOutput) write(data []byte) {
+	fifo.mutex.Lock()
+	defer fifo.mutex.Unlock()
+
+	if fifo.fd < 0 {
+		// Opening a pipe without reader fails with ENXIO instead of blocking.
+		fd, err := syscall.Open(fifo.path, syscall.O_WRONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return
+		}
+		fifo.fd = fd
+	}
+
+	for len(data) > 0 {
+		n, err := syscall.Write(fifo.fd, data)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EPIPE {
+			// The reader is gone, wait for the next one.
+			syscall.Close(fifo.fd)
+			fifo.fd = -1
+		}
+		if err != nil {
+			return
+		}
+		data = data[n:]
+	}
+}
+
+// Close removes the named pipe. Readers see the end of the output.
+func (fifo *fifoOutput) Close() error {
+	fifo.mutex.Lock()
+	defer fifo.mutex.Unlock()
+
+	if fifo.fd >= 0 {
+		syscall.Close(fifo.fd)
+		fifo.fd = -1
+	}
+	return os.Remove(fifo.path)
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFifoOutput(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "fifo")
+	fifo, err := newFifoOutput(dir, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fifo.path != filepath.Join(dir, "abc.fifo") {
+		t.Errorf("unexpected path %s", fifo.path)
+	}
+	if info, err := os.Stat(fifo.path); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("named pipe was not created: %v", err)
+	}
+
+	// Without a reader, output is dropped instead of blocking the session.
+	fifo.write([]byte("dropped"))
+
+	reader, err := os.OpenFile(fifo.path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	fifo.write([]byte("copied"))
+	fifo.Close()
+
+	if _, err := os.Stat(fifo.path); !os.IsNotExist(err) {
+		t.Errorf("named pipe was not removed: %v", err)
+	}
+	output, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "copied" {
+		t.Errorf("reader got %q", output)
+	}
+}
+
+func TestFifoOutputReaderGone(t *testing.T) {
+	fifo, err := newFifoOutput(t.TempDir(), "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fifo.Close()
+
+	reader, err := os.OpenFile(fifo.path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fifo.write([]byte("first"))
+	reader.Close()
+	// Writing to the pipe without reader fails with EPIPE, not SIGPIPE, in Go.
+	fifo.write([]byte("lost"))
+	if fifo.fd != -1 {
+		t.Error("pipe was kept open after the reader left")
+	}
+
+	reader, err = os.OpenFile(fifo.path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	fifo.write([]byte("second"))
+	buf := make([]byte, 64)
+	if n, _ := reader.Read(buf); string(buf[:n]) != "second" {
+		t.Errorf("next reader got %q", buf[:n])
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newHandshakeServer serves websocket requests with handshake only, closing accepted connections.
+func newHandshakeServer(app *App) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, _, ok := app.handshake(w, r); ok {
+			conn.Close()
+		}
+	}))
+}
+
+func TestHandshakePendingUpgradesSaturation(t *testing.T) {
+	options := testOptions()
+	options.MaxPendingUpgrades = 1
+	app := newTestApp(t, options)
+	app.handshakeTimeout = 200 * time.Millisecond
+	server := newHandshakeServer(app)
+	defer server.Close()
+
+	// A client which never sends the init message holds the only slot...
+	idle, _, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while saturated, got %v", err)
+	}
+
+	// ...until it times out.
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := idle.ReadMessage(); err == nil {
+		t.Fatal("idle connection was not closed")
+	}
+
+	// The slot is released right after the server closed the connection.
+	deadline := time.Now().Add(time.Second)
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("slot was not released after the handshake timed out: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandshakeInitMessageSize(t *testing.T) {
+	options := testOptions()
+	options.MaxInitMessageSize = 64
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{Arguments: strings.Repeat("x", 64)})
+	defer conn.Close()
+	if code := closeCode(waitClosed(t, conn)); code != websocket.CloseMessageTooBig {
+		t.Errorf("expected close code %d for an oversized init message, got %d", websocket.CloseMessageTooBig, code)
+	}
+
+	// The limit applies to the init message only.
+	conn = dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	conn.WriteMessage(websocket.TextMessage, []byte("1"+strings.Repeat("x", 128)))
+	readMessage(t, conn, Pong)
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type HealthStatus struct {
+	Status      string
+	Version     string
+	Uptime      int64 // seconds
+	Connections int64
+}
+
+// handleHealthCheck reports the server status for readiness and liveness probes.
+// It fails with 503 once Exit has been called, so that no new traffic
+// is routed to the server while it's draining.
+func (app *App) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	status := HealthStatus{
+		Status:      "ok",
+		Version:     Version,
+		Uptime:      int64(time.Since(app.startTime) / time.Second),
+		Connections: atomic.LoadInt64(app.connections),
+	}
+
+	code := http.StatusOK
+	select {
+	case <-app.quit:
+		status.Status = "draining"
+		code = http.StatusServiceUnavailable
+	default:
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, status)
+}
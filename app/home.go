@@ -0,0 +1,40 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+)
+
+// User names which are safe to be used as a directory name.
+var homeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._@-]*$`)
+
+// userHome returns the home directory of an authenticated user under HomeBaseDir.
+// The directory is created on first use and owned by the user running the command.
+func (app *App) userHome(user string, credential *syscall.Credential) (string, error) {
+	if user == "" {
+		return "", errors.New("No authenticated user")
+	}
+	if !homeNamePattern.MatchString(user) {
+		return "", errors.New("Invalid user name for home directory: " + user)
+	}
+
+	home := filepath.Join(ExpandHomeDir(app.options.HomeBaseDir), user)
+	if _, err := os.Stat(home); err == nil {
+		return home, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(home, 0700); err != nil {
+		return "", err
+	}
+	if err := os.Chown(home, int(credential.Uid), int(credential.Gid)); err != nil {
+		return "", err
+	}
+	log.Printf("Created home directory %s for user %s", home, user)
+	return home, nil
+}
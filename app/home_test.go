@@ -0,0 +1,98 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestUserHome(t *testing.T) {
+	options := testOptions()
+	options.HomeBaseDir = t.TempDir()
+	app := newTestApp(t, options)
+
+	for _, user := range []string{"", "..", "../etc", "a/b", ".hidden"} {
+		if _, err := app.userHome(user, app.sessionCredential("")); err == nil {
+			t.Errorf("home directory was given to user %q", user)
+		}
+	}
+
+	home, err := app.userHome("alice", app.sessionCredential(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home != filepath.Join(options.HomeBaseDir, "alice") {
+		t.Errorf("unexpected home directory %s", home)
+	}
+	info, err := os.Stat(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0700 {
+		t.Errorf("unexpected home directory mode %v", info.Mode())
+	}
+
+	// An existing directory is reused as it is.
+	if err := ioutil.WriteFile(filepath.Join(home, "notes"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := app.userHome("alice", app.sessionCredential("")); err != nil || again != home {
+		t.Errorf("existing home directory was not reused: %s, %v", again, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "notes")); err != nil {
+		t.Errorf("existing home directory was modified: %v", err)
+	}
+}
+
+func TestPerUserHomeSession(t *testing.T) {
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	options.PerUserHome = true
+	options.HomeBaseDir = t.TempDir()
+	app := newTestCommandApp(t, []string{"sh", "-c", "echo home=$HOME"}, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	header := http.Header{}
+	request := &http.Request{Header: header}
+	request.SetBasicAuth("alice", "secret")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(server), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	message, _ := json.Marshal(InitMessage{AuthToken: fetchAuthToken(t, app, "alice", "secret")})
+	conn.WriteMessage(websocket.TextMessage, message)
+
+	output := readOutput(t, conn, "\n")
+	if expected := "home=" + filepath.Join(options.HomeBaseDir, "alice"); !strings.Contains(output, expected) {
+		t.Errorf("output %q does not contain %q", output, expected)
+	}
+}
+
+func TestCheckConfigPerUserHome(t *testing.T) {
+	options := testOptions()
+	options.PerUserHome = true
+	options.HomeBaseDir = "/home/gotty"
+	if err := CheckConfig(options); err == nil {
+		t.Error("per user home was accepted without basic authentication")
+	}
+
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("valid configuration was rejected: %v", err)
+	}
+
+	options.HomeBaseDir = ""
+	if err := CheckConfig(options); err == nil {
+		t.Error("per user home was accepted without a base directory")
+	}
+}
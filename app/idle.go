@@ -0,0 +1,47 @@
+package app
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// goIdleTimeout closes the session when the client sends no input for IdleTimeout,
+// which sends the close signal to the command. Pings don't count as input,
+// so that abandoned browser tabs don't keep sessions alive.
+func (context *clientContext) goIdleTimeout() {
+	timeout := time.Duration(context.app.options.IdleTimeout) * time.Second
+	context.touchInput()
+
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+			case <-context.done:
+				return
+			}
+
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(&context.lastInput)))
+			if idle < timeout {
+				timer.Reset(timeout - idle)
+				continue
+			}
+
+			log.Printf("Closing session of %s, idle for %v", context.request.RemoteAddr, idle.Round(time.Second))
+			context.writeMutex.Lock()
+			context.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Idle timeout"), time.Now().Add(time.Second))
+			context.writeMutex.Unlock()
+			context.connection.Close()
+			return
+		}
+	}()
+}
+
+func (context *clientContext) touchInput() {
+	atomic.StoreInt64(&context.lastInput, time.Now().UnixNano())
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"log"
+	"net"
+	"net/http"
+)
+
+// wrapIPFilter refuses requests of clients in DenyIPs, or not in AllowIPs when it's given,
+// before they reach anything else such as basic authentication.
+func (app *App) wrapIPFilter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := app.clientIP(r)
+		if !app.ipAllowed(ip) {
+			log.Printf("Denied request from %s", app.clientAddr(r))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ipAllowed checks the client IP against DenyIPs then AllowIPs.
+// Clients whose IP can't be determined are allowed only without any list.
+func (app *App) ipAllowed(ip net.IP) bool {
+	if ip == nil {
+		return len(app.allowIPs) == 0 && len(app.denyIPs) == 0
+	}
+	if containsIP(app.denyIPs, ip) {
+		return false
+	}
+	return len(app.allowIPs) == 0 || containsIP(app.allowIPs, ip)
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Events waiting to be created in the API server, more are dropped.
+const k8sEventsQueueSize = 64
+
+// Files mounted into pods for the service account.
+var k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// k8sEvents reports session events as Kubernetes Events of the pod gotty runs in,
+// so that terminal access shows up in `kubectl get events`.
+// It talks to the API server directly with the service account of the pod,
+// the way the in-cluster config of client-go does. Creating an Event is a single
+// POST, which doesn't justify vendoring client-go and apimachinery, whose
+// dependencies outnumber the rest of the vendor directory many times over.
+// Events are created in order by a single goroutine. When the API server is slow
+// or unavailable, events are dropped once the queue is full rather than piling up.
+type k8sEvents struct {
+	endpoint  string
+	namespace string
+	pod       string
+	client    *http.Client
+	queue     chan SessionEvent
+
+	// Use atomic operations.
+	dropped int64
+}
+
+// newK8sEvents fails when gotty is not running in a Kubernetes pod.
+func newK8sEvents() (*k8sEvents, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("Not running in a Kubernetes cluster")
+	}
+
+	namespace, err := ioutil.ReadFile(k8sServiceAccountDir + "/namespace")
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := ioutil.ReadFile(k8sServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("Could not parse the service account CA certificate")
+	}
+
+	// The pod name is the hostname unless given explicitly, e.g. by the downward API.
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		if pod, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &k8sEvents{
+		endpoint:  "https://" + net.JoinHostPort(host, port),
+		namespace: strings.TrimSpace(string(namespace)),
+		pod:       pod,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: caCertPool}},
+		},
+		queue: make(chan SessionEvent, k8sEventsQueueSize),
+	}, nil
+}
+
+type k8sObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+type k8sEvent struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		GenerateName string `json:"generateName"`
+		Namespace    string `json:"namespace"`
+	} `json:"metadata"`
+	InvolvedObject k8sObjectReference `json:"involvedObject"`
+	Reason         string             `json:"reason"`
+	Message        string             `json:"message"`
+	Type           string             `json:"type"`
+	Source         struct {
+		Component string `json:"component"`
+		Host      string `json:"host,omitempty"`
+	} `json:"source"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+	Count          int       `json:"count"`
+}
+
+func (k8s *k8sEvents) newEvent(event SessionEvent) k8sEvent {
+	e := k8sEvent{
+		APIVersion: "v1",
+		Kind:       "Event",
+		InvolvedObject: k8sObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  k8s.namespace,
+			Name:       k8s.pod,
+		},
+		Type:           "Normal",
+		FirstTimestamp: event.Time,
+		LastTimestamp:  event.Time,
+		Count:          1,
+	}
+	e.Metadata.GenerateName = k8s.pod + ".gotty-"
+	e.Metadata.Namespace = k8s.namespace
+	e.Source.Component = "gotty"
+	e.Source.Host = k8s.pod
+
+	client := event.RemoteAddr
+	if event.User != "" {
+		client = event.User + "@" + client
+	}
+	switch event.Event {
+	case "connect":
+		e.Reason = "SessionStarted"
+		e.Message = fmt.Sprintf("Session %s started for %s: %s", event.SessionID, client, strings.Join(event.Command, " "))
+	default:
+		e.Reason = "SessionStopped"
+		e.Message = fmt.Sprintf("Session %s stopped for %s after %s", event.SessionID, client,
+			time.Duration(event.Duration*float64(time.Second)).Round(time.Second))
+	}
+	return e
+}
+
+func (k8s *k8sEvents) post(event SessionEvent) error {
+	// The token is read every time since projected tokens are rotated.
+	token, err := ioutil.ReadFile(k8sServiceAccountDir + "/token")
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(k8s.newEvent(event))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, k8s.endpoint+"/api/v1/namespaces/"+k8s.namespace+"/events", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := k8s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("Unexpected status code: " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+func (k8s *k8sEvents) enqueue(event SessionEvent) {
+	select {
+	case k8s.queue <- event:
+	default:
+		dropped := atomic.AddInt64(&k8s.dropped, 1)
+		log.Printf("Kubernetes event queue is full, dropped %s event (%d dropped in total)", event.Event, dropped)
+	}
+}
+
+func (k8s *k8sEvents) goDeliver(quit <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case event := <-k8s.queue:
+				if err := k8s.post(event); err != nil {
+					log.Printf("Failed to create Kubernetes event: %v", err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestK8sEventMessages(t *testing.T) {
+	k8s := &k8sEvents{namespace: "tools", pod: "gotty-7d4b9"}
+	now := time.Now()
+
+	started := k8s.newEvent(SessionEvent{Event: "connect", Time: now, SessionID: "abc", RemoteAddr: "192.0.2.1:1234", User: "alice", Command: []string{"top", "-b"}})
+	if started.Reason != "SessionStarted" || started.Message != "Session abc started for alice@192.0.2.1:1234: top -b" {
+		t.Errorf("unexpected start event %s: %s", started.Reason, started.Message)
+	}
+	if started.InvolvedObject.Kind != "Pod" || started.InvolvedObject.Name != "gotty-7d4b9" || started.Metadata.Namespace != "tools" {
+		t.Errorf("unexpected object of the event %+v", started.InvolvedObject)
+	}
+
+	stopped := k8s.newEvent(SessionEvent{Event: "disconnect", Time: now, SessionID: "abc", RemoteAddr: "192.0.2.1:1234", Duration: 61.4})
+	if stopped.Reason != "SessionStopped" || stopped.Message != "Session abc stopped for 192.0.2.1:1234 after 1m1s" {
+		t.Errorf("unexpected stop event %s: %s", stopped.Reason, stopped.Message)
+	}
+}
+
+func TestK8sEventsOutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := newK8sEvents(); err == nil {
+		t.Error("Kubernetes events were enabled outside a cluster")
+	}
+}
+
+// The TLS server stands in for the API server, like the fake clientset of client-go would.
+func TestK8sEventsPost(t *testing.T) {
+	received := make(chan k8sEvent, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/tools/events" || r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var event k8sEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	defer func(saved string) { k8sServiceAccountDir = saved }(k8sServiceAccountDir)
+	k8sServiceAccountDir = dir
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	for name, content := range map[string]string{"namespace": "tools\n", "token": "s3cr3t\n", "ca.crt": string(caCert)} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "https://"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", host)
+	t.Setenv("KUBERNETES_SERVICE_PORT", port)
+	t.Setenv("POD_NAME", "gotty-7d4b9")
+
+	k8s, err := newK8sEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k8s.post(SessionEvent{Event: "connect", SessionID: "abc", Command: []string{"top"}}); err != nil {
+		t.Fatal(err)
+	}
+	if event := <-received; event.Reason != "SessionStarted" || event.InvolvedObject.Name != "gotty-7d4b9" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	// Queued events are created in the background.
+	quit := make(chan struct{})
+	defer close(quit)
+	k8s.goDeliver(quit)
+	k8s.enqueue(SessionEvent{Event: "disconnect", SessionID: "abc"})
+	select {
+	case event := <-received:
+		if event.Reason != "SessionStopped" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("queued event was not created")
+	}
+}
+
+func TestK8sEventsQueueOverflow(t *testing.T) {
+	// Without a worker, as when the API server doesn't respond.
+	k8s := &k8sEvents{queue: make(chan SessionEvent, k8sEventsQueueSize)}
+	captureLog(func() {
+		for i := 0; i < k8sEventsQueueSize+1; i++ {
+			k8s.enqueue(SessionEvent{Event: "connect"})
+		}
+	})
+	if k8s.dropped != 1 || len(k8s.queue) != k8sEventsQueueSize {
+		t.Errorf("expected 1 dropped event and a full queue, got %d dropped and %d queued", k8s.dropped, len(k8s.queue))
+	}
+}
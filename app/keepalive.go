@@ -0,0 +1,47 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// goPing sends websocket ping frames every WSPingInterval to keep idle connections
+// open through proxies and load balancers. The connection is closed when
+// no pong arrives within WSPongTimeout after a ping, which ends the session.
+// It must be called before processReceive starts reading.
+func (context *clientContext) goPing() {
+	interval := time.Duration(context.app.options.WSPingInterval) * time.Second
+	wait := interval + time.Duration(context.app.options.WSPongTimeout)*time.Second
+	conn := context.connection
+
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-context.done:
+				return
+			}
+
+			if err := context.ping(); err != nil {
+				log.Printf("Failed to send ping to %s: %v", context.request.RemoteAddr, err)
+				return
+			}
+		}
+	}()
+}
+
+func (context *clientContext) ping() error {
+	context.writeMutex.Lock()
+	defer context.writeMutex.Unlock()
+	return context.connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"log"
+)
+
+// RSA key sizes of equivalent strength to elliptic curve keys, from NIST SP 800-57
+var ecEquivalentBits = map[int]int{
+	224: 2048,
+	256: 3072,
+	384: 7680,
+	521: 15360,
+}
+
+type peerVerifier func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+
+// verifyPeerWith runs the verifiers in turn and fails with the first error.
+func verifyPeerWith(verifiers []peerVerifier) peerVerifier {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, verify := range verifiers {
+			if err := verify(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// keyBits returns the strength of a public key as the size of an RSA key,
+// or 0 for unknown key types.
+func keyBits(key interface{}) int {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return ecEquivalentBits[key.Curve.Params().BitSize]
+	case ed25519.PublicKey:
+		return 3072
+	}
+	return 0
+}
+
+// minKeyBitsVerifier rejects client certificates whose key is weaker than an RSA key of min bits.
+func minKeyBitsVerifier(min int) peerVerifier {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			if len(chain) == 0 {
+				continue
+			}
+			cert := chain[0]
+			if bits := keyBits(cert.PublicKey); bits < min {
+				log.Printf("Rejected client certificate %q with a weak %s key (%d bits)", cert.Subject.CommonName, cert.PublicKeyAlgorithm, bits)
+				return fmt.Errorf("Client certificate key is too weak: %d bits, at least %d are required", bits, min)
+			}
+		}
+		return nil
+	}
+}
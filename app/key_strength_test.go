@@ -0,0 +1,205 @@
+package app
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyBits(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edKey, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name     string
+		key      interface{}
+		expected int
+	}{
+		{"RSA", &rsaKey.PublicKey, 1024},
+		{"P-256", &p256Key.PublicKey, 3072},
+		{"P-384", &p384Key.PublicKey, 7680},
+		{"Ed25519", edKey, 3072},
+		{"unknown", "not a key", 0},
+	}
+	for _, test := range tests {
+		if bits := keyBits(test.key); bits != test.expected {
+			t.Errorf("%s: expected %d bits, got %d", test.name, test.expected, bits)
+		}
+	}
+}
+
+func TestMinKeyBitsVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	weak := &x509.Certificate{Subject: pkix.Name{CommonName: "weak"}, PublicKey: &rsaKey.PublicKey}
+	strong := &x509.Certificate{Subject: pkix.Name{CommonName: "strong"}, PublicKey: &ecKey.PublicKey}
+
+	tests := []struct {
+		min   int
+		leaf  *x509.Certificate
+		valid bool
+	}{
+		{2048, strong, true},
+		{2048, weak, false},
+		{1024, weak, true},
+		{4096, strong, false},
+	}
+	for _, test := range tests {
+		verify := minKeyBitsVerifier(test.min)
+		var err error
+		logged := captureLog(func() {
+			// Only the leaf is checked, the CA key isn't the client's.
+			err = verify(nil, [][]*x509.Certificate{{test.leaf, weak}})
+		})
+		if (err == nil) != test.valid {
+			t.Errorf("%s key with minimum %d: unexpected error %v", test.leaf.Subject.CommonName, test.min, err)
+		}
+		if !test.valid && !strings.Contains(logged, "Rejected client certificate \""+test.leaf.Subject.CommonName+"\"") {
+			t.Errorf("Expected the rejection to be logged, got %q", logged)
+		}
+	}
+}
+
+func TestVerifyPeerWith(t *testing.T) {
+	var called []string
+	verifier := func(name string, err error) peerVerifier {
+		return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			called = append(called, name)
+			return err
+		}
+	}
+
+	verify := verifyPeerWith([]peerVerifier{verifier("crl", nil), verifier("key", errors.New("weak")), verifier("last", nil)})
+	if err := verify(nil, nil); err == nil || err.Error() != "weak" {
+		t.Errorf("Expected the first error, got %v", err)
+	}
+	if strings.Join(called, ",") != "crl,key" {
+		t.Errorf("Expected verification to stop at the first error, called %v", called)
+	}
+}
+
+func TestCheckConfigMinClientKeyBits(t *testing.T) {
+	options := testOptions()
+	options.MinClientKeyBits = 2048
+	if err := CheckConfig(options); err == nil {
+		t.Error("Expected an error without TLS client authentication")
+	}
+	options.EnableTLS = true
+	options.EnableTLSClientAuth = true
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// issueTestClientKeyPair issues a client certificate for key, signed by the CA.
+func issueTestClientKeyPair(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, key crypto.Signer) tls.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("client %d", serial)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMinClientKeyBitsHandshake(t *testing.T) {
+	ca, caKey := newTestCA(t, "Test CA")
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strongKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: big.NewInt(4), RevocationTime: time.Now()}},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	options := testOptions()
+	options.EnableTLS = true
+	options.TLSCrtFile = filepath.Join(dir, "gotty.crt")
+	options.TLSKeyFile = filepath.Join(dir, "gotty.key")
+	options.EnableTLSClientAuth = true
+	options.TLSCACrtFile = filepath.Join(dir, "ca.crt")
+	options.TLSCRLFile = filepath.Join(dir, "crl.pem")
+	options.MinClientKeyBits = 2048
+	if err := generateSelfSignedCert(options.TLSCrtFile, options.TLSKeyFile); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(options.TLSCACrtFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+	ioutil.WriteFile(options.TLSCRLFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0600)
+
+	app := newTestApp(t, options)
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server, err := app.makeServer("127.0.0.1:0", &handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTLS(listener, options.TLSCrtFile, options.TLSKeyFile)
+	defer server.Close()
+
+	// The same checks apply with certificates obtained by EnableAutoCert.
+	autoCert := serveAutoCert(t, app, handler)
+
+	tests := []struct {
+		name  string
+		cert  tls.Certificate
+		valid bool
+	}{
+		{"RSA-1024", issueTestClientKeyPair(t, ca, caKey, 2, weakKey), false},
+		{"P-256", issueTestClientKeyPair(t, ca, caKey, 3, strongKey), true},
+		// The key check is chained with the CRL check.
+		{"revoked P-256", issueTestClientKeyPair(t, ca, caKey, 4, strongKey), false},
+	}
+	for _, address := range []string{listener.Addr().String(), autoCert.Addr().String()} {
+		for _, test := range tests {
+			var err error
+			logged := captureLog(func() { err = getWithClientCert(address, test.cert) })
+			if (err == nil) != test.valid {
+				t.Errorf("%s client certificate on %s: unexpected result %v", test.name, address, err)
+			}
+			if weak := strings.Contains(logged, "with a weak"); weak != (test.name == "RSA-1024") {
+				t.Errorf("%s client certificate on %s: unexpected log %q", test.name, address, logged)
+			}
+		}
+	}
+}
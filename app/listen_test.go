@@ -0,0 +1,52 @@
+package app
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestListenRetry(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := busy.Addr().String()
+
+	options := testOptions()
+	options.ListenRetries = 0
+	options.ListenRetryDelay = 50
+	app := newTestApp(t, options)
+	if _, err := app.listen("tcp", address); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("expected EADDRINUSE without retries, got %v", err)
+	}
+
+	// The address is released while retrying.
+	options.ListenRetries = 20
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		busy.Close()
+	}()
+	listener, err := app.listen("tcp", address)
+	if err != nil {
+		t.Fatalf("failed to listen after the address was released: %v", err)
+	}
+	listener.Close()
+}
+
+func TestListenDoesNotRetryOtherErrors(t *testing.T) {
+	options := testOptions()
+	options.ListenRetries = 20
+	options.ListenRetryDelay = 1000
+	app := newTestApp(t, options)
+
+	start := time.Now()
+	if _, err := app.listen("tcp", "127.0.0.1:99999"); err == nil {
+		t.Fatal("listened on an invalid port")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("retried for %v", elapsed)
+	}
+}
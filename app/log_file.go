@@ -0,0 +1,125 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingFile is a log writer which renames the file to "<path>.1" once it
+// grows over maxSize, shifting older backups to "<path>.2", "<path>.3" and so on.
+// Backups beyond maxBackups or older than maxAge are removed (0 to keep all).
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+
+	mutex *sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int, maxAge time.Duration) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		maxAge:     maxAge,
+		mutex:      &sync.Mutex{},
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	backups := f.backups()
+	for i := len(backups) - 1; i >= 0; i-- {
+		index := backups[i]
+		name := f.backupName(index)
+		if f.maxBackups > 0 && index >= f.maxBackups {
+			os.Remove(name)
+			continue
+		}
+		if f.maxAge > 0 {
+			if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > f.maxAge {
+				os.Remove(name)
+				continue
+			}
+		}
+		if err := os.Rename(name, f.backupName(index+1)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(f.path, f.backupName(1)); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+// backups returns the indexes of existing backup files in ascending order.
+func (f *rotatingFile) backups() []int {
+	matches, _ := filepath.Glob(f.path + ".*")
+	indexes := make([]int, 0, len(matches))
+	for _, match := range matches {
+		index, err := strconv.Atoi(strings.TrimPrefix(match, f.path+"."))
+		if err == nil && index > 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func (f *rotatingFile) backupName(index int) string {
+	return fmt.Sprintf("%s.%d", f.path, index)
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gotty.log")
+	f, err := openRotatingFile(path, 10, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if content := readFile(t, path); content != "fourth\n" {
+		t.Errorf("unexpected current file %q", content)
+	}
+	if content := readFile(t, path+".1"); content != "third\n" {
+		t.Errorf("unexpected first backup %q", content)
+	}
+	if content := readFile(t, path+".2"); content != "second\n" {
+		t.Errorf("unexpected second backup %q", content)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup beyond the maximum was kept: %v", err)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gotty.log")
+	if err := ioutil.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := openRotatingFile(path, 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write([]byte("new\n"))
+	if content := readFile(t, path); content != "old\nnew\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+	// The size of the existing file counts.
+	f.Write([]byte("rotated\n"))
+	if content := readFile(t, path+".1"); content != "old\nnew\n" {
+		t.Errorf("unexpected backup %q", content)
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gotty.log")
+	f, err := openRotatingFile(path, 4, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("aaaa"))
+	f.Write([]byte("bbbb"))
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path+".1", old, old)
+	f.Write([]byte("cccc"))
+
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("expired backup was kept: %v", err)
+	}
+	if content := readFile(t, path+".1"); content != "bbbb" {
+		t.Errorf("unexpected backup %q", content)
+	}
+}
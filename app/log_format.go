@@ -0,0 +1,39 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Fields of a structured log record
+type logFields map[string]interface{}
+
+// logRecord logs fields as a JSON object on one line when LogFormat is json,
+// with the formatted message in msg. Otherwise it logs the message only, as log.Printf does.
+func (app *App) logRecord(fields logFields, format string, v ...interface{}) {
+	app.logRecordTo(log.Default(), fields, format, v...)
+}
+
+func (app *App) logRecordTo(logger *log.Logger, fields logFields, format string, v ...interface{}) {
+	if app.options.LogFormat != "json" {
+		logger.Printf(format, v...)
+		return
+	}
+
+	record := make(logFields, len(fields)+2)
+	for key, value := range fields {
+		record[key] = value
+	}
+	record["time"] = time.Now().Format(time.RFC3339Nano)
+	record["msg"] = fmt.Sprintf(format, v...)
+	line, err := json.Marshal(record)
+	if err != nil {
+		logger.Printf(format, v...)
+		return
+	}
+	// Records carry their own time, skip the prefix of the logger
+	// while still following its output.
+	log.New(logger.Writer(), "", 0).Println(string(line))
+}
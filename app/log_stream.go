@@ -0,0 +1,118 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Log lines buffered for each viewer. Lines are dropped for viewers falling behind.
+const logStreamBuffer = 256
+
+// logStream fans out the server log to viewers of the admin log endpoint.
+type logStream struct {
+	maxViewers int
+
+	mutex   *sync.Mutex
+	viewers map[chan []byte]struct{}
+}
+
+func newLogStream(maxViewers int) *logStream {
+	return &logStream{
+		maxViewers: maxViewers,
+		mutex:      &sync.Mutex{},
+		viewers:    make(map[chan []byte]struct{}),
+	}
+}
+
+// Write never blocks the logger. It must not log anything by itself.
+func (stream *logStream) Write(p []byte) (int, error) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	if len(stream.viewers) == 0 {
+		return len(p), nil
+	}
+	line := append([]byte{}, p...)
+	for viewer := range stream.viewers {
+		select {
+		case viewer <- line:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+func (stream *logStream) subscribe() (chan []byte, bool) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	if stream.maxViewers > 0 && len(stream.viewers) >= stream.maxViewers {
+		return nil, false
+	}
+	viewer := make(chan []byte, logStreamBuffer)
+	stream.viewers[viewer] = struct{}{}
+	return viewer, true
+}
+
+func (stream *logStream) unsubscribe(viewer chan []byte) {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	delete(stream.viewers, viewer)
+}
+
+// handleAdminLog streams new lines of the server log as Server-Sent Events.
+// Each viewer receives up to LogStreamRateLimit log writes per second when it is set,
+// the rest are dropped and counted in a notice sent with the next write let through.
+func (app *App) handleAdminLog(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	viewer, ok := app.logStream.subscribe()
+	if !ok {
+		http.Error(w, "Too many log viewers", http.StatusTooManyRequests)
+		return
+	}
+	defer app.logStream.unsubscribe(viewer)
+
+	var limiter *rateLimiter
+	if app.options.LogStreamRateLimit > 0 {
+		limiter = newRateLimiter(app.options.LogStreamRateLimit, app.options.LogStreamRateBurst)
+	}
+	dropped := 0
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-viewer:
+			if limiter != nil {
+				if _, ok := limiter.reserve(time.Now(), 0); !ok {
+					dropped++
+					continue
+				}
+			}
+			if dropped > 0 {
+				fmt.Fprintf(w, "data: %d log lines dropped, exceeding %d per second\n\n", dropped, app.options.LogStreamRateLimit)
+				dropped = 0
+			}
+			for _, data := range bytes.Split(bytes.TrimRight(line, "\n"), []byte("\n")) {
+				fmt.Fprintf(w, "data: %s\n", data)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-app.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminLogStream(t *testing.T) {
+	options := testOptions()
+	options.LogStreamMaxViewers = 1
+	app := newTestApp(t, options)
+	server := httptest.NewServer(http.HandlerFunc(app.handleAdminLog))
+	defer server.Close()
+
+	// Subscribed once the headers arrive.
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+
+	second, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second.Body.Close()
+	if second.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 beyond the maximum viewers, got %d", second.StatusCode)
+	}
+
+	app.logStream.Write([]byte("2026/01/01 first line\nsecond line\n"))
+	reader := bufio.NewReader(resp.Body)
+	for _, expected := range []string{"data: 2026/01/01 first line\n", "data: second line\n", "\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != expected {
+			t.Errorf("expected %q, got %q", expected, line)
+		}
+	}
+}
+
+func TestAdminLogStreamRateLimit(t *testing.T) {
+	options := testOptions()
+	options.LogStreamRateLimit = 10
+	options.LogStreamRateBurst = 2
+	app := newTestApp(t, options)
+	server := httptest.NewServer(http.HandlerFunc(app.handleAdminLog))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		app.logStream.Write([]byte(line))
+	}
+	// Lets a token come back.
+	time.Sleep(200 * time.Millisecond)
+	app.logStream.Write([]byte("later\n"))
+
+	reader := bufio.NewReader(resp.Body)
+	for _, expected := range []string{
+		"data: one\n", "\n",
+		"data: two\n", "\n",
+		"data: 3 log lines dropped, exceeding 10 per second\n", "\n",
+		"data: later\n", "\n",
+	} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != expected {
+			t.Errorf("expected %q, got %q", expected, line)
+		}
+	}
+}
+
+func TestLogStreamDropsForSlowViewers(t *testing.T) {
+	stream := newLogStream(0)
+	viewer, ok := stream.subscribe()
+	if !ok {
+		t.Fatal("failed to subscribe")
+	}
+	for i := 0; i < logStreamBuffer+10; i++ {
+		if n, err := stream.Write([]byte("line\n")); n != 5 || err != nil {
+			t.Fatalf("write returned %d, %v", n, err)
+		}
+	}
+	if len(viewer) != logStreamBuffer {
+		t.Errorf("expected %d buffered lines, got %d", logStreamBuffer, len(viewer))
+	}
+
+	stream.unsubscribe(viewer)
+	stream.Write([]byte("after\n"))
+	if len(viewer) != logStreamBuffer {
+		t.Error("line was sent to an unsubscribed viewer")
+	}
+}
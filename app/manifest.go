@@ -0,0 +1,57 @@
+package app
+
+import (
+	"errors"
+)
+
+// SessionManifest describes a session to the client in a single message,
+// sent right after the capabilities.
+type SessionManifest struct {
+	SessionID   string
+	Command     string
+	Arguments   []string
+	PermitWrite bool
+	Reconnect   int // seconds, 0 when reconnection is disabled
+	Preferences map[string]interface{}
+}
+
+// Policies for the command arguments in the manifest, given by ManifestArguments.
+const (
+	manifestArgumentsFull     = "full"     // as they are
+	manifestArgumentsRedacted = "redacted" // replaced by "***", keeping their number
+	manifestArgumentsNone     = "none"     // omitted
+)
+
+func checkManifestArguments(policy string) error {
+	switch policy {
+	case manifestArgumentsFull, manifestArgumentsRedacted, manifestArgumentsNone:
+		return nil
+	}
+	return errors.New("Manifest arguments must be one of full, redacted or none")
+}
+
+func (context *clientContext) manifest(prefs map[string]interface{}) SessionManifest {
+	args := context.command.Args[1:]
+	switch context.app.options.ManifestArguments {
+	case manifestArgumentsRedacted:
+		redacted := make([]string, len(args))
+		for i := range redacted {
+			redacted[i] = "***"
+		}
+		args = redacted
+	case manifestArgumentsNone:
+		args = []string{}
+	}
+
+	manifest := SessionManifest{
+		SessionID:   context.id,
+		Command:     context.command.Args[0],
+		Arguments:   args,
+		PermitWrite: context.writable(),
+		Preferences: prefs,
+	}
+	if context.app.options.EnableReconnect {
+		manifest.Reconnect = context.app.options.ReconnectTime
+	}
+	return manifest
+}
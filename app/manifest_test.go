@@ -0,0 +1,50 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestManifestMessage(t *testing.T) {
+	tests := []struct {
+		policy    string
+		arguments []string
+	}{
+		{"full", []string{"-u", "-"}},
+		{"redacted", []string{"***", "***"}},
+		{"none", []string{}},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.PermitWrite = true
+		options.EnableReconnect = true
+		options.ReconnectTime = 7
+		options.ManifestArguments = test.policy
+		app := newTestCommandApp(t, []string{"cat", "-u", "-"}, options)
+		server := startTestServer(app)
+
+		conn := dialTestSession(t, server, InitMessage{})
+		var manifest SessionManifest
+		if err := json.Unmarshal([]byte(readMessage(t, conn, SetManifest)), &manifest); err != nil {
+			t.Fatal(err)
+		}
+		id := waitSessions(t, app, 1)[0].ID
+		if manifest.SessionID != id || manifest.Command != "cat" || !manifest.PermitWrite || manifest.Reconnect != 7 {
+			t.Errorf("%s: unexpected manifest %+v", test.policy, manifest)
+		}
+		if !reflect.DeepEqual(manifest.Arguments, test.arguments) {
+			t.Errorf("%s: expected arguments %q, got %q", test.policy, test.arguments, manifest.Arguments)
+		}
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestCheckManifestArguments(t *testing.T) {
+	for policy, ok := range map[string]bool{"full": true, "redacted": true, "none": true, "": false, "some": false} {
+		if err := checkManifestArguments(policy); (err == nil) != ok {
+			t.Errorf("%q: unexpected result %v", policy, err)
+		}
+	}
+}
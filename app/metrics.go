@@ -0,0 +1,133 @@
+package app
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Upper bounds of the histogram buckets in seconds, unless SessionDurationBuckets
+// and RequestDurationBuckets are given.
+var (
+	sessionDurationBuckets = []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 24 * 3600}
+	requestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// metrics are exported in the Prometheus text format.
+// Each App has its own registry so that several of them can live in a process.
+type metrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	sessionsStarted prometheus.Counter
+	execRequests    *prometheus.CounterVec
+
+	sessionDuration prometheus.Histogram
+	requestDuration prometheus.Histogram
+}
+
+func newMetrics(sessionBuckets []float64, requestBuckets []float64, connections *int64) *metrics {
+	if len(sessionBuckets) == 0 {
+		sessionBuckets = sessionDurationBuckets
+	}
+	if len(requestBuckets) == 0 {
+		requestBuckets = requestDurationBuckets
+	}
+
+	registry := prometheus.NewRegistry()
+	m := &metrics{
+		registry: registry,
+		handler:  promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+
+		sessionsStarted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "gotty_sessions_started_total",
+			Help: "Total number of started sessions.",
+		}),
+		execRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gotty_remote_exec_requests_total",
+			Help: "Total number of remote exec requests.",
+		}, []string{"result"}),
+
+		sessionDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gotty_session_duration_seconds",
+			Help:    "Duration of finished sessions.",
+			Buckets: sessionBuckets,
+		}),
+		requestDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gotty_http_request_duration_seconds",
+			Help:    "Duration of HTTP requests, excluding websocket sessions.",
+			Buckets: requestBuckets,
+		}),
+	}
+	// Export both results from the start, not only after the first request of each.
+	m.execRequests.WithLabelValues("success")
+	m.execRequests.WithLabelValues("failure")
+
+	registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gotty_websocket_connections",
+			Help: "Current number of websocket connections.",
+		}, func() float64 {
+			return float64(atomic.LoadInt64(connections))
+		}),
+		m.sessionsStarted,
+		m.execRequests,
+		m.sessionDuration,
+		m.requestDuration,
+	)
+	return m
+}
+
+// watchWebhook exports the queue of the webhook.
+func (m *metrics) watchWebhook(hook *webhook) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gotty_webhook_queue_depth",
+			Help: "Number of session events waiting to be delivered to the webhook.",
+		}, func() float64 {
+			return float64(hook.depth())
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "gotty_webhook_events_dropped_total",
+			Help: "Total number of session events dropped because the webhook queue was full.",
+		}, func() float64 {
+			return float64(atomic.LoadInt64(&hook.dropped))
+		}),
+	)
+}
+
+func (m *metrics) sessionStarted() {
+	m.sessionsStarted.Inc()
+}
+
+func (m *metrics) sessionFinished(duration time.Duration) {
+	m.sessionDuration.Observe(duration.Seconds())
+}
+
+func (m *metrics) requestFinished(duration time.Duration) {
+	m.requestDuration.Observe(duration.Seconds())
+}
+
+func (m *metrics) execFinished(success bool) {
+	if success {
+		m.execRequests.WithLabelValues("success").Inc()
+	} else {
+		m.execRequests.WithLabelValues("failure").Inc()
+	}
+}
+
+func (app *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	app.metrics.handler.ServeHTTP(w, r)
+}
+
+func increasing(buckets []float64) bool {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// scrapeMetrics parses the metrics exported by app with the parser of Prometheus.
+func scrapeMetrics(t *testing.T, app *App) map[string]*dto.MetricFamily {
+	recorder := httptest.NewRecorder()
+	app.handleMetrics(recorder, httptest.NewRequest("GET", app.options.MetricsPath, nil))
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(recorder.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing metrics: %v", err)
+	}
+	return families
+}
+
+// bucketCounts maps the upper bounds of the only histogram in family to their cumulative counts.
+func bucketCounts(t *testing.T, family *dto.MetricFamily) map[float64]uint64 {
+	if family == nil || len(family.GetMetric()) != 1 {
+		t.Fatalf("Expected a single histogram, got %v", family)
+	}
+	counts := make(map[float64]uint64)
+	for _, bucket := range family.GetMetric()[0].GetHistogram().GetBucket() {
+		counts[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+	}
+	return counts
+}
+
+func TestMetrics(t *testing.T) {
+	options := testOptions()
+	options.EnableMetrics = true
+	options.WebhookURL = "http://127.0.0.1:1/"
+	app := newTestApp(t, options)
+
+	app.metrics.sessionStarted()
+	app.metrics.execFinished(true)
+	app.metrics.execFinished(true)
+	app.metrics.execFinished(false)
+	atomic.AddInt64(app.connections, 3)
+	defer atomic.AddInt64(app.connections, -3)
+
+	families := scrapeMetrics(t, app)
+	tests := []struct {
+		name   string
+		kind   dto.MetricType
+		values map[string]float64
+	}{
+		{"gotty_websocket_connections", dto.MetricType_GAUGE, map[string]float64{"": 3}},
+		{"gotty_sessions_started_total", dto.MetricType_COUNTER, map[string]float64{"": 1}},
+		{"gotty_remote_exec_requests_total", dto.MetricType_COUNTER, map[string]float64{"success": 2, "failure": 1}},
+		{"gotty_webhook_queue_depth", dto.MetricType_GAUGE, map[string]float64{"": 0}},
+		{"gotty_webhook_events_dropped_total", dto.MetricType_COUNTER, map[string]float64{"": 0}},
+	}
+	for _, test := range tests {
+		family, ok := families[test.name]
+		if !ok {
+			t.Errorf("Expected %s in metrics", test.name)
+			continue
+		}
+		if family.GetType() != test.kind {
+			t.Errorf("Expected %s to be a %v, got %v", test.name, test.kind, family.GetType())
+		}
+		values := make(map[string]float64)
+		for _, metric := range family.GetMetric() {
+			label := ""
+			for _, pair := range metric.GetLabel() {
+				label = pair.GetValue()
+			}
+			if metric.GetCounter() != nil {
+				values[label] = metric.GetCounter().GetValue()
+			} else {
+				values[label] = metric.GetGauge().GetValue()
+			}
+		}
+		if !reflect.DeepEqual(values, test.values) {
+			t.Errorf("Expected %s to be %v, got %v", test.name, test.values, values)
+		}
+	}
+}
+
+func TestMetricsWithoutWebhook(t *testing.T) {
+	options := testOptions()
+	options.EnableMetrics = true
+	app := newTestApp(t, options)
+
+	families := scrapeMetrics(t, app)
+	if _, ok := families["gotty_webhook_queue_depth"]; ok {
+		t.Errorf("Expected no webhook metrics without a webhook")
+	}
+	// Both results are exported before any remote exec request.
+	if family := families["gotty_remote_exec_requests_total"]; family == nil || len(family.GetMetric()) != 2 {
+		t.Errorf("Expected both exec results, got %v", family)
+	}
+}
+
+func TestRequestDurationBuckets(t *testing.T) {
+	options := testOptions()
+	options.EnableMetrics = true
+	options.RequestDurationBuckets = []float64{0.5, 30}
+	app := newTestApp(t, options)
+
+	tests := []struct {
+		status int
+	}{
+		{http.StatusOK},
+		{http.StatusNotFound},
+		// Websocket sessions last as long as the terminal, so they aren't requests.
+		{http.StatusSwitchingProtocols},
+	}
+	for _, test := range tests {
+		handler := app.wrapLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+		}))
+		captureLog(func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		})
+	}
+
+	families := scrapeMetrics(t, app)
+	counts := bucketCounts(t, families["gotty_http_request_duration_seconds"])
+	expected := map[float64]uint64{0.5: 2, 30: 2, math.Inf(1): 2}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected request buckets %v, got %v", expected, counts)
+	}
+	// Session buckets keep their defaults.
+	if counts := bucketCounts(t, families["gotty_session_duration_seconds"]); len(counts) != len(sessionDurationBuckets)+1 || counts[86400] != 0 {
+		t.Errorf("Expected default session buckets, got %v", counts)
+	}
+}
+
+func TestSessionDurationBuckets(t *testing.T) {
+	options := testOptions()
+	options.EnableMetrics = true
+	options.SessionDurationBuckets = []float64{5}
+	app := newTestApp(t, options)
+	app.metrics.sessionFinished(2 * time.Second)
+	app.metrics.sessionFinished(time.Minute)
+
+	families := scrapeMetrics(t, app)
+	counts := bucketCounts(t, families["gotty_session_duration_seconds"])
+	expected := map[float64]uint64{5: 1, math.Inf(1): 2}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected session buckets %v, got %v", expected, counts)
+	}
+	if counts := bucketCounts(t, families["gotty_http_request_duration_seconds"]); len(counts) != len(requestDurationBuckets)+1 {
+		t.Errorf("Expected default request buckets, got %v", counts)
+	}
+}
+
+func TestCheckConfigDurationBuckets(t *testing.T) {
+	tests := []struct {
+		session []float64
+		request []float64
+		valid   bool
+	}{
+		{nil, nil, true},
+		{[]float64{1, 10}, []float64{0.1, 0.2}, true},
+		{[]float64{10, 1}, nil, false},
+		{nil, []float64{0.1, 0.1}, false},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.SessionDurationBuckets = test.session
+		options.RequestDurationBuckets = test.request
+		err := CheckConfig(options)
+		if (err == nil) != test.valid {
+			t.Errorf("Buckets %v and %v: unexpected error %v", test.session, test.request, err)
+		}
+	}
+}
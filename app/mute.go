@@ -0,0 +1,58 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// sendOutput delivers PTY output to the client unless the client is muted.
+// While muted, output is kept for replay when MuteReplay is enabled.
+func (context *clientContext) sendOutput(data []byte) error {
+	context.muteMutex.Lock()
+	defer context.muteMutex.Unlock()
+
+	if context.muted {
+		if context.app.options.MuteReplay {
+			context.muteBuffer = append(context.muteBuffer, data...)
+			if over := len(context.muteBuffer) - context.app.options.MuteBufferSize; over > 0 {
+				context.muteBuffer = context.muteBuffer[over:]
+			}
+		}
+		return nil
+	}
+
+	return context.writeOutput(data)
+}
+
+func (context *clientContext) writeOutput(data []byte) error {
+	safeMessage := base64.StdEncoding.EncodeToString(data)
+	return context.write(append([]byte{Output}, []byte(safeMessage)...))
+}
+
+func (context *clientContext) isMuted() bool {
+	context.muteMutex.Lock()
+	defer context.muteMutex.Unlock()
+	return context.muted
+}
+
+func (context *clientContext) setMuted(muted bool) error {
+	context.muteMutex.Lock()
+	defer context.muteMutex.Unlock()
+
+	if context.muted == muted {
+		return nil
+	}
+	context.muted = muted
+
+	mutedMessage, _ := json.Marshal(muted)
+	if err := context.write(append([]byte{SetMuted}, mutedMessage...)); err != nil {
+		return err
+	}
+
+	if !muted && len(context.muteBuffer) > 0 {
+		buffered := context.muteBuffer
+		context.muteBuffer = nil
+		return context.writeOutput(buffered)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMutedOutputIsDropped(t *testing.T) {
+	options := testOptions()
+	options.MuteReplay = true
+	options.MuteBufferSize = 8
+	context := &clientContext{
+		app:       newTestApp(t, options),
+		muteMutex: &sync.Mutex{},
+		muted:     true,
+	}
+
+	// Without a connection, writing would panic.
+	context.sendOutput([]byte("0123456789"))
+	context.sendOutput([]byte("abc"))
+	if buffered := string(context.muteBuffer); buffered != "56789abc" {
+		t.Errorf("expected the last 8 bytes to be kept, got %q", buffered)
+	}
+
+	options.MuteReplay = false
+	context.muteBuffer = nil
+	context.sendOutput([]byte("dropped"))
+	if len(context.muteBuffer) != 0 {
+		t.Errorf("output was kept without MuteReplay: %q", context.muteBuffer)
+	}
+}
+
+func TestAdminMute(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.MuteReplay = true
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readMessage(t, conn, SetWritePermit)
+	id := waitSessions(t, app, 1)[0].ID
+
+	if w := postForm(app.handleAdminMute, url.Values{"id": {id}, "mute": {"true"}}); w.Code != http.StatusNoContent {
+		t.Fatalf("admin answered %d", w.Code)
+	}
+	if muted := readMessage(t, conn, SetMuted); muted != "true" {
+		t.Errorf("client was told muted %s", muted)
+	}
+	if !app.Sessions()[0].Muted {
+		t.Error("session is not listed as muted")
+	}
+
+	// The command keeps running, its output is replayed once unmuted.
+	conn.WriteMessage(websocket.TextMessage, []byte("0while muted\n"))
+	conn.WriteMessage(websocket.TextMessage, []byte{Ping})
+	readMessage(t, conn, Pong)
+	postForm(app.handleAdminMute, url.Values{"id": {id}, "mute": {"false"}})
+	if muted := readMessage(t, conn, SetMuted); muted != "false" {
+		t.Errorf("client was told muted %s", muted)
+	}
+	readOutput(t, conn, "while muted")
+
+	if w := postForm(app.handleAdminMute, url.Values{"id": {"missing"}, "mute": {"true"}}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown session, got %d", w.Code)
+	}
+}
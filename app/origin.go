@@ -0,0 +1,53 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// checkOrigin accepts websocket connections from the same origin as the page,
+// or from origins matching one of AllowedOrigins. Patterns can contain wildcards,
+// e.g. https://*.example.com, and a single * accepts any origin.
+// Requests without an Origin header come from non-browser clients and are accepted.
+// When PublicURL is set, only its exact origin is accepted instead.
+func (app *App) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	switch {
+	case app.options.PublicURL != "":
+		if strings.EqualFold(origin, publicOrigin(app.options.PublicURL)) {
+			return true
+		}
+	case origin == "":
+		return true
+	case len(app.options.AllowedOrigins) == 0:
+		u, err := url.Parse(origin)
+		if err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+	default:
+		for _, pattern := range app.options.AllowedOrigins {
+			// path.Match doesn't let * match the slashes of the scheme.
+			if pattern == "*" {
+				return true
+			}
+			if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(origin)); matched {
+				return true
+			}
+		}
+	}
+
+	log.Printf("Warning: rejected websocket connection from %s with origin %q (host %q)", r.RemoteAddr, origin, r.Host)
+	return false
+}
+
+// publicOrigin returns the origin (scheme://host[:port]) of the public URL.
+func publicOrigin(publicURL string) string {
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
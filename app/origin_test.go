@@ -0,0 +1,63 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		publicURL      string
+		allowedOrigins []string
+		origin         string
+		ok             bool
+	}{
+		{"", nil, "", true},
+		{"", nil, "http://gotty.example.com:8080", true},
+		{"", nil, "http://evil.example.com", false},
+		{"", []string{"https://*.example.com"}, "https://term.example.com", true},
+		{"", []string{"https://*.example.com"}, "http://gotty.example.com:8080", false},
+		{"", []string{"*"}, "http://evil.example.com", true},
+		{"https://term.example.com/gotty/", nil, "https://term.example.com", true},
+		{"https://term.example.com/gotty/", nil, "HTTPS://Term.Example.com", true},
+		{"https://term.example.com/gotty/", nil, "http://term.example.com", false},
+		{"https://term.example.com/gotty/", nil, "http://gotty.example.com:8080", false},
+		{"https://term.example.com/gotty/", nil, "", false},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.PublicURL = test.publicURL
+		options.AllowedOrigins = test.allowedOrigins
+		app := newTestApp(t, options)
+
+		r := httptest.NewRequest("GET", "http://gotty.example.com:8080/ws", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if ok := app.checkOrigin(r); ok != test.ok {
+			t.Errorf("public URL %q, allowed %v, origin %q: expected %v", test.publicURL, test.allowedOrigins, test.origin, test.ok)
+		}
+	}
+}
+
+func TestCheckConfigPublicURL(t *testing.T) {
+	tests := []struct {
+		publicURL      string
+		allowedOrigins []string
+		ok             bool
+	}{
+		{"https://term.example.com/", nil, true},
+		{"http://term.example.com:8080", nil, true},
+		{"term.example.com", nil, false},
+		{"ftp://term.example.com", nil, false},
+		{"https://term.example.com/", []string{"https://*.example.com"}, false},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.PublicURL = test.publicURL
+		options.AllowedOrigins = test.allowedOrigins
+		if err := CheckConfig(options); (err == nil) != test.ok {
+			t.Errorf("public URL %q: unexpected result %v", test.publicURL, err)
+		}
+	}
+}
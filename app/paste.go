@@ -0,0 +1,29 @@
+package app
+
+import (
+	"log"
+	"unicode/utf8"
+)
+
+// limitPaste enforces MaxPasteBytes on a single input message, which is how pastes reach us.
+// Depending on PasteOverflow, oversized input is either dropped as a whole
+// or cut at a character boundary. It returns false when nothing should be written.
+func (context *clientContext) limitPaste(input []byte) ([]byte, bool) {
+	max := context.app.options.MaxPasteBytes
+	if max <= 0 || len(input) <= max {
+		return input, true
+	}
+
+	if context.app.options.PasteOverflow != "truncate" {
+		log.Printf("Dropped input of %d bytes from %s, exceeding the paste limit of %d bytes",
+			len(input), context.request.RemoteAddr, max)
+		return nil, false
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(input[cut]) {
+		cut--
+	}
+	log.Printf("Truncated input of %d bytes from %s to %d bytes", len(input), context.request.RemoteAddr, cut)
+	return input[:cut], cut > 0
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestLimitPaste(t *testing.T) {
+	tests := []struct {
+		overflow string
+		input    string
+		output   string
+		ok       bool
+	}{
+		{"reject", "short", "short", true},
+		{"reject", "exactly 10", "exactly 10", true},
+		{"reject", "far too long", "", false},
+		{"truncate", "far too long", "far too lo", true},
+		{"truncate", "123456789é", "123456789", true},
+		{"truncate", "éééééé", "ééééé", true},
+	}
+	for _, test := range tests {
+		options := testOptions()
+		options.MaxPasteBytes = 10
+		options.PasteOverflow = test.overflow
+		context := &clientContext{
+			app:     newTestApp(t, options),
+			request: httptest.NewRequest("GET", "/ws", nil),
+		}
+
+		output, ok := context.limitPaste([]byte(test.input))
+		if string(output) != test.output || ok != test.ok {
+			t.Errorf("%s %q: got %q, %v", test.overflow, test.input, output, ok)
+		}
+	}
+}
+
+func TestPasteLimitedSession(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.MaxPasteBytes = 8
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readMessage(t, conn, SetWritePermit)
+
+	conn.WriteMessage(websocket.TextMessage, []byte("0dropped paste\n"))
+	conn.WriteMessage(websocket.TextMessage, []byte("0typed\n"))
+	if output := readOutput(t, conn, "typed"); strings.Contains(output, "dropped") {
+		t.Errorf("oversized input reached the command: %q", output)
+	}
+}
+
+func TestCheckConfigPasteOverflow(t *testing.T) {
+	for overflow, ok := range map[string]bool{"reject": true, "truncate": true, "": false, "drop": false} {
+		options := testOptions()
+		options.PasteOverflow = overflow
+		if err := CheckConfig(options); (err == nil) != ok {
+			t.Errorf("paste overflow %q: unexpected result %v", overflow, err)
+		}
+	}
+}
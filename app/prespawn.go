@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Maximum length of the validation output shown to clients.
+const maxPreSpawnMessageSize = 1024
+
+// validatePreSpawn runs the pre-spawn validation command, if any.
+// The command must exit successfully for a session to start, e.g.
+// `docker inspect -f '{{ .State.Running }}' <container>` before `docker exec`.
+// On failure, the returned message is the output of the command
+// so that clients can see why they were refused.
+func (app *App) validatePreSpawn() (string, error) {
+	if len(app.options.PreSpawnValidate) == 0 {
+		return "", nil
+	}
+
+	timeout := time.Duration(app.options.PreSpawnValidateTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	validate := app.options.PreSpawnValidate
+	cmd := exec.CommandContext(ctx, validate[0], validate[1:]...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.SysProcAttr.Credential = app.runAsCredential()
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return "", nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = errors.New("Validation timed out")
+	}
+
+	message := strings.TrimSpace(string(output))
+	if len(message) > maxPreSpawnMessageSize {
+		message = message[:maxPreSpawnMessageSize] + "..."
+	}
+	if message == "" {
+		message = err.Error()
+	}
+	return message, err
+}
+
+// refuseSession tells the client why the command was not started and closes the connection.
+func (app *App) refuseSession(conn *websocket.Conn, message string) {
+	connections := app.releaseConnection()
+	log.Printf("Refused to start command for client %s, connections: %d", conn.RemoteAddr(), connections)
+
+	text := "Failed to start session: " + message
+	text = strings.Replace(text, "\n", "\r\n", -1) + "\r\n"
+	safeMessage := base64.StdEncoding.EncodeToString([]byte(text))
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.WriteMessage(websocket.TextMessage, append([]byte{Output}, []byte(safeMessage)...))
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Session refused"), time.Now().Add(time.Second))
+	conn.Close()
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestValidatePreSpawn(t *testing.T) {
+	options := testOptions()
+	options.PreSpawnValidateTimeout = 5
+	app := newTestApp(t, options)
+
+	if message, err := app.validatePreSpawn(); err != nil || message != "" {
+		t.Errorf("failed without a validation command: %q, %v", message, err)
+	}
+
+	options.PreSpawnValidate = []string{"true"}
+	if _, err := app.validatePreSpawn(); err != nil {
+		t.Errorf("successful validation failed: %v", err)
+	}
+
+	options.PreSpawnValidate = []string{"sh", "-c", "echo container is not running; exit 1"}
+	if message, err := app.validatePreSpawn(); err == nil || message != "container is not running" {
+		t.Errorf("unexpected result of failed validation: %q, %v", message, err)
+	}
+
+	options.PreSpawnValidate = []string{"false"}
+	if message, err := app.validatePreSpawn(); err == nil || message == "" {
+		t.Errorf("failed validation without output has no message: %q, %v", message, err)
+	}
+
+	options.PreSpawnValidate = []string{"sleep", "10"}
+	options.PreSpawnValidateTimeout = 1
+	if message, err := app.validatePreSpawn(); err == nil || message != "Validation timed out" {
+		t.Errorf("unexpected result of timed out validation: %q, %v", message, err)
+	}
+}
+
+func TestPreSpawnRefusesSession(t *testing.T) {
+	options := testOptions()
+	options.PreSpawnValidate = []string{"sh", "-c", "echo not ready; exit 1"}
+	options.PreSpawnValidateTimeout = 5
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readOutput(t, conn, "Failed to start session: not ready")
+	if code := closeCode(waitClosed(t, conn)); code != websocket.ClosePolicyViolation {
+		t.Errorf("expected close code %d, got %d", websocket.ClosePolicyViolation, code)
+	}
+	waitConnections(t, app, 0)
+}
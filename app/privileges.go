@@ -0,0 +1,56 @@
+package app
+
+import (
+	"os/user"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+const prSetNoNewPrivs = 38 // PR_SET_NO_NEW_PRIVS, missing from package syscall
+
+// lookupGroups returns the ids of the groups u is a member of, which are set
+// as the supplementary groups of the commands run as u.
+// Groups with non-numeric ids are skipped.
+func lookupGroups(u *user.User) ([]uint32, error) {
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		gid, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			continue
+		}
+		groups = append(groups, uint32(gid))
+	}
+	return groups, nil
+}
+
+// runAsCredential returns the credential of RunAsUser, with its supplementary groups.
+func (app *App) runAsCredential() *syscall.Credential {
+	return &syscall.Credential{Uid: app.uid, Gid: app.gid, Groups: app.groups}
+}
+
+// withNoNewPrivs runs start, typically exec.Cmd.Start, on a thread with no_new_privs set
+// when enabled, so that the forked command and its children can't gain privileges
+// through setuid binaries such as sudo.
+// The flag can't be cleared, the thread is thrown away afterwards.
+func withNoNewPrivs(enabled bool, start func() error) error {
+	if !enabled {
+		return start()
+	}
+	result := make(chan error, 1)
+	go func() {
+		// Exits without unlocking, which terminates the thread.
+		runtime.LockOSThread()
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0)
+		if errno != 0 {
+			result <- errno
+			return
+		}
+		result <- start()
+	}()
+	return <-result
+}
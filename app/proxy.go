@@ -0,0 +1,123 @@
+package app
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// parseCIDRs parses a list of CIDR ranges. Plain IP addresses are accepted as single host ranges.
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, entry := range list {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.New("Invalid IP address: " + entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.New("Invalid CIDR: " + entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// fromTrustedProxy reports whether the direct peer of the request is one of TrustedProxies.
+func (app *App) fromTrustedProxy(r *http.Request) bool {
+	ip := remoteIP(r)
+	return ip != nil && containsIP(app.trustedProxies, ip)
+}
+
+// clientIP returns the IP of the client. With TrustXForwardedFor, requests relayed by
+// TrustedProxies are attributed to the rightmost address of X-Forwarded-For which isn't
+// a trusted proxy, as anything left of it may have been forged by the client.
+func (app *App) clientIP(r *http.Request) net.IP {
+	ip := remoteIP(r)
+	if !app.options.TrustXForwardedFor || ip == nil || !containsIP(app.trustedProxies, ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// Can't tell who added anything left of a malformed entry.
+			return ip
+		}
+		ip = hop
+		if !containsIP(app.trustedProxies, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// clientAddr describes the client for logs: its address, or with TrustXForwardedFor,
+// its IP followed by the address of the proxy which relayed the request.
+func (app *App) clientAddr(r *http.Request) string {
+	ip := app.clientIP(r)
+	if ip == nil || ip.Equal(remoteIP(r)) {
+		return r.RemoteAddr
+	}
+	return ip.String() + " (via " + r.RemoteAddr + ")"
+}
+
+// requestScheme returns the scheme the client used to reach us,
+// honoring X-Forwarded-Proto set by trusted proxies.
+func (app *App) requestScheme(r *http.Request) string {
+	if app.options.ForwardedProto != "" {
+		return app.options.ForwardedProto
+	}
+	if app.fromTrustedProxy(r) {
+		proto := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]))
+		if proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func (app *App) canonicalURL(r *http.Request, path string) *url.URL {
+	return &url.URL{Scheme: app.requestScheme(r), Host: r.Host, Path: path}
+}
+
+// ipString formats ip for logs, with an empty string for nil.
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
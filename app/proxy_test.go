@@ -0,0 +1,49 @@
+package app
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestScheme(t *testing.T) {
+	options := testOptions()
+	options.TrustedProxies = []string{"10.0.0.0/8"}
+	app := newTestApp(t, options)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		tls        bool
+		expected   string
+	}{
+		{"direct", "192.0.2.1:1000", "", false, "http"},
+		{"direct tls", "192.0.2.1:1000", "", true, "https"},
+		{"untrusted header", "192.0.2.1:1000", "https", false, "http"},
+		{"trusted proxy", "10.0.0.1:1000", "https", false, "https"},
+		{"trusted proxy list", "10.0.0.1:1000", "HTTPS, http", false, "https"},
+		{"trusted proxy downgrade", "10.0.0.1:1000", "http", true, "http"},
+		{"invalid proto", "10.0.0.1:1000", "gopher", false, "http"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.proto != "" {
+			r.Header.Set("X-Forwarded-Proto", test.proto)
+		}
+		if test.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if scheme := app.requestScheme(r); scheme != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, scheme)
+		}
+	}
+
+	// ForwardedProto overrides whatever the request says.
+	options.ForwardedProto = "https"
+	r := httptest.NewRequest("GET", "/path", nil)
+	if u := app.canonicalURL(r, "/path"); u.String() != "https://example.com/path" {
+		t.Errorf("unexpected canonical URL %s", u)
+	}
+}
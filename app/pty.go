@@ -0,0 +1,69 @@
+package app
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/kr/pty"
+)
+
+// startPty starts the command on a new PTY like pty.Start,
+// with the window size set before the command starts.
+// When outputOnly is set, the command reads from /dev/null instead of the PTY,
+// which is still used as its controlling terminal and for stdout/stderr.
+// The command finds the device name of its terminal in GOTTY_TTY, e.g. /dev/pts/3.
+// It's started with umask, unless it's -1, and with no_new_privs set when noNewPrivs is.
+func startPty(cmd *exec.Cmd, size *windowSize, outputOnly bool, umask int, noNewPrivs bool) (*os.File, error) {
+	ptyIo, tty, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+
+	if err := setPtySize(ptyIo, size); err != nil {
+		ptyIo.Close()
+		return nil, err
+	}
+
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "GOTTY_TTY="+tty.Name())
+
+	if outputOnly {
+		devNull, err := os.Open(os.DevNull)
+		if err != nil {
+			ptyIo.Close()
+			return nil, err
+		}
+		defer devNull.Close()
+
+		cmd.Stdin = devNull
+		cmd.SysProcAttr.Ctty = 1 // stdout in the child
+	}
+
+	start := func() error { return withNoNewPrivs(noNewPrivs, cmd.Start) }
+	if err := withUmask(umask, start); err != nil {
+		ptyIo.Close()
+		return nil, err
+	}
+	return ptyIo, nil
+}
+
+// initialWindowSize is the size of the PTY until the client sends its own,
+// which is given by Width and Height, or 80x24 by default.
+func (app *App) initialWindowSize() *windowSize {
+	size := &windowSize{row: 24, col: 80}
+	if app.options.Height > 0 {
+		size.row = uint16(app.options.Height)
+	}
+	if app.options.Width > 0 {
+		size.col = uint16(app.options.Width)
+	}
+	return size
+}
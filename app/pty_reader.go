@@ -0,0 +1,88 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Size of a single read from the PTY.
+const ptyReadSize = 1024
+
+// Maximum length of the reason of a close frame.
+const maxCloseReasonSize = 123
+
+// goReadPty starts reading the PTY as soon as the command has started
+// and queues the output until processSend is ready to deliver it.
+// Output printed while the client is being initialized is kept in the queue,
+// up to StartupBufferSize bytes, after which the command is held back instead.
+// The output channel is closed when the PTY can no longer be read.
+func (context *clientContext) goReadPty() {
+	chunks := context.app.options.StartupBufferSize / ptyReadSize
+	context.output = make(chan []byte, chunks)
+
+	go func() {
+		defer close(context.output)
+		for {
+			buf := make([]byte, ptyReadSize)
+			size, err := context.pty.Read(buf)
+			if err != nil {
+				if !isPtyClosed(err) {
+					log.Printf("Failed to read PTY of %s: %v", context.request.RemoteAddr, err)
+					context.ptyErr = err
+				}
+				return
+			}
+			select {
+			case context.output <- buf[:size]:
+			case <-context.done:
+				return
+			}
+		}
+	}()
+}
+
+// isPtyClosed reports whether a read error just means that the PTY was closed.
+// Linux returns EIO once all the processes holding the terminal have exited.
+func isPtyClosed(err error) bool {
+	return err == io.EOF || errors.Is(err, syscall.EIO) || errors.Is(err, os.ErrClosed)
+}
+
+// closeWithExitReason tells the client how the command ended.
+// PTY I/O errors and commands killed by a signal are reported as abnormal terminations
+// so that crashed programs can be told apart from clean exits.
+func (context *clientContext) closeWithExitReason() {
+	code := websocket.CloseNormalClosure
+	reason := "Command exited"
+	if context.ptyErr != nil {
+		code = websocket.CloseInternalServerErr
+		reason = "PTY I/O error: " + context.ptyErr.Error()
+	} else if state := context.command.ProcessState; state != nil {
+		exitCode := state.ExitCode()
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = websocket.CloseInternalServerErr
+			reason = "Command terminated abnormally: " + status.Signal().String()
+			// As reported by shells
+			exitCode = 128 + int(status.Signal())
+		} else if exitCode != 0 {
+			reason = fmt.Sprintf("Command exited with status %d", exitCode)
+		}
+		if message, ok := context.app.options.ExitMessages[strconv.Itoa(exitCode)]; ok {
+			context.writeOutput([]byte("\r\n" + message + "\r\n"))
+			reason = fmt.Sprintf("Command exited with status %d: %s", exitCode, message)
+		}
+	}
+	if len(reason) > maxCloseReasonSize {
+		reason = reason[:maxCloseReasonSize]
+	}
+
+	log.Printf("Closing connection of %s: %s", context.request.RemoteAddr, reason)
+	context.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newPipeContext(t *testing.T, startupBufferSize int) (*clientContext, *os.File) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		reader.Close()
+		writer.Close()
+	})
+	options := testOptions()
+	options.StartupBufferSize = startupBufferSize
+	return &clientContext{
+		app:     newTestApp(t, options),
+		request: httptest.NewRequest("GET", "/ws", nil),
+		pty:     reader,
+		done:    make(chan struct{}),
+	}, writer
+}
+
+func TestReadPtyBuffersStartupOutput(t *testing.T) {
+	context, writer := newPipeContext(t, 2*ptyReadSize)
+	context.goReadPty()
+
+	// Nobody delivers the output yet, it's queued up to the buffer size.
+	expected := bytes.Repeat([]byte("0123456789abcdef"), ptyReadSize/4)
+	writer.Write(expected)
+	for deadline := time.Now().Add(5 * time.Second); len(context.output) < cap(context.output); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d queued chunks, got %d", cap(context.output), len(context.output))
+		}
+	}
+	writer.Close()
+
+	output := []byte{}
+	for data := range context.output {
+		output = append(output, data...)
+	}
+	if !bytes.Equal(output, expected) {
+		t.Errorf("expected %d bytes of output in order, got %d", len(expected), len(output))
+	}
+	if context.ptyErr != nil {
+		t.Errorf("closed PTY was reported as an error: %v", context.ptyErr)
+	}
+}
+
+func TestReadPtyStopsWhenDone(t *testing.T) {
+	context, writer := newPipeContext(t, 0)
+	context.goReadPty()
+	close(context.done)
+
+	// While we are receiving, the reader picks randomly between delivering and stopping.
+	for {
+		writer.Write([]byte("output"))
+		select {
+		case _, ok := <-context.output:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("reader did not stop")
+		}
+	}
+}
+
+func TestStartupOutputIsDelivered(t *testing.T) {
+	app := newTestCommandApp(t, []string{"sh", "-c", "echo printed before init; exec cat"}, testOptions())
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readOutput(t, conn, "printed before init")
+}
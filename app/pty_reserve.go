@@ -0,0 +1,50 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Where Linux tells the limit and the number of allocated PTYs
+const ptySysctlDir = "/proc/sys/kernel/pty"
+
+// availablePtys returns how many PTYs can still be allocated according to the sysctls in dir.
+// It returns false when they are not available, e.g. on other systems than Linux.
+func availablePtys(dir string) (int, bool) {
+	max, err := readSysctlInt(filepath.Join(dir, "max"))
+	if err != nil {
+		return 0, false
+	}
+	nr, err := readSysctlInt(filepath.Join(dir, "nr"))
+	if err != nil {
+		return 0, false
+	}
+	return max - nr, true
+}
+
+func readSysctlInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// checkPtyReserve refuses to allocate a PTY when it would leave less than PtyReserve free ones,
+// so that the system keeps some for other users, e.g. administrators logging in with ssh.
+func (app *App) checkPtyReserve() error {
+	if app.options.PtyReserve <= 0 {
+		return nil
+	}
+	available, ok := availablePtys(ptySysctlDir)
+	if !ok {
+		return nil
+	}
+	if available <= app.options.PtyReserve {
+		return fmt.Errorf("No terminal is available, %d are left and %d are reserved", available, app.options.PtyReserve)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestAvailablePtys(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := availablePtys(dir); ok {
+		t.Error("PTYs were counted without the sysctls")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "max"), []byte("4096\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "nr"), []byte("10\n"), 0600)
+	if available, ok := availablePtys(dir); !ok || available != 4086 {
+		t.Errorf("expected 4086 available PTYs, got %d, %v", available, ok)
+	}
+}
+
+func TestPtyReserveRefusesSession(t *testing.T) {
+	if _, ok := availablePtys(ptySysctlDir); !ok {
+		t.Skip("the number of PTYs is not available")
+	}
+	options := testOptions()
+	// More than any system has.
+	options.PtyReserve = 1 << 30
+	app := newTestApp(t, options)
+	if err := app.checkPtyReserve(); err == nil {
+		t.Fatal("session was allowed to take a reserved PTY")
+	}
+
+	server := startTestServer(app)
+	defer server.Close()
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readOutput(t, conn, "No terminal is available")
+	waitClosed(t, conn)
+
+	options.PtyReserve = 0
+	if err := app.checkPtyReserve(); err != nil {
+		t.Errorf("PTY was refused without a reserve: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// runPty runs the shell script on a PTY and returns its output.
+func runPty(t *testing.T, script string, outputOnly bool) string {
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	ptyIo, err := startPty(cmd, &windowSize{row: 24, col: 80}, outputOnly, -1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ptyIo.Close()
+
+	// Reading fails with EIO once the command closed the PTY.
+	output, _ := ioutil.ReadAll(ptyIo)
+	cmd.Wait()
+	return string(output)
+}
+
+func TestStartPtyOutputOnly(t *testing.T) {
+	script := `if test -t 0; then echo stdin:tty; else echo stdin:null; fi; test -t 1 && echo stdout:tty; echo "tty:$GOTTY_TTY"`
+
+	output := runPty(t, script, true)
+	if !strings.Contains(output, "stdin:null") || !strings.Contains(output, "stdout:tty") {
+		t.Errorf("unexpected output of an output-only command: %q", output)
+	}
+	if !strings.Contains(output, "tty:/dev/") {
+		t.Errorf("GOTTY_TTY is not set: %q", output)
+	}
+
+	if output := runPty(t, script, false); !strings.Contains(output, "stdin:tty") {
+		t.Errorf("unexpected output of an interactive command: %q", output)
+	}
+}
+
+func TestStartPtyWindowSize(t *testing.T) {
+	cmd := exec.Command("stty", "size")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	ptyIo, err := startPty(cmd, &windowSize{row: 33, col: 101}, false, -1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ptyIo.Close()
+	output, _ := ioutil.ReadAll(ptyIo)
+	cmd.Wait()
+	if strings.TrimSpace(string(output)) != "33 101" {
+		t.Errorf("command started with size %q", output)
+	}
+}
+
+func TestOutputOnlySession(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.OutputOnly = true
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	if permit := readMessage(t, conn, SetWritePermit); permit != "false" {
+		t.Errorf("output-only session was told write permit %s", permit)
+	}
+	// cat reads /dev/null and exits.
+	waitClosed(t, conn)
+}
+
+func TestStartPtyTTYEnv(t *testing.T) {
+	// GOTTY_TTY names the terminal of the command and keeps the environment given.
+	cmd := exec.Command("sh", "-c", `test "$GOTTY_TTY" = "$(tty)" && echo "match $GREETING"`)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "GREETING=hello"}
+	ptyIo, err := startPty(cmd, &windowSize{row: 24, col: 80}, false, -1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ptyIo.Close()
+	output, _ := ioutil.ReadAll(ptyIo)
+	cmd.Wait()
+	if strings.TrimSpace(string(output)) != "match hello" {
+		t.Errorf("unexpected output %q", output)
+	}
+}
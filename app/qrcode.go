@@ -0,0 +1,27 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// printQRCodes renders the URLs as QR codes, on stdout, so that they can be scanned by phones.
+// Loopback and link-local addresses are skipped since other devices can't reach them.
+func printQRCodes(w io.Writer, urls []*url.URL) {
+	for _, u := range urls {
+		if ip := net.ParseIP(u.Hostname()); ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast()) {
+			continue
+		}
+		code, err := qrcode.New(u.String(), qrcode.Low)
+		if err != nil {
+			log.Printf("Failed to generate QR code for %s: %v", u.String(), err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\n%s\n", u.String(), code.ToSmallString(false))
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestPrintQRCodes(t *testing.T) {
+	var urls []*url.URL
+	for _, raw := range []string{"http://127.0.0.1:8080/", "http://[fe80::1]:8080/", "http://192.0.2.1:8080/", "https://example.com/"} {
+		u, _ := url.Parse(raw)
+		urls = append(urls, u)
+	}
+
+	output := new(bytes.Buffer)
+	printQRCodes(output, urls)
+	printed := output.String()
+
+	for _, skipped := range []string{"127.0.0.1", "fe80::1"} {
+		if strings.Contains(printed, skipped) {
+			t.Errorf("QR code of unreachable %s was printed", skipped)
+		}
+	}
+	for _, u := range []string{"http://192.0.2.1:8080/", "https://example.com/"} {
+		if !strings.Contains(printed, u+"\n") {
+			t.Errorf("QR code of %s was not printed", u)
+		}
+	}
+	for _, u := range []string{"http://192.0.2.1:8080/", "https://example.com/"} {
+		code, _ := qrcode.New(u, qrcode.Low)
+		expected := code.Bitmap()
+		rows := strings.Split(strings.SplitN(printed, u+"\n", 2)[1], "\n")[:(len(expected)+1)/2]
+		if modules := parseSmallQRCode(rows, len(expected)); !reflect.DeepEqual(modules, expected) {
+			t.Errorf("QR code of %s doesn't match its modules:\n%s", u, strings.Join(rows, "\n"))
+		}
+	}
+}
+
+// parseSmallQRCode turns the half block rows of QRCode.ToSmallString back into
+// the modules of a QR code of the given size, quiet zone included. Dark modules,
+// which are true, are printed blank so that they appear dark on a dark terminal.
+func parseSmallQRCode(rows []string, size int) [][]bool {
+	modules := make([][]bool, 0, size)
+	for _, row := range rows {
+		var top, bottom []bool
+		for _, r := range row {
+			switch r {
+			case ' ':
+				top, bottom = append(top, true), append(bottom, true)
+			case '█':
+				top, bottom = append(top, false), append(bottom, false)
+			case '▄':
+				top, bottom = append(top, true), append(bottom, false)
+			case '▀':
+				top, bottom = append(top, false), append(bottom, true)
+			default:
+				return nil
+			}
+		}
+		modules = append(modules, top)
+		if len(modules) < size {
+			modules = append(modules, bottom)
+		}
+	}
+	return modules
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var urlPattern = regexp.MustCompile(`URL: (http://[^/\s]+)(\S*)/`)
+
+// runTCPApp runs the app on a free local port until the test ends.
+// It returns the address of the server and the random path of the app.
+func runTCPApp(t *testing.T, app *App) (string, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.options.Address, app.options.Port, _ = net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	logs := &syncBuffer{}
+	log.SetOutput(logs)
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Run()
+	}()
+	t.Cleanup(func() {
+		app.Exit()
+		<-done
+		log.SetOutput(os.Stderr)
+	})
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if match := urlPattern.FindStringSubmatch(logs.String()); match != nil {
+			if conn, err := net.Dial("tcp", net.JoinHostPort(app.options.Address, app.options.Port)); err == nil {
+				conn.Close()
+				return match[1], match[2]
+			}
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("Run() failed: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %s", logs.String())
+		}
+	}
+}
+
+func TestRandomURLNotFoundBeforeAuth(t *testing.T) {
+	options := testOptions()
+	options.EnableRandomUrl = true
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	options.EnableAdmin = true
+	options.AdminUsers = []string{"alice"}
+	options.EnableClientConfig = true
+	app := newTestApp(t, options)
+	server, path := runTCPApp(t, app)
+	if path == "" {
+		t.Fatal("no random path")
+	}
+
+	tests := []struct {
+		path       string
+		credential bool
+		status     int
+	}{
+		{"/", false, http.StatusNotFound},
+		{"/guess/", false, http.StatusNotFound},
+		{"/guess/ws", false, http.StatusNotFound},
+		{"/ws", false, http.StatusNotFound},
+		{"/auth_token.js", false, http.StatusNotFound},
+		{"/auth_token.js", true, http.StatusNotFound},
+		{"/rexec", false, http.StatusNotFound},
+		{"/config.json", false, http.StatusNotFound},
+		{"/admin/sessions", true, http.StatusNotFound},
+		{"/admin/kill", false, http.StatusNotFound},
+		{"/admin/broadcast", false, http.StatusNotFound},
+		{path + "/", false, http.StatusUnauthorized},
+		{path + "/", true, http.StatusOK},
+		{options.HealthCheckPath, false, http.StatusOK},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", server+test.path, nil)
+		if test.credential {
+			r.SetBasicAuth("alice", "secret")
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s (credential %v): expected %d, got %d", test.path, test.credential, test.status, resp.StatusCode)
+		}
+	}
+	// Endpoints under the random path are found, whether they answer or ask for credentials.
+	for _, endpoint := range []string{"/ws", "/auth_token.js", "/rexec", "/config.json", "/admin/sessions"} {
+		resp, err := http.Get(server + path + endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			t.Errorf("%s%s was not found", path, endpoint)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket admitting rate events per second on average
+// and up to burst events at once.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mutex  *sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		mutex:  &sync.Mutex{},
+		tokens: float64(burst),
+	}
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it.
+// Nothing is taken and false is returned when the wait would exceed maxWait.
+func (limiter *rateLimiter) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if !limiter.last.IsZero() {
+		limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+	}
+	limiter.last = now
+
+	if limiter.tokens >= 1 {
+		limiter.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - limiter.tokens) / limiter.rate * float64(time.Second))
+	if wait > maxWait {
+		return 0, false
+	}
+	limiter.tokens--
+	return wait, true
+}
+
+// full reports whether the bucket would be full at now, i.e. it behaves like a new one.
+func (limiter *rateLimiter) full(now time.Time) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return limiter.tokens+now.Sub(limiter.last).Seconds()*limiter.rate >= limiter.burst
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type argReauth struct {
+	AuthToken string
+}
+
+func (context *clientContext) reauthPending() bool {
+	return atomic.LoadInt32(&context.reauthWaiting) == 1
+}
+
+// goReauth periodically challenges the client to re-submit its credential.
+// Input is suspended until the client answers, and the session is closed
+// when the answer is wrong or doesn't arrive in time.
+func (context *clientContext) goReauth() {
+	interval := time.Duration(context.app.options.ReauthInterval) * time.Second
+	timeout := time.Duration(context.app.options.ReauthTimeout) * time.Second
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-context.done:
+				return
+			}
+
+			atomic.StoreInt32(&context.reauthWaiting, 1)
+			if err := context.write([]byte{ReauthChallenge}); err != nil {
+				log.Print(err.Error())
+				return
+			}
+
+			deadline := time.NewTimer(timeout)
+			select {
+			case ok := <-context.reauthResult:
+				deadline.Stop()
+				if !ok {
+					log.Printf("Re-authentication failed for: %s", context.request.RemoteAddr)
+					context.connection.Close()
+					return
+				}
+				atomic.StoreInt32(&context.reauthWaiting, 0)
+			case <-deadline.C:
+				log.Printf("Re-authentication timed out for: %s", context.request.RemoteAddr)
+				context.connection.Close()
+				return
+			case <-context.done:
+				deadline.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (context *clientContext) handleReauth(data []byte) {
+	if !context.reauthPending() {
+		return
+	}
+
+	var args argReauth
+	if err := json.Unmarshal(data, &args); err != nil {
+		log.Print("Malformed re-authentication message")
+		args.AuthToken = ""
+	}
+
+	ok := context.app.checkAuthToken(args.AuthToken)
+	if ok {
+		// Resume input right away, input following the response must not be dropped.
+		atomic.StoreInt32(&context.reauthWaiting, 0)
+	}
+	select {
+	case context.reauthResult <- ok:
+	default:
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReauth(t *testing.T) {
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = "alice:a"
+	options.PermitWrite = true
+	options.ReauthInterval = 1
+	options.ReauthTimeout = 2
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	token := fetchAuthToken(t, app, "alice", "a")
+	conn := dialTestSession(t, server, InitMessage{AuthToken: token})
+	defer conn.Close()
+
+	readMessage(t, conn, ReauthChallenge)
+	response, _ := json.Marshal(argReauth{AuthToken: fetchAuthToken(t, app, "alice", "a")})
+	conn.WriteMessage(websocket.TextMessage, append([]byte{ReauthResponse}, response...))
+	conn.WriteMessage(websocket.TextMessage, []byte("0reauthenticated\n"))
+	readOutput(t, conn, "reauthenticated")
+
+	readMessage(t, conn, ReauthChallenge)
+	response, _ = json.Marshal(argReauth{AuthToken: "forged"})
+	conn.WriteMessage(websocket.TextMessage, append([]byte{ReauthResponse}, response...))
+	waitClosed(t, conn)
+}
+
+func TestReauthTimeout(t *testing.T) {
+	options := testOptions()
+	options.ReauthInterval = 1
+	options.ReauthTimeout = 1
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readMessage(t, conn, ReauthChallenge)
+	waitClosed(t, conn)
+}
+
+func TestReauthClient(t *testing.T) {
+	script, err := Asset("static/js/gotty.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The bundled client has to answer challenges, or sessions end after ReauthInterval.
+	for _, expected := range []string{"case '" + string(ReauthChallenge) + "'", `ws.send("` + string(ReauthResponse) + `"`} {
+		if !strings.Contains(string(script), expected) {
+			t.Errorf("gotty.js doesn't contain %s", expected)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Longest time a reconnecting client is held back before being told to retry later
+const maxReconnectWait = 30 * time.Second
+
+// How long after the end of its session a client connecting again counts as reconnecting
+const reconnectWindow = time.Minute
+
+// Interval at which buckets of keys gone quiet are dropped
+const limiterSweepInterval = time.Minute
+
+// rateLimiters keeps a token bucket per key, such as a client,
+// so that one client can't monopolize capacity shared with others.
+type rateLimiters struct {
+	rate  int
+	burst int
+
+	mutex     *sync.Mutex
+	limiters  map[string]*rateLimiter
+	lastSweep time.Time
+}
+
+func newRateLimiters(rate int, burst int) *rateLimiters {
+	return &rateLimiters{
+		rate:     rate,
+		burst:    burst,
+		mutex:    &sync.Mutex{},
+		limiters: make(map[string]*rateLimiter),
+	}
+}
+
+// reserve takes a token from the bucket of the key, see rateLimiter.reserve.
+func (limiters *rateLimiters) reserve(key string, now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	limiters.mutex.Lock()
+	if now.Sub(limiters.lastSweep) > limiterSweepInterval {
+		for key, limiter := range limiters.limiters {
+			if limiter.full(now) {
+				delete(limiters.limiters, key)
+			}
+		}
+		limiters.lastSweep = now
+	}
+	limiter, ok := limiters.limiters[key]
+	if !ok {
+		limiter = newRateLimiter(limiters.rate, limiters.burst)
+		limiters.limiters[key] = limiter
+	}
+	limiters.mutex.Unlock()
+
+	return limiter.reserve(now, maxWait)
+}
+
+// allow takes a token from the bucket of the key, if there is one.
+func (limiters *rateLimiters) allow(key string, now time.Time) bool {
+	_, ok := limiters.reserve(key, now, 0)
+	return ok
+}
+
+// recentClients remembers when the sessions of clients last ended,
+// so that a client coming back shortly afterwards is known to be reconnecting.
+type recentClients struct {
+	mutex     *sync.Mutex
+	ended     map[string]time.Time
+	lastSweep time.Time
+}
+
+func newRecentClients() *recentClients {
+	return &recentClients{
+		mutex: &sync.Mutex{},
+		ended: make(map[string]time.Time),
+	}
+}
+
+// remember records that a session of the key ended at now.
+func (clients *recentClients) remember(key string, now time.Time) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	if now.Sub(clients.lastSweep) > limiterSweepInterval {
+		for key, ended := range clients.ended {
+			if now.Sub(ended) > reconnectWindow {
+				delete(clients.ended, key)
+			}
+		}
+		clients.lastSweep = now
+	}
+	clients.ended[key] = now
+}
+
+// reconnecting reports whether a session of the key ended within reconnectWindow before now.
+func (clients *recentClients) reconnecting(key string, now time.Time) bool {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+	ended, ok := clients.ended[key]
+	return ok && now.Sub(ended) <= reconnectWindow
+}
+
+// reconnectKey tells clients apart by their IP rather than by what they claim in the init message.
+func (app *App) reconnectKey(r *http.Request) string {
+	if ip := app.clientIP(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// rememberDisconnect records the end of a session of the client of r, see throttleReconnect.
+func (app *App) rememberDisconnect(r *http.Request) {
+	if app.recentClients != nil {
+		app.recentClients.remember(app.reconnectKey(r), time.Now())
+	}
+}
+
+// throttleReconnect holds back reconnecting clients so that reconnect storms,
+// e.g. after a network outage or a restart of a proxy, don't spawn all the commands at once.
+// A client is reconnecting when one of its sessions ended within reconnectWindow.
+// All of them share a single server-wide limiter, first connections aren't throttled.
+// It closes the connection and returns false when the client has to retry later.
+func (app *App) throttleReconnect(conn *websocket.Conn, r *http.Request) bool {
+	if app.reconnectLimiter == nil {
+		return true
+	}
+
+	now := time.Now()
+	if !app.recentClients.reconnecting(app.reconnectKey(r), now) {
+		return true
+	}
+	wait, ok := app.reconnectLimiter.reserve(now, maxReconnectWait)
+	if !ok {
+		// Still reconnecting when it comes back.
+		app.rememberDisconnect(r)
+		connections := app.releaseConnection()
+		log.Printf("Too many clients are reconnecting, asked %s to retry later, connections: %d", app.clientAddr(r), connections)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeTryAgainLater, "Too many clients are reconnecting"), time.Now().Add(time.Second))
+		conn.Close()
+		return false
+	}
+	if wait > 0 {
+		log.Printf("Delaying reconnection of %s by %v", app.clientAddr(r), wait)
+		time.Sleep(wait)
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := newRateLimiter(2, 2)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if wait, ok := limiter.reserve(now, 0); !ok || wait != 0 {
+			t.Fatalf("reservation %d within the burst: wait %v, ok %v", i+1, wait, ok)
+		}
+	}
+	if _, ok := limiter.reserve(now, 0); ok {
+		t.Error("reserved beyond the burst without waiting")
+	}
+	if wait, ok := limiter.reserve(now, time.Second); !ok || wait != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms, got %v (ok %v)", wait, ok)
+	}
+	if !limiter.full(now.Add(2 * time.Second)) {
+		t.Error("bucket is not full after refilling")
+	}
+}
+
+func TestThrottleReconnectBurst(t *testing.T) {
+	options := testOptions()
+	options.ReconnectRate = 20
+	options.ReconnectBurst = 2
+	app := newTestApp(t, options)
+
+	// Sessions of many clients ended at once, e.g. when a proxy restarted.
+	clients := 12
+	requests := make([]*http.Request, clients)
+	for i := range requests {
+		requests[i] = httptest.NewRequest("GET", "/ws", nil)
+		requests[i].RemoteAddr = fmt.Sprintf("192.0.2.%d:1000", i+1)
+		app.rememberDisconnect(requests[i])
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, r := range requests {
+		wg.Add(1)
+		go func(r *http.Request) {
+			defer wg.Done()
+			app.throttleReconnect(nil, r)
+		}(r)
+	}
+	// A client connecting for the first time isn't part of the storm.
+	first := httptest.NewRequest("GET", "/ws", nil)
+	first.RemoteAddr = "198.51.100.1:1000"
+	app.throttleReconnect(nil, first)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("first connection was held back for %v", elapsed)
+	}
+	wg.Wait()
+
+	// Beyond the burst, the clients are admitted at the rate of the server as a whole.
+	expected := time.Duration(clients-options.ReconnectBurst) * time.Second / time.Duration(options.ReconnectRate)
+	if elapsed := time.Since(start); elapsed < expected-50*time.Millisecond {
+		t.Errorf("%d reconnecting clients were admitted in %v, expected at least %v", clients, elapsed, expected)
+	}
+}
+
+func TestThrottleReconnectStorm(t *testing.T) {
+	options := testOptions()
+	options.ReconnectRate = 1
+	options.ReconnectBurst = 1
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	// Use up more than maxReconnectWait of the bucket of the server.
+	for i := 0; i <= int(maxReconnectWait/time.Second); i++ {
+		app.reconnectLimiter.reserve(time.Now(), maxReconnectWait)
+	}
+
+	// A client connecting for the first time isn't throttled.
+	conn := dialTestSession(t, server, InitMessage{})
+	waitSessions(t, app, 1)
+	conn.Close()
+	waitConnections(t, app, 0)
+
+	// Once its session ended, it's reconnecting and refused until the bucket refills.
+	conn = dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	if code := closeCode(waitClosed(t, conn)); code != closeTryAgainLater {
+		t.Errorf("expected close code %d, got %d", closeTryAgainLater, code)
+	}
+	if connections := atomic.LoadInt64(app.connections); connections != 0 {
+		t.Errorf("refused connection is still counted: %d", connections)
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recorder writes the output of a session to a file in the asciinema v2 format.
+// Output is queued by record and written by a separate goroutine,
+// so that a slow disk never holds back the session.
+// Each batch of events is written as complete lines, which keeps the file valid
+// even when gotty is killed in the middle of a session.
+type recorder struct {
+	file  *os.File
+	start time.Time
+
+	mutex   *sync.Mutex
+	queue   []byte
+	closed  bool
+	pending []byte // incomplete UTF-8 sequence at the end of the last output
+	wake    chan struct{}
+	done    chan struct{}
+
+	// Called once the file is closed, if set
+	release func()
+}
+
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Command   string            `json:"command,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recordFileName returns a name like 20060102-150405-127.0.0.1_53124.cast.
+func recordFileName(remoteAddr string, now time.Time) string {
+	addr := strings.NewReplacer(":", "_", "[", "", "]", "", "/", "_").Replace(remoteAddr)
+	return now.Format("20060102-150405") + "-" + addr + ".cast"
+}
+
+func newRecorder(dir string, remoteAddr string, command []string, size *windowSize) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	path := filepath.Join(dir, recordFileName(remoteAddr, now))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	header, _ := json.Marshal(castHeader{
+		Version:   2,
+		Width:     int(size.col),
+		Height:    int(size.row),
+		Timestamp: now.Unix(),
+		Command:   strings.Join(command, " "),
+		Env:       map[string]string{"TERM": os.Getenv("TERM")},
+	})
+	if _, err := file.Write(append(header, '\n')); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	rec := &recorder{
+		file:  file,
+		start: now,
+		mutex: &sync.Mutex{},
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	go rec.writeLoop()
+	return rec, nil
+}
+
+// record queues an output event. It never blocks on the file.
+func (rec *recorder) record(data []byte) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	if rec.closed {
+		return
+	}
+
+	// Multi-byte characters can be split between reads, but events must be valid UTF-8.
+	data = append(rec.pending, data...)
+	cut := completeRunes(data)
+	rec.pending = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return
+	}
+
+	event, _ := json.Marshal([]interface{}{
+		time.Since(rec.start).Seconds(),
+		"o",
+		string(data[:cut]),
+	})
+	rec.queue = append(rec.queue, event...)
+	rec.queue = append(rec.queue, '\n')
+
+	select {
+	case rec.wake <- struct{}{}:
+	default:
+	}
+}
+
+// completeRunes returns the length of data without a multi-byte character cut at its end.
+func completeRunes(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+func (rec *recorder) writeLoop() {
+	defer close(rec.done)
+	for range rec.wake {
+		rec.mutex.Lock()
+		batch := rec.queue
+		rec.queue = nil
+		closed := rec.closed
+		rec.mutex.Unlock()
+
+		if len(batch) > 0 {
+			rec.file.Write(batch)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// Close writes the queued events and closes the file.
+func (rec *recorder) Close() error {
+	rec.mutex.Lock()
+	if rec.closed {
+		rec.mutex.Unlock()
+		return nil
+	}
+	rec.closed = true
+	rec.mutex.Unlock()
+
+	select {
+	case rec.wake <- struct{}{}:
+	default:
+	}
+	<-rec.done
+	err := rec.file.Close()
+	if rec.release != nil {
+		rec.release()
+	}
+	return err
+}
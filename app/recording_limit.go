@@ -0,0 +1,41 @@
+package app
+
+import (
+	"time"
+)
+
+// Longest time a session waits for a recording slot with the queue policy
+const maxRecordingWait = 30 * time.Second
+
+// acquireRecordingSlot reserves one of MaxConcurrentRecordings.
+// With the skip policy, it returns false at once when all of them are taken.
+// With the queue policy, it waits for one to be released, up to maxRecordingWait.
+func (app *App) acquireRecordingSlot() bool {
+	if app.recordingSlots == nil {
+		return true
+	}
+
+	select {
+	case app.recordingSlots <- struct{}{}:
+		return true
+	default:
+	}
+	if app.options.RecordingLimitPolicy != "queue" {
+		return false
+	}
+
+	timer := time.NewTimer(maxRecordingWait)
+	defer timer.Stop()
+	select {
+	case app.recordingSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (app *App) releaseRecordingSlot() {
+	if app.recordingSlots != nil {
+		<-app.recordingSlots
+	}
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingSlots(t *testing.T) {
+	options := testOptions()
+	options.MaxConcurrentRecordings = 2
+	app := newTestApp(t, options)
+
+	for i := 0; i < 2; i++ {
+		if !app.acquireRecordingSlot() {
+			t.Fatalf("slot %d was not acquired", i+1)
+		}
+	}
+	if app.acquireRecordingSlot() {
+		t.Error("slot beyond the limit was acquired with the skip policy")
+	}
+	app.releaseRecordingSlot()
+	if !app.acquireRecordingSlot() {
+		t.Error("released slot was not acquired")
+	}
+
+	// The queue policy waits for a slot to be released.
+	options.RecordingLimitPolicy = "queue"
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		app.releaseRecordingSlot()
+	}()
+	if !app.acquireRecordingSlot() {
+		t.Error("slot was not acquired once released")
+	}
+
+	unlimited := newTestApp(t, testOptions())
+	for i := 0; i < 10; i++ {
+		if !unlimited.acquireRecordingSlot() {
+			t.Fatal("slot was not acquired without a limit")
+		}
+	}
+}
+
+func TestRecordingLimitSkipsRecording(t *testing.T) {
+	options := testOptions()
+	options.RecordDir = t.TempDir()
+	options.MaxConcurrentRecordings = 1
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	first := dialTestSession(t, server, InitMessage{})
+	defer first.Close()
+	waitSessions(t, app, 1)
+	second := dialTestSession(t, server, InitMessage{})
+	defer second.Close()
+	waitSessions(t, app, 2)
+
+	files, _ := filepath.Glob(filepath.Join(options.RecordDir, "*"))
+	if len(files) != 1 {
+		t.Errorf("expected 1 recording, got %v", files)
+	}
+
+	// The slot is given back once the recorded session ends.
+	first.Close()
+	for deadline := time.Now().Add(5 * time.Second); len(app.recordingSlots) != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("recording slot was not released")
+		}
+	}
+}
+
+func TestCheckConfigRecordingLimitPolicy(t *testing.T) {
+	for policy, ok := range map[string]bool{"skip": true, "queue": true, "": true, "drop": false} {
+		options := testOptions()
+		options.RecordingLimitPolicy = policy
+		if err := CheckConfig(options); (err == nil) != ok {
+			t.Errorf("%q: unexpected result %v", policy, err)
+		}
+	}
+}
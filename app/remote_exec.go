@@ -0,0 +1,356 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Limits of exec commands when ExecTimeout, ExecMaxOutput and ExecMaxStdin are 0
+const (
+	defaultExecTimeout   = 60 // seconds
+	defaultExecMaxOutput = 40960
+	defaultExecMaxStdin  = 1024 * 1024
+)
+
+type ExecMessageReq struct {
+	Context   string
+	Command   string
+	Arguments []string
+	Async     bool
+	Timeout   int    // seconds, 0 for ExecTimeout, which is also the maximum
+	MaxOutput int    // bytes of each output, 0 for ExecMaxOutput, which is also the maximum
+	Stdin     string // written to the command, which reads from /dev/null when empty
+
+	// Why the request was changed by applyExecLimits
+	notes []string
+}
+
+type ExecMessageRsp struct {
+	*ExecMessageReq
+	Output1  string
+	Output2  string
+	ExitCode int      // -1 when the command couldn't be started or was killed by a signal
+	TimedOut bool     // set when the command was killed after exceeding its time limit
+	Error    string   // failures of gotty only, such as failing to start the command
+	Notes    []string `json:",omitempty"`
+	Job      string   `json:",omitempty"`
+	Running  bool     `json:",omitempty"`
+}
+
+// summary describes the request for logs, which must not contain what is written to the command.
+func (req *ExecMessageReq) summary() string {
+	return fmt.Sprintf("%s %q (stdin: %d bytes)", req.Command, req.Arguments, len(req.Stdin))
+}
+
+func newExecRsp(req *ExecMessageReq) ExecMessageRsp {
+	return ExecMessageRsp{
+		ExecMessageReq: req,
+		ExitCode:       -1,
+		Notes:          req.notes,
+	}
+}
+
+func (app *App) handleRemoteExec(w http.ResponseWriter, r *http.Request) {
+	// allow cross domain AJAX requests
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS, DELETE, POST")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+
+	if r.Method == http.MethodGet && r.URL.Query().Get("job") != "" {
+		app.handleExecJob(w, r.URL.Query().Get("job"))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		return
+	}
+
+	if app.execLimiters != nil && !app.execLimiters.allow(app.execIdentity(r), time.Now()) {
+		log.Printf("Rate limited exec request from %s (%s)", app.clientAddr(r), app.execIdentity(r))
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Too many exec requests", http.StatusTooManyRequests)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var req ExecMessageReq
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	record := AuditRecord{
+		Kind:       "exec",
+		RemoteAddr: r.RemoteAddr,
+		User:       app.authenticatedUser(r),
+		Uid:        app.uid,
+		Gid:        app.gid,
+		Command:    req.Command,
+		Arguments:  req.Arguments,
+	}
+
+	if !app.execAllowed(req.Command) {
+		log.Printf("Denied exec request of command %q from %s", req.Command, r.RemoteAddr)
+		rsp := newExecRsp(&req)
+		rsp.Error = fmt.Sprintf("Command %q is not allowed", req.Command)
+		record.Denied = rsp.Error
+		app.audit(record)
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(rsp)
+		return
+	}
+
+	if err := app.checkExecArguments(&req); err != nil {
+		log.Printf("Rejected exec request from %s: %v", r.RemoteAddr, err)
+		record.Denied = err.Error()
+		app.audit(record)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	app.applyExecLimits(&req)
+	client := execClient{RemoteAddr: r.RemoteAddr, User: record.User}
+
+	stream := wantsExecStream(r)
+	if stream && req.Async {
+		http.Error(w, "Streaming is not available for asynchronous requests", http.StatusBadRequest)
+		return
+	}
+	app.audit(record)
+
+	if stream {
+		app.streamExec(w, r, &req, client)
+		return
+	}
+
+	var rsp ExecMessageRsp
+	if req.Async {
+		job, ok := app.execJobs.add(&req)
+		if !ok {
+			http.Error(w, "Too many exec jobs", http.StatusServiceUnavailable)
+			return
+		}
+		go func() {
+			rsp := app.runExec(&req)
+			app.execJobs.finish(job, rsp)
+			app.logExec(client, rsp)
+		}()
+		rsp = newExecRsp(&req)
+		rsp.Job = job
+		rsp.Running = true
+	} else {
+		rsp = app.runExec(&req)
+		app.logExec(client, rsp)
+	}
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(rsp); err != nil {
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (app *App) handleExecJob(w http.ResponseWriter, id string) {
+	rsp, ok := app.execJobs.take(id)
+	if !ok {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(rsp); err != nil {
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
+
+// execAllowed reports whether the base name of the command is listed in ExecWhitelist.
+// Without a whitelist, no command is allowed unless ExecAllowAny is set.
+func (app *App) execAllowed(command string) bool {
+	if app.options.ExecAllowAny {
+		return true
+	}
+	name := filepath.Base(command)
+	for _, allowed := range app.options.ExecWhitelist {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExecArguments enforces ExecMaxArguments and ExecMaxArgLength, 0 meaning no limit,
+// and ExecMaxStdin.
+func (app *App) checkExecArguments(req *ExecMessageReq) error {
+	maxStdin := app.options.ExecMaxStdin
+	if maxStdin <= 0 {
+		maxStdin = defaultExecMaxStdin
+	}
+	if len(req.Stdin) > maxStdin {
+		return fmt.Errorf("Stdin is too large: %d bytes, at most %d are allowed", len(req.Stdin), maxStdin)
+	}
+	if max := app.options.ExecMaxArguments; max > 0 && len(req.Arguments) > max {
+		return fmt.Errorf("Too many arguments: %d, at most %d are allowed", len(req.Arguments), max)
+	}
+	if max := app.options.ExecMaxArgLength; max > 0 {
+		for i, arg := range req.Arguments {
+			if len(arg) > max {
+				return fmt.Errorf("Argument %d is too long: %d bytes, at most %d are allowed", i, len(arg), max)
+			}
+		}
+	}
+	return nil
+}
+
+// applyExecLimits sets the time limit and output cap of the request,
+// clamping them to ExecTimeout and ExecMaxOutput.
+func (app *App) applyExecLimits(req *ExecMessageReq) {
+	maxTimeout := app.options.ExecTimeout
+	if maxTimeout <= 0 {
+		maxTimeout = defaultExecTimeout
+	}
+	maxOutput := app.options.ExecMaxOutput
+	if maxOutput <= 0 {
+		maxOutput = defaultExecMaxOutput
+	}
+
+	req.notes = nil
+	if req.Timeout <= 0 {
+		req.Timeout = maxTimeout
+	} else if req.Timeout > maxTimeout {
+		req.notes = append(req.notes, fmt.Sprintf("Timeout was clamped from %d to %d seconds", req.Timeout, maxTimeout))
+		req.Timeout = maxTimeout
+	}
+	if req.MaxOutput <= 0 {
+		req.MaxOutput = maxOutput
+	} else if req.MaxOutput > maxOutput {
+		req.notes = append(req.notes, fmt.Sprintf("MaxOutput was clamped from %d to %d bytes", req.MaxOutput, maxOutput))
+		req.MaxOutput = maxOutput
+	}
+}
+
+// execReadSize returns how much to read next into an output of length bytes,
+// up to one byte over max to find out whether the output has to be truncated.
+func execReadSize(length int, max int) int64 {
+	size := max + 1 - length
+	if size > 1024 {
+		size = 1024
+	}
+	return int64(size)
+}
+
+// execCommand prepares the requested command to run as the configured user.
+func (app *App) execCommand(ctx context.Context, req *ExecMessageReq) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, req.Command, req.Arguments...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.SysProcAttr.Credential = app.runAsCredential()
+	// Kill children as well, they would keep the outputs open.
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if req.Stdin != "" {
+		// Written by os/exec in background while the outputs are read,
+		// then closed for the command to see the end of its input.
+		cmd.Stdin = strings.NewReader(req.Stdin)
+	}
+	return cmd
+}
+
+// runExec runs the requested command and returns its outputs with the exit code.
+func (app *App) runExec(req *ExecMessageReq) ExecMessageRsp {
+	var err error
+	var stdout io.ReadCloser
+	var stderr io.ReadCloser
+	var bufout bytes.Buffer
+	var buferr bytes.Buffer
+	var readStdout func()
+	var readStderr func()
+	rsp := newExecRsp(req)
+	exit := make(chan bool, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+	defer cancel()
+
+	log.Printf("Exec %s", req.summary())
+
+	cmd := app.execCommand(ctx, req)
+	if stdout, err = cmd.StdoutPipe(); err != nil {
+		rsp.Error = fmt.Sprintf("Can not connect to stdout for command %q: %v", req.Command, err)
+		goto Error
+	}
+	if stderr, err = cmd.StderrPipe(); err != nil {
+		rsp.Error = fmt.Sprintf("Can not connect to stderr for command %q: %v", req.Command, err)
+		goto Error
+	}
+	if err := cmd.Start(); err != nil {
+		rsp.Error = fmt.Sprintf("Can not start command %q: %v", req.Command, err)
+		goto Error
+	}
+	bufout.Grow(4096)
+	buferr.Grow(1024)
+
+	readStdout = func() {
+		for bufout.Len() <= req.MaxOutput {
+			if _, err := io.CopyN(&bufout, stdout, execReadSize(bufout.Len(), req.MaxOutput)); err != nil {
+				if err != io.EOF {
+					bufout.WriteString(fmt.Sprintf("...<Error occurred while reading stdout for command %q: %v>", req.Command, err))
+				}
+				return
+			}
+		}
+		bufout.Truncate(req.MaxOutput)
+		bufout.WriteString("...<More contents were truncated>")
+		cancel()
+	}
+	readStderr = func() {
+		for buferr.Len() <= req.MaxOutput {
+			if _, err := io.CopyN(&buferr, stderr, execReadSize(buferr.Len(), req.MaxOutput)); err != nil {
+				if err != io.EOF {
+					buferr.WriteString(fmt.Sprintf("...<Error occurred while reading stderr for command %q: %v>", req.Command, err))
+				}
+				return
+			}
+		}
+		buferr.Truncate(req.MaxOutput)
+		buferr.WriteString("...<More contents were truncated>")
+		cancel()
+	}
+	go func() {
+		defer func() { exit <- true }()
+		readStdout()
+	}()
+	go func() {
+		defer func() { exit <- true }()
+		readStderr()
+	}()
+
+	// Outputs must be read completely before waiting for the command.
+	// A truncated output kills the command, which closes the other one.
+	<-exit
+	<-exit
+	if err := cmd.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok && err != ctx.Err() {
+			rsp.Error = fmt.Sprintf("Failed to wait for command %q: %v", req.Command, err)
+		}
+	}
+	rsp.ExitCode = cmd.ProcessState.ExitCode()
+	rsp.TimedOut = ctx.Err() == context.DeadlineExceeded
+	rsp.Output1 = bufout.String()
+	rsp.Output2 = buferr.String()
+
+Error:
+	app.metrics.execFinished(rsp.Error == "" && rsp.ExitCode == 0)
+	return rsp
+}
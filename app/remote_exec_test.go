@@ -0,0 +1,110 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// postExecRequest sends the request to handleRemoteExec and decodes the response.
+func postExecRequest(t *testing.T, app *App, req ExecMessageReq) (int, ExecMessageRsp) {
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest("POST", "/rexec", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	app.handleRemoteExec(w, r)
+
+	var rsp ExecMessageRsp
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&rsp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return w.Code, rsp
+}
+
+func TestExecStdin(t *testing.T) {
+	options := testOptions()
+	options.ExecWhitelist = []string{"cat"}
+	app := newTestApp(t, options)
+
+	var rsp ExecMessageRsp
+	logged := captureLog(func() {
+		code, response := postExecRequest(t, app, ExecMessageReq{Command: "cat", Stdin: "top secret\n"})
+		if code != http.StatusOK {
+			t.Fatalf("exec answered %d", code)
+		}
+		rsp = response
+	})
+	if rsp.Output1 != "top secret\n" || rsp.ExitCode != 0 {
+		t.Errorf("unexpected response: %+v", rsp)
+	}
+	if strings.Contains(logged, "top secret") {
+		t.Errorf("stdin was logged: %s", logged)
+	}
+	if !strings.Contains(logged, "stdin: 11 bytes") {
+		t.Errorf("stdin length was not logged: %s", logged)
+	}
+}
+
+func TestExecStdinTooLarge(t *testing.T) {
+	options := testOptions()
+	options.ExecWhitelist = []string{"cat"}
+	options.ExecMaxStdin = 4
+	app := newTestApp(t, options)
+
+	if code, _ := postExecRequest(t, app, ExecMessageReq{Command: "cat", Stdin: "12345"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for oversized stdin, got %d", code)
+	}
+}
+
+func TestExecStreamDoesNotLogStdin(t *testing.T) {
+	options := testOptions()
+	options.ExecWhitelist = []string{"cat"}
+	app := newTestApp(t, options)
+
+	body, _ := json.Marshal(ExecMessageReq{Command: "cat", Stdin: "top secret\n"})
+	logged := captureLog(func() {
+		r := httptest.NewRequest("POST", "/rexec", bytes.NewReader(body))
+		r.Header.Set("Accept", "text/event-stream")
+		w := httptest.NewRecorder()
+		app.handleRemoteExec(w, r)
+		if !strings.Contains(w.Body.String(), "top secret") {
+			t.Errorf("output was not streamed: %q", w.Body.String())
+		}
+	})
+	if strings.Contains(logged, "top secret") {
+		t.Errorf("stdin was logged: %s", logged)
+	}
+}
+
+func TestExecArgumentLimits(t *testing.T) {
+	options := testOptions()
+	options.ExecWhitelist = []string{"echo"}
+	options.ExecMaxArguments = 2
+	options.ExecMaxArgLength = 5
+	app := newTestApp(t, options)
+
+	tests := []struct {
+		arguments []string
+		status    int
+	}{
+		{[]string{"a", "12345"}, http.StatusOK},
+		{[]string{"a", "b", "c"}, http.StatusBadRequest},
+		{[]string{"123456"}, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if code, _ := postExecRequest(t, app, ExecMessageReq{Command: "echo", Arguments: test.arguments}); code != test.status {
+			t.Errorf("%q: expected %d, got %d", test.arguments, test.status, code)
+		}
+	}
+
+	// 0 means no limit.
+	options.ExecMaxArguments = 0
+	options.ExecMaxArgLength = 0
+	if code, _ := postExecRequest(t, app, ExecMessageReq{Command: "echo", Arguments: []string{"a", "b", "123456"}}); code != http.StatusOK {
+		t.Errorf("unlimited arguments were rejected with %d", code)
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+type windowSize struct {
+	row uint16
+	col uint16
+	x   uint16
+	y   uint16
+}
+
+// resize applies the window size to the PTY.
+// When MaxResizesPerSecond is set, requests exceeding the rate are coalesced
+// and only the latest size is applied once the interval has passed.
+// Sizes identical to the current one are ignored, some frontends send them on every pixel of a drag.
+// resizeMutex is held while touching the PTY, so that it's never resized after closePty.
+func (context *clientContext) resize(rows uint16, columns uint16) {
+	size := &windowSize{row: rows, col: columns}
+
+	context.resizeMutex.Lock()
+	defer context.resizeMutex.Unlock()
+
+	if context.pendingResize == nil && *size == context.appliedSize {
+		return
+	}
+
+	limit := context.app.options.MaxResizesPerSecond
+	if limit <= 0 {
+		context.setWindowSize(size)
+		return
+	}
+	interval := time.Second / time.Duration(limit)
+
+	elapsed := time.Since(context.lastResize)
+	if context.resizeTimer == nil && elapsed >= interval {
+		context.setWindowSize(size)
+		context.lastResize = time.Now()
+		return
+	}
+
+	context.pendingResize = size
+	if context.resizeTimer == nil {
+		context.resizeTimer = time.AfterFunc(interval-elapsed, context.flushResize)
+	}
+}
+
+func (context *clientContext) flushResize() {
+	context.resizeMutex.Lock()
+	defer context.resizeMutex.Unlock()
+
+	context.resizeTimer = nil
+	select {
+	case <-context.done:
+		return
+	default:
+	}
+	if context.pendingResize != nil {
+		context.setWindowSize(context.pendingResize)
+		context.pendingResize = nil
+		context.lastResize = time.Now()
+	}
+}
+
+// closePty closes the PTY, waiting for a resize in progress.
+func (context *clientContext) closePty() error {
+	context.resizeMutex.Lock()
+	defer context.resizeMutex.Unlock()
+	return context.pty.Close()
+}
+
+// setWindowSize applies the size to the PTY unless it's the current one,
+// which would needlessly send SIGWINCH to the command.
+func (context *clientContext) setWindowSize(size *windowSize) error {
+	if *size == context.appliedSize {
+		return nil
+	}
+	if err := setPtySize(context.pty, size); err != nil {
+		return err
+	}
+	context.appliedSize = *size
+	return nil
+}
+
+func setPtySize(pty *os.File, size *windowSize) error {
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		pty.Fd(),
+		syscall.TIOCSWINSZ,
+		uintptr(unsafe.Pointer(size)),
+	)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kr/pty"
+)
+
+// newResizeContext returns a context with a PTY of 24x80 and the terminal side of the PTY.
+func newResizeContext(t *testing.T, options *Options) (*clientContext, *os.File) {
+	ptyIo, tty, err := pty.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := windowSize{row: 24, col: 80}
+	if err := setPtySize(ptyIo, &size); err != nil {
+		t.Fatal(err)
+	}
+	context := &clientContext{
+		app:         newTestApp(t, options),
+		pty:         ptyIo,
+		done:        make(chan struct{}),
+		resizeMutex: &sync.Mutex{},
+		appliedSize: size,
+	}
+	// As sessions end, so that a pending resize doesn't touch the closed PTY.
+	t.Cleanup(func() {
+		select {
+		case <-context.done:
+		default:
+			close(context.done)
+		}
+		context.closePty()
+		tty.Close()
+	})
+	return context, tty
+}
+
+func checkPtySize(t *testing.T, tty *os.File, rows int, columns int) {
+	actualRows, actualColumns, err := pty.Getsize(tty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actualRows != rows || actualColumns != columns {
+		t.Errorf("expected size %dx%d, got %dx%d", rows, columns, actualRows, actualColumns)
+	}
+}
+
+func TestResizeRateLimit(t *testing.T) {
+	options := testOptions()
+	options.MaxResizesPerSecond = 10
+	context, tty := newResizeContext(t, options)
+
+	context.resize(30, 100)
+	checkPtySize(t, tty, 30, 100)
+
+	// Requests within the interval are coalesced into the latest one.
+	context.resize(31, 101)
+	context.resize(32, 102)
+	checkPtySize(t, tty, 30, 100)
+
+	time.Sleep(200 * time.Millisecond)
+	checkPtySize(t, tty, 32, 102)
+}
+
+func TestResizeCoalescedAfterClose(t *testing.T) {
+	options := testOptions()
+	options.MaxResizesPerSecond = 10
+	context, tty := newResizeContext(t, options)
+
+	context.resize(30, 100)
+	context.resize(31, 101)
+	close(context.done)
+
+	time.Sleep(200 * time.Millisecond)
+	checkPtySize(t, tty, 30, 100)
+}
+
+func TestResizeIgnoresCurrentSize(t *testing.T) {
+	options := testOptions()
+	options.MaxResizesPerSecond = 10
+	context, tty := newResizeContext(t, options)
+
+	// Repeating the current size doesn't count against the rate.
+	context.resize(24, 80)
+	if !context.lastResize.IsZero() {
+		t.Error("resize to the current size was applied")
+	}
+	context.resize(30, 100)
+	checkPtySize(t, tty, 30, 100)
+
+	// Going back to the applied size replaces a pending resize.
+	context.resize(31, 101)
+	context.resize(30, 100)
+	time.Sleep(200 * time.Millisecond)
+	checkPtySize(t, tty, 30, 100)
+	if context.appliedSize != (windowSize{row: 30, col: 100}) {
+		t.Errorf("unexpected applied size %+v", context.appliedSize)
+	}
+}
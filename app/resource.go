@@ -91,7 +91,7 @@ func staticFaviconPng() (*asset, error) {
 	return a, nil
 }
 
-var _staticIndexHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x91\xc1\x4e\xc4\x20\x10\x86\xef\x3e\xc5\x88\xf1\x66\x4a\xbd\xb6\xb4\x4f\xe0\xd9\xab\x61\xcb\x2c\x4c\x97\x42\x03\xb3\x5d\x1b\xe3\xbb\x1b\xc4\x9e\x34\xf1\x04\xc3\xff\xe5\xe7\x23\xa8\x7b\x13\x27\xde\x57\x04\xc7\x8b\x1f\xef\x54\x5d\x00\x94\x43\x6d\xca\x06\x40\x31\xb1\xc7\xf1\x25\xda\x57\xc2\x9b\x92\x75\xac\x51\xe6\xdd\xe3\x78\x8a\x66\x7f\x82\x07\xc6\xb4\x50\xd0\x1e\x3e\xd6\x98\x89\x29\x86\x0e\xf4\x29\x47\x7f\x65\xec\xc1\x21\x59\xc7\x1d\x3c\xb7\xed\x63\x0f\x37\x32\xec\x8e\x61\xd1\xc9\x52\xe8\xa0\x5d\xdf\xfb\x4f\x25\x6b\x69\xbd\xc0\x53\xb8\x40\x42\x3f\x08\x9a\x62\x10\x50\x5c\x07\x41\x8b\xb6\x28\xd7\x60\x05\xb8\x84\xe7\x41\x9c\xf5\x56\xf2\xa6\x1c\x7d\xeb\xcb\xc3\x5f\x15\xb9\x9f\x32\x43\x1b\x90\x19\xc4\x21\x2a\x46\x25\x0d\x6d\xc7\x5b\xa6\x44\x2b\x43\x4e\xd3\x20\x1a\x39\x67\xe9\x0a\xd7\xcc\xb9\x60\x35\xfc\x93\xd4\x57\x76\x6f\x1c\x2f\x18\xfe\x67\xe7\x2c\x6d\x64\xde\x7f\x91\x4a\x56\x4f\x25\xeb\x0f\x7c\x05\x00\x00\xff\xff\xb8\xf4\x0f\x79\x99\x01\x00\x00")
+var _staticIndexHtml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x85\x91\xc1\x6e\xc3\x20\x10\x44\xef\xfd\x8a\x2d\x55\x6f\x95\x71\xaf\x31\xf6\x17\xe4\xdc\x6b\x45\x0c\x81\x4d\x30\x20\xd8\x24\xb5\xaa\xfe\x7b\x21\xd4\xa7\x56\xea\x89\xd9\x9d\xd1\xf0\x10\xe2\x51\x85\x99\xd6\xa8\xc1\xd2\xe2\xa6\x07\xd1\x0e\x00\x61\xb5\x54\x55\x14\x49\x48\x4e\x4f\xfb\x60\xde\x50\xdf\x04\x6f\x63\xb3\x32\xad\x45\x1f\x82\x5a\x5f\xe0\x89\x74\x5a\xd0\x4b\x07\x9f\x31\x64\x24\x0c\x7e\x07\xf2\x90\x83\xbb\x90\x1e\xc0\x6a\x34\x96\x76\xf0\xda\xf7\xcf\x03\xdc\x50\x91\xdd\x86\x45\x26\x83\x25\xdc\xc7\x8f\xe1\x4b\xf0\x56\xda\x2e\x70\xe8\xcf\x90\xb4\x1b\x19\xce\xc1\x33\xa8\xac\x45\x2f\xd2\x68\x1e\xbd\x61\x60\x93\x3e\x8e\xec\x28\xaf\xd5\xef\xea\xea\x8e\xcf\x37\x7e\x51\xe1\x7e\xca\x14\x5e\x01\xd5\xc8\x36\x50\x36\x09\x5e\x76\xdb\x5b\xe6\x84\x91\x20\xa7\x79\x64\x1d\x3f\x65\x6e\x6b\xae\x3b\xe5\x1a\x6b\xe6\x9f\x49\x79\x21\xfb\x4e\xe1\xac\xfd\xff\xd9\xd2\x6a\x02\xd1\xfa\x2b\x29\x78\xe3\x2c\xe0\xf7\x1f\xf8\x06\xb8\xf4\x0f\x79\x99\x01\x00\x00")
 
 func staticIndexHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -111,7 +111,7 @@ func staticIndexHtml() (*asset, error) {
 	return a, nil
 }
 
-var _staticJsGottyJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x56\xdf\x6f\xdb\x36\x10\x7e\xcf\x5f\x71\xe0\x4b\xa8\x45\x55\x9c\x76\x0f\x83\x0d\x6f\xe8\x8a\x0c\xe8\x3a\x2c\x45\xed\x2d\x0f\x41\x50\xd0\xd4\x59\xe2\x4c\x93\x06\x79\x8a\xa0\x15\xfe\xdf\x0b\x4a\xfe\x21\xcb\x52\xdd\xf2\xc1\xa6\xc8\xfb\xbe\xfb\xee\xc8\x23\xc9\x97\x85\x91\xa4\xac\xe1\x11\x7c\xb9\x02\x00\x78\x11\x0e\x72\xa2\x8d\xbf\x37\x62\xa1\x31\x85\x29\x94\xca\xa4\xb6\x4c\xb4\x95\x22\x98\x26\x1b\x67\xc9\x4a\xab\x61\x3a\x05\x56\xdb\x8e\xd9\xe4\x00\x16\x2e\xf3\x3d\x20\x8f\xc2\xc9\xfc\x68\x56\x38\x0d\x53\xe0\x27\xae\x7e\x83\xeb\xd2\xfb\xf1\xed\xed\x35\x8c\x43\x37\xf4\x22\xb8\x39\xe3\xca\xad\xa7\x9e\xe1\x8d\xa0\xdc\x88\x35\xc2\x4d\x00\x5f\x1f\x7d\xed\x05\x07\x5d\x4f\x2c\xb3\x44\x15\x7b\x6e\x29\x2e\xc8\x7e\x42\x69\x8d\x41\x49\x30\x85\x57\x77\x93\xab\xc3\xa4\xdd\xa0\x79\x0c\xc0\xb3\x4c\xed\x2d\xca\x30\x6b\xb0\x84\x47\x5c\xcc\xac\x5c\x21\xf1\xc2\xe9\xf8\xe8\x35\xda\xd1\xed\x01\x84\x6e\xdd\x19\xda\x28\x93\xcd\xd5\x1a\x5d\x6b\xbc\xf4\x89\x35\xc1\x7d\xdb\x39\xbe\xa0\xa1\xb6\x82\x9d\xa5\x47\x93\xf2\x3f\x67\x0f\x7f\x27\x9e\x9c\x32\x99\x5a\x56\xfc\x0b\xbc\x75\x59\xb1\x46\x43\x7e\x5c\x2f\x4b\x0c\x6f\x0b\xca\xe7\x76\x85\x66\x0c\x75\x1a\x3e\x8b\x82\xf2\xcf\x14\x46\xe2\x6d\x14\x4d\x4e\x68\x0f\xa2\x60\x0a\x1e\xe9\xbd\x21\x74\x2f\x42\xf3\xe0\xeb\xa3\x32\x59\x0c\x6f\x46\xf0\x13\xdc\x8d\x46\xa3\x18\xca\x93\x30\x43\xcb\x43\x9c\x49\x8a\x4b\x51\x68\x9a\x91\x75\x22\xc3\x5d\xa6\xb4\x5a\x24\xbb\x91\xe4\x2f\x2b\x85\xe6\x1d\xd7\x7d\xd8\x44\x6a\x14\x8e\x77\xdd\x04\xcb\x1d\x6d\x83\x9a\xa3\x5b\x2b\xd3\x70\x9e\x59\x26\x19\xd2\x47\x87\x4b\xcf\xa3\xc4\x23\x71\x16\x82\x79\x85\x46\xda\x54\x99\x8c\xc5\xc0\x9c\x28\x59\x2f\xd2\x9a\x3d\xf3\x27\x14\x69\x35\xb4\x25\xda\xcb\xaa\x2c\x4c\x1b\xb0\xb2\xc9\xa6\xf0\xf9\x99\xa6\xd0\x94\x4d\xac\xf9\x77\xfe\x01\x2b\x4f\xce\xae\xb0\xcd\xec\xc9\xf5\x91\xb7\x57\x9d\x8d\x18\xdc\x40\x30\x9c\x9c\xd9\x6d\xfb\xdd\x05\xdc\xac\xde\x27\x30\x3d\x73\x3f\xa4\xf0\x18\xbd\x57\xff\x9f\x88\x94\x56\x17\x6b\xe3\x63\x70\xb6\xf4\x97\xe4\xf6\x4e\x86\xc6\x5e\x87\x38\x3a\x7b\x78\xd0\x3a\xb4\x7e\x47\xed\xb6\x53\x36\xde\x77\xe2\x8b\x88\x10\xc2\xb8\xfe\xfd\xb6\xed\x76\x70\x36\xea\x9d\x39\x1f\xed\x5b\x9b\x66\xaf\x18\x4f\x42\xeb\x0f\x58\x2d\xac\x70\x69\xb7\x36\xba\xb8\x5d\xa9\x48\xeb\x04\x21\x4f\xad\xac\x4b\x3e\x6c\xf4\x7b\x8d\xa1\xfb\x7b\xf5\x3e\xe5\x8c\x76\xcb\xc7\xda\x65\xbe\xed\x9e\x37\x6b\xf4\xbe\xa9\xd3\x6f\x1f\x39\xa9\x20\x01\x53\xa8\xe7\x92\xf0\x91\x78\xad\x24\xf2\xbb\x8e\x58\x5f\x2a\x92\x39\x3f\xda\x3d\x8d\x9e\xbb\x5c\x52\x78\x84\xeb\xd1\xf5\x78\x20\x1d\x36\x29\x9d\x22\xfc\x67\xfe\xc7\x2f\x7c\x77\xe6\x0b\xb2\x0b\x1e\xe8\xba\x67\x56\x68\x0b\x87\x62\x35\xe9\x71\x71\xd7\xe3\xe2\xf6\x16\x36\xd6\x64\xdf\x4f\xf2\x7a\x48\xa7\x47\x7a\xac\xd5\xcd\x15\x69\x6c\xd4\xfd\x80\xb8\x37\x3d\xbc\x1b\x87\x4b\x74\x68\x24\x86\x3b\xa6\x2e\x8d\x8d\x70\x7e\x90\xfc\x61\xf1\x1f\x4a\x4a\x56\x58\x79\xde\xc2\x46\xc9\xd2\xba\x7b\x21\xf3\xe3\x75\xbf\xc2\x6a\xa8\x50\xa5\x35\xde\x6a\x4c\xb4\xcd\x38\x9b\x21\x51\x38\x26\x42\x69\xae\xb0\x82\x1b\x60\xe3\xfa\xa3\xad\xed\x69\x85\xd5\x73\x8f\x1c\x18\x38\x74\x57\x58\xc5\xdf\x83\xdf\xfe\x48\xfe\x7e\xee\xc9\x5f\xf7\x66\xbf\x9c\xc1\x93\xe0\xeb\x77\x49\x88\xde\xed\x39\x9a\xd8\x4f\x69\x6f\x80\x81\x0f\x5f\xa9\x67\xe7\x55\xde\xa3\x77\x3b\x5c\x7e\x52\x5b\x7f\xb9\xf8\xd4\x12\x78\xc8\x6b\xdf\x0a\xd6\xf9\x2e\xcc\x85\x33\x04\x5a\xc5\xe5\x73\x5b\x3e\xbc\xa0\xd3\xa2\xe2\xec\x5d\x13\x94\xb2\x06\xde\x05\x2d\x29\x8b\xc1\x14\x5a\x77\xcf\xa0\xd3\x05\x08\x17\xf3\xe1\x79\x70\x78\x36\x74\x30\x41\xf5\x69\xe6\x7e\x85\x51\x5f\x08\x1e\x29\xe0\x6d\x41\xbc\x79\x7d\xc5\x9d\x8c\x37\x6f\x8e\x41\x49\xdb\x66\x62\x7b\x75\x7c\xc5\xed\x9f\x2c\xed\xd4\x9e\x5e\x55\x87\xdb\xf4\x8e\x45\x07\x7c\xf8\x6b\x24\x84\x04\x6e\x23\x1e\x5d\x7d\x0d\x00\x00\xff\xff\x9e\x90\xaa\xec\x32\x0b\x00\x00")
+var _staticJsGottyJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x95\x17\xfd\x6f\xda\x46\xf4\xf7\xfc\x15\x27\x6b\x6a\xce\x1d\x75\x20\x99\xb4\x09\x94\x4d\x5d\x95\x76\xdd\xba\xa5\x4a\xd8\x32\x29\xca\xaa\xc3\x7e\x60\x37\xe6\x8e\xdd\x9d\x43\x59\xc4\xff\xbe\xf7\xce\x06\x8c\x39\x03\xbd\x1f\xc0\xbe\xf7\xfd\xfd\xcc\xc7\x85\x8c\x6d\xa6\x24\x0f\xd9\xf3\x09\xc3\xf3\x24\x34\x4b\xad\x9d\x99\x2b\x29\x46\x39\x24\xec\x92\xcd\x33\x99\xa8\x79\x94\xab\x58\x10\x6a\x34\xd3\xca\xaa\x58\xe5\xec\xf2\x92\x05\x0e\xb7\x1f\x0c\xd6\xc4\x42\x4f\x8c\x87\xc8\x80\xd0\x71\xba\x41\x2b\x34\xd2\x33\xbe\x25\xea\x27\x76\x3a\x37\xa6\x7f\x76\x76\xca\xfa\xf4\x48\x4f\x21\xfb\x76\x87\x57\xaa\x8c\xf5\x5c\xcf\x84\x4d\xa5\x98\x02\x82\x90\xf8\x74\x23\x6b\xa5\x30\xe9\x75\x1f\x4c\x94\xb5\x8b\xe0\xa1\xa6\x71\x61\xd5\x0d\xc4\x4a\x4a\x88\x2d\xa2\xbc\xea\x0d\x4e\xd6\x40\x35\x03\x79\x47\x84\x3b\x9e\x5a\x61\xcc\x09\x2a\x61\xce\xee\x60\x74\xab\xe2\x47\xb0\x1c\x8d\xeb\x6c\xa4\x86\x15\xbb\x15\x81\x05\x3d\x6d\x5c\xcd\x32\x39\x19\x66\x53\xd0\xb5\xfb\xb9\x89\x94\x24\xf1\x75\xe1\xf0\x04\xd2\xd6\x35\xa8\x30\x0d\xc8\x84\xff\x7a\x7b\xfd\x47\x64\xac\x46\x66\xd9\x78\xc1\x9f\xd9\x6b\x3d\x29\xa6\x48\x60\xfa\x2e\x2c\x1d\xf6\xba\xb0\xe9\x50\x3d\x82\xec\x33\xe7\x86\x4f\x68\x7b\xfa\xc9\xd2\x4d\x67\x19\x86\x83\x2d\xb6\x6b\xa5\x50\x01\x03\xf6\xbd\x44\xc5\x9f\x44\xce\x49\xd6\x47\x84\x75\xd8\x45\x97\xbd\x64\xbd\x6e\xb7\xdb\x41\x1d\xea\x66\xd2\x49\xc9\xce\x28\x81\xb1\x28\x72\x7b\x6b\x95\x16\x13\xa8\x3c\x95\x67\xa3\xa8\xba\x89\x3e\x60\xf8\x72\xde\x10\xed\xa3\x8d\xe2\x1c\x73\x88\x37\xc5\x10\x66\xc5\xb6\xa4\x1a\xe2\x4f\x26\x4b\x9e\x3b\x98\xd1\x04\xec\x47\x0d\x63\xc3\x43\xf4\x99\xe5\x01\x19\xf3\x0a\x64\xac\x12\xb4\x28\xe8\xb0\x40\x8b\x79\xe0\xa5\x54\x72\xc5\xf9\x06\x44\xb2\x68\x4b\x89\x7a\x58\x33\x85\x58\x8e\x38\x53\xd1\xac\x30\xe9\x8e\x4e\x74\x10\xa6\xe4\x5f\xc3\xdf\x60\x81\xb1\xc3\x50\xd4\x39\xe3\x8d\x8f\x79\x3d\xea\x41\x37\xc0\x8c\x27\xc4\xc1\x0e\xde\xd2\x2f\x8e\xe8\x6e\x5d\x9e\xa0\xac\xa6\xf8\x36\x0d\x37\xd6\x9b\xec\xbf\x2d\x25\x31\xc9\x8b\xa9\xc4\xf4\xd2\x0a\xd3\xe0\x80\xba\x5e\x20\x9d\xe0\x9c\xec\x68\xe4\x70\x2b\x36\x9d\xe7\xbd\x50\x3a\x95\x66\xfd\xd5\x43\xe7\x20\x05\x99\xd0\x77\xbf\xfb\x71\x97\xad\xd0\xf0\xe4\xb8\x5b\x5f\x6c\xca\x5c\x91\xc6\x8a\x3c\xc7\x80\x8c\x94\xd0\x49\xb3\x36\x96\xbe\xe4\x4c\xb0\x7f\x69\x61\x81\x27\x2a\x76\x25\x4f\x89\x7e\x95\x03\x3d\xfe\xbc\x78\x8f\x59\x62\xab\xf0\x05\xf5\x32\x5f\x36\xfb\xcd\x14\x8c\x29\xeb\x74\x7f\xcb\x49\x84\x15\x88\xe4\x60\x11\xbd\x44\x26\xcf\x62\xe0\xbd\x86\xb2\x66\x9e\xd9\x38\xe5\x1b\xbc\xfb\xee\x43\x93\x57\x2c\x0c\xb0\xd3\xee\x69\xbf\xc5\x1d\x2a\x9a\xeb\xcc\xc2\x9f\xc3\xb7\x3f\xf0\xaa\xe7\x0b\xab\x46\x9c\xd8\x85\x9e\xa4\x1f\x69\x10\x8f\x03\x8f\x88\x9e\x47\xc4\xd9\x19\x9b\x29\x39\x39\x9e\xc9\x79\x9b\x9e\xd8\x4e\xee\x9c\x76\xc3\xcc\xe6\x50\x6a\xf7\x15\xca\x5d\x78\xf8\xce\xb0\x53\x81\xc6\xee\x04\x34\x63\x5c\x69\xcc\x84\x36\xad\xcc\xaf\x47\x9f\x71\x84\x45\x8f\x58\xca\xbc\x46\x1b\x46\x63\xa5\xaf\x04\xc6\x61\x1d\x54\x44\x69\x2b\x54\x1c\x84\x46\xe5\x80\x73\x75\xc2\x83\x5b\xb0\x96\xda\x04\x95\x26\xd2\xe0\x6f\xd0\x77\x2f\x75\xdd\xee\x11\xf2\xe0\x51\xa7\xad\xe9\x22\x7a\xe7\x18\xfa\xe5\xd7\xf8\xef\x3b\x8f\xff\x9a\x93\xfd\xb0\x07\xb7\x8c\x77\x7b\x09\x59\xaf\x57\x3c\x4a\xdb\xb7\xd9\xa2\x4b\x70\x3c\xe2\x5b\x62\x82\xf0\x78\x7d\xbf\xf7\xe8\x8b\xb8\x38\x8f\xb1\x54\xb2\x98\x6a\xd9\xcd\xd4\x23\x18\x2e\xdb\xeb\x39\xce\x95\x39\x5c\xcd\xd9\x98\x71\x0a\x94\x2f\x25\x5c\x00\x0b\x79\xa0\x29\xd5\xab\xd5\xa4\x6a\x7e\xfd\x04\x3a\x17\x0b\x1e\xbc\x29\xbd\x84\xa2\xd9\x1b\xd2\x25\xc1\x19\x2b\x8b\x3c\x0f\xdb\x4c\x70\x1e\xa2\x49\xbf\xde\x37\xd6\x7b\x48\x83\x86\xb4\xde\x0e\xc5\x8f\xac\xeb\x33\x01\x73\x8e\xe8\x55\x61\x79\xb9\xce\x75\x1a\x21\x2c\x97\x98\x70\x8f\x57\xcb\x8b\xcd\x5a\xb8\xda\x81\xea\xae\xdd\x9e\x7d\xeb\xf1\xdc\x0b\xc2\x35\x7d\xd5\x71\xde\x02\x36\x45\x26\xd8\x58\x83\x49\x99\x5b\xbf\x70\x9b\xb5\xf8\x98\x02\x8b\x35\x24\x94\x02\x02\xf7\x55\x7a\x1f\xd1\x30\xc2\x25\x2c\x55\x79\x52\x0d\x25\x64\x61\x14\x02\x85\x75\x18\x06\x9b\x36\x39\x18\xc5\x19\x86\x31\x07\xba\x5d\xe0\xca\x07\x4c\x2a\x96\x63\x7b\x43\x72\xf4\x64\x96\x44\x6b\x03\xb6\x53\xad\xdd\x8c\x12\xf7\xdf\x02\x8c\xad\xb6\xac\xbf\x7f\xff\xf0\x0b\x6e\xed\x37\xe5\x65\x3d\x11\x2a\xbc\x88\x9c\xcc\x83\x77\x57\x43\x8c\xf5\x9e\x25\x3d\xd8\x2c\x9f\xd1\x67\x13\xf8\x18\xc9\x5c\x89\x64\xdf\xa6\xe5\xf6\x69\xe7\x3f\xfc\x16\x09\x06\x3b\xb0\xa9\x20\x4f\x5f\xb2\xb3\x7f\xe8\xad\xb9\xf3\xd2\x17\x48\xf4\x32\x1c\x7c\x73\x16\xc1\x17\x88\xf9\x4a\x2e\x86\x05\x87\x82\x81\x21\x7c\xb1\x9e\xa4\x5b\xa1\x61\x45\xd8\xc2\xd0\x67\xd0\x79\xb7\xcb\x5e\xbc\x28\xa5\x79\x8b\xa8\x92\x56\x6b\x40\x0e\xf7\xbe\xe7\xeb\x7b\x1e\x35\xdd\xff\xbe\x92\x59\xa7\xdb\x85\x67\x8b\x7a\xae\x2f\xfe\x25\xcb\x65\x63\x0d\xd8\x75\x3d\x68\xad\xf4\x3e\xdf\x1f\x2f\x72\xc7\xa0\x43\xd2\x1d\xdf\xed\xb2\x29\x2b\x97\x2e\x97\x21\x0f\x4f\xfe\x07\x10\x0a\xcf\xcf\xba\x0e\x00\x00")
 
 func staticJsGottyJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -126,7 +126,7 @@ func staticJsGottyJs() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "static/js/gotty.js", size: 2866, mode: os.FileMode(420), modTime: time.Unix(1500541305, 0)}
+	info := bindataFileInfo{name: "static/js/gotty.js", size: 3770, mode: os.FileMode(420), modTime: time.Unix(1792203182, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestLookupUidGid(t *testing.T) {
+	options := testOptions()
+	options.RunAsUser = "nobody"
+	app := newTestApp(t, options)
+	if uid, gid, _, err := app.lookupUidGid(); err != nil || uid != 65534 || gid != 65534 {
+		t.Errorf("nobody resolved to %d:%d, %v", uid, gid, err)
+	}
+
+	options.RunAsUser = "root"
+	if _, _, groups, err := app.lookupUidGid(); err != nil || !containsGid(groups, 0) {
+		t.Errorf("root resolved to groups %v, %v", groups, err)
+	}
+}
+
+func TestLookupUidGidStrict(t *testing.T) {
+	// Only the default user falls back to root when it doesn't exist.
+	defer func(saved string) { DefaultOptions.RunAsUser = saved }(DefaultOptions.RunAsUser)
+	DefaultOptions.RunAsUser = "no-such-user"
+	options := testOptions()
+	app := newTestApp(t, options)
+
+	if uid, gid, _, err := app.lookupUidGid(); err != nil || uid != 0 || gid != 0 {
+		t.Errorf("missing default user resolved to %d:%d, %v", uid, gid, err)
+	}
+
+	options.StrictRunAsUser = true
+	if _, _, _, err := app.lookupUidGid(); err == nil {
+		t.Error("missing default user fell back to root with StrictRunAsUser")
+	}
+
+	options.StrictRunAsUser = false
+	options.RunAsUser = "another-missing-user"
+	if _, _, _, err := app.lookupUidGid(); err == nil {
+		t.Error("missing user given explicitly fell back to root")
+	}
+}
+
+func TestStartPtyNoNewPrivs(t *testing.T) {
+	for _, noNewPrivs := range []bool{false, true} {
+		cmd := exec.Command("grep", "NoNewPrivs", "/proc/self/status")
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		ptyIo, err := startPty(cmd, &windowSize{row: 24, col: 80}, false, -1, noNewPrivs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, _ := ioutil.ReadAll(ptyIo)
+		ptyIo.Close()
+		cmd.Wait()
+
+		expected := "0"
+		if noNewPrivs {
+			expected = "1"
+		}
+		if fields := strings.Fields(string(output)); len(fields) != 2 || fields[1] != expected {
+			t.Errorf("noNewPrivs %v: command got %q", noNewPrivs, output)
+		}
+	}
+}
+
+func containsGid(groups []uint32, gid uint32) bool {
+	for _, group := range groups {
+		if group == gid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Time before MaxSessionDuration is reached at which the user is warned
+const sessionDeadlineWarning = 10 * time.Second
+
+// goSessionDeadline closes the session MaxSessionDuration after it started,
+// whatever the activity of the client, which sends the close signal to the command.
+func (context *clientContext) goSessionDeadline() {
+	deadline := context.startTime.Add(time.Duration(context.app.options.MaxSessionDuration) * time.Second)
+
+	go func() {
+		warning := time.NewTimer(time.Until(deadline.Add(-sessionDeadlineWarning)))
+		defer warning.Stop()
+		select {
+		case <-warning.C:
+		case <-context.done:
+			return
+		}
+
+		remaining := time.Until(deadline).Round(time.Second)
+		message := fmt.Sprintf("\r\n*** This session reaches its maximum duration and will be closed in %v ***\r\n", remaining)
+		context.writeOutput([]byte(message))
+
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-context.done:
+			return
+		}
+
+		log.Printf("Closing session of %s, maximum duration reached", context.request.RemoteAddr)
+		context.writeMutex.Lock()
+		context.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Maximum session duration reached"), time.Now().Add(time.Second))
+		context.writeMutex.Unlock()
+		context.connection.Close()
+	}()
+}
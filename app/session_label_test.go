@@ -0,0 +1,69 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSessionLabel(t *testing.T) {
+	options := testOptions()
+	options.SessionLabelFormat = "{{.Command}}/{{.SessionID}}"
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	logged := captureLog(func() {
+		conn := dialTestSession(t, server, InitMessage{})
+		defer conn.Close()
+		readMessage(t, conn, SetWritePermit)
+
+		session := waitSessions(t, app, 1)[0]
+		if expected := "cat/" + session.ID; session.Label != expected {
+			t.Errorf("expected label %q, got %q", expected, session.Label)
+		}
+	})
+	if !strings.Contains(logged, "[cat/") {
+		t.Errorf("label is not logged: %s", logged)
+	}
+}
+
+func TestSessionLabelSyntaxError(t *testing.T) {
+	options := testOptions()
+	options.SessionLabelFormat = "{{.Command"
+	if _, err := New([]string{"cat"}, options); err == nil {
+		t.Error("invalid label format was accepted")
+	}
+}
+
+func TestSessionLabelVerifiedUser(t *testing.T) {
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	options.SessionLabelFormat = "{{.User}}"
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+	token := fetchAuthToken(t, app, "alice", "secret")
+
+	// Holding the auth token doesn't let a client name itself with a made-up user.
+	tests := []struct {
+		user     string
+		password string
+		label    string
+	}{
+		{"alice", "secret", "alice"},
+		{"mallory", "guessed", ""},
+	}
+	for _, test := range tests {
+		captureLog(func() {
+			conn := dialAuthenticatedSession(t, server, test.user, test.password, token)
+			defer conn.Close()
+			readMessage(t, conn, SetWritePermit)
+
+			if session := waitSessions(t, app, 1)[0]; session.Label != test.label {
+				t.Errorf("%s: expected label %q, got %q", test.user, test.label, session.Label)
+			}
+		})
+		waitSessions(t, app, 0)
+	}
+}
@@ -0,0 +1,194 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Number of messages waiting to be sent to a spectator, which is disconnected when it falls further behind
+const spectatorQueueSize = 256
+
+// Longest time sending a message to a spectator may take
+const spectatorWriteTimeout = 10 * time.Second
+
+// spectator is a read-only client attached to the shared session.
+// Messages are sent by its own writer, so that a stalled spectator doesn't hold back
+// the owner, nor the other spectators.
+type spectator struct {
+	connection *websocket.Conn
+	request    *http.Request
+	queue      chan []byte
+	// Closed by readSpectator once removed from the session, after which nothing is queued.
+	detached chan struct{}
+}
+
+// sharedSession is the command run by its owner, the first client, for the spectators.
+type sharedSession struct {
+	key        string
+	owner      *clientContext
+	spectators []*spectator
+}
+
+// sharedSessionKey tells shared sessions apart by the command line they run,
+// so that spectators only attach to a session of the command they requested.
+func sharedSessionKey(command string, argv []string) string {
+	return fmt.Sprintf("%q", append([]string{command}, argv...))
+}
+
+// send queues the message, and disconnects the spectator when its queue is full.
+func (s *spectator) send(message []byte) {
+	select {
+	case s.queue <- message:
+	default:
+		log.Printf("Spectator %s is too slow, disconnecting it", s.request.RemoteAddr)
+		// readSpectator removes it.
+		s.connection.Close()
+	}
+}
+
+// goWrite sends the queued messages until the spectator is detached.
+func (s *spectator) goWrite() {
+	go func() {
+		for {
+			select {
+			case message := <-s.queue:
+				s.connection.SetWriteDeadline(time.Now().Add(spectatorWriteTimeout))
+				if err := s.connection.WriteMessage(websocket.TextMessage, message); err != nil {
+					s.connection.Close()
+					return
+				}
+			case <-s.detached:
+				return
+			}
+		}
+	}()
+}
+
+// joinSharedSession attaches the connection as a spectator when the shared session of the key
+// is running and returns true. Otherwise, the caller becomes the owner of the session, and has to
+// call startSharedSession once the command is running or leaveSharedSession if it fails.
+func (app *App) joinSharedSession(conn *websocket.Conn, r *http.Request, key string) bool {
+	app.sharedMutex.Lock()
+	defer app.sharedMutex.Unlock()
+
+	session, ok := app.sharedSessions[key]
+	if !ok {
+		app.sharedSessions[key] = &sharedSession{key: key}
+		return false
+	}
+	if session.owner == nil {
+		connections := app.releaseConnection()
+		log.Printf("Shared session is starting, asked %s to retry later, connections: %d", app.clientAddr(r), connections)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeTryAgainLater, "Shared session is starting"), time.Now().Add(time.Second))
+		conn.Close()
+		return true
+	}
+
+	s := &spectator{
+		connection: conn,
+		request:    r,
+		queue:      make(chan []byte, spectatorQueueSize),
+		detached:   make(chan struct{}),
+	}
+	// Queued before any output, which is shared under the same lock.
+	messages, err := session.owner.initialMessages(false)
+	if err != nil {
+		log.Printf("Failed to initialize spectator %s: %v", app.clientAddr(r), err)
+	}
+	for _, message := range messages {
+		s.send(message)
+	}
+	session.spectators = append(session.spectators, s)
+	log.Printf("Spectator %s attached to the shared session, spectators: %d", app.clientAddr(r), len(session.spectators))
+	s.goWrite()
+	go app.readSpectator(session, s)
+	return true
+}
+
+// startSharedSession makes the context the owner of the session it claimed with joinSharedSession.
+// It has to be called before the context handles its client.
+func (app *App) startSharedSession(key string, context *clientContext) {
+	app.sharedMutex.Lock()
+	session := app.sharedSessions[key]
+	session.owner = context
+	context.shared = session
+	app.sharedMutex.Unlock()
+}
+
+func (app *App) leaveSharedSession(key string) {
+	app.sharedMutex.Lock()
+	delete(app.sharedSessions, key)
+	app.sharedMutex.Unlock()
+}
+
+// endSharedSession closes the spectators when the owner of a shared session leaves.
+func (app *App) endSharedSession(context *clientContext) {
+	session := context.shared
+	if session == nil {
+		return
+	}
+	app.sharedMutex.Lock()
+	spectators := session.spectators
+	session.spectators = nil
+	delete(app.sharedSessions, session.key)
+	app.sharedMutex.Unlock()
+
+	// WriteControl can be called concurrently with the writers of the spectators.
+	for _, s := range spectators {
+		s.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Shared session closed"), time.Now().Add(time.Second))
+		s.connection.Close()
+	}
+}
+
+// shareOutput sends the output of the owner of a shared session to its spectators.
+func (app *App) shareOutput(context *clientContext, data []byte) {
+	session := context.shared
+	if session == nil {
+		return
+	}
+	app.sharedMutex.Lock()
+	defer app.sharedMutex.Unlock()
+	if len(session.spectators) == 0 {
+		return
+	}
+
+	message := append([]byte{Output}, []byte(base64.StdEncoding.EncodeToString(data))...)
+	for _, s := range session.spectators {
+		s.send(message)
+	}
+}
+
+// readSpectator drops the input of the spectator, answering pings only, until it goes away.
+func (app *App) readSpectator(session *sharedSession, s *spectator) {
+	defer func() {
+		app.sharedMutex.Lock()
+		for i, other := range session.spectators {
+			if other == s {
+				session.spectators = append(session.spectators[:i], session.spectators[i+1:]...)
+				break
+			}
+		}
+		remaining := len(session.spectators)
+		close(s.detached)
+		app.sharedMutex.Unlock()
+
+		s.connection.Close()
+		connections := app.releaseConnection()
+		log.Printf("Spectator %s detached, spectators: %d, connections: %d", app.clientAddr(s.request), remaining, connections)
+	}()
+
+	for {
+		_, data, err := s.connection.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(data) > 0 && data[0] == Ping {
+			s.send([]byte{Pong})
+		}
+	}
+}
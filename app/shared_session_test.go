@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSharedSessionSpectator(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.SharedSession = true
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	owner := dialTestSession(t, server, InitMessage{})
+	defer owner.Close()
+	owner.WriteMessage(websocket.TextMessage, []byte("0first\n"))
+	readOutput(t, owner, "first")
+
+	spectator := dialTestSession(t, server, InitMessage{})
+	defer spectator.Close()
+	if permit := readMessage(t, spectator, SetWritePermit); permit != "false" {
+		t.Errorf("spectator was told write permit %s", permit)
+	}
+
+	// Input of spectators is dropped, output of the owner is shared.
+	spectator.WriteMessage(websocket.TextMessage, []byte("0spectator\n"))
+	owner.WriteMessage(websocket.TextMessage, []byte("0second\n"))
+	if output := readOutput(t, spectator, "second"); strings.Contains(output, "spectator") {
+		t.Errorf("input of the spectator reached the command: %q", output)
+	}
+
+	owner.Close()
+	waitClosed(t, spectator)
+}
+
+func TestSharedSessionPerCommand(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.SharedSession = true
+	options.Commands = map[string][]string{"upper": {"tr", "a-z", "A-Z"}}
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	owner := dialTestSession(t, server, InitMessage{})
+	defer owner.Close()
+	owner.WriteMessage(websocket.TextMessage, []byte("0owner\n"))
+	readOutput(t, owner, "owner")
+
+	// A client of another command gets a session of its own, writable.
+	other := dialTestSession(t, server, InitMessage{Command: "upper"})
+	defer other.Close()
+	if permit := readMessage(t, other, SetWritePermit); permit != "true" {
+		t.Fatalf("client of another command was attached as a spectator")
+	}
+	other.WriteMessage(websocket.TextMessage, []byte("0other\n"))
+	readOutput(t, other, "OTHER")
+
+	if key := sharedSessionKey("cat", nil); key == sharedSessionKey("tr", []string{"a-z", "A-Z"}) {
+		t.Errorf("commands share the key %s", key)
+	}
+}
+
+func TestSharedSessionStalledSpectator(t *testing.T) {
+	options := testOptions()
+	options.PermitWrite = true
+	options.SharedSession = true
+	// More output than the socket buffers of the stalled spectator and its queue hold.
+	app := newTestCommandApp(t, []string{"sh", "-c", "read x; head -c 10000000 /dev/zero | tr '\\0' x; echo; echo END; read x"}, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	owner := dialTestSession(t, server, InitMessage{})
+	defer owner.Close()
+	readMessage(t, owner, SetWritePermit)
+
+	// This spectator never reads.
+	stalled := dialTestSession(t, server, InitMessage{})
+	defer stalled.Close()
+	waitConnections(t, app, 2)
+
+	logged := captureLog(func() {
+		owner.WriteMessage(websocket.TextMessage, []byte("0go\n"))
+		for {
+			output, _ := base64.StdEncoding.DecodeString(readMessage(t, owner, Output))
+			if strings.Contains(string(output), "END") {
+				break
+			}
+		}
+		waitConnections(t, app, 1)
+	})
+	if !strings.Contains(logged, "is too slow, disconnecting it") {
+		t.Errorf("stalled spectator was not disconnected: %s", logged)
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"log"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// goShutdownTimeout force-closes the sessions still running ShutdownTimeout after Exit,
+// so that a hung session can't hold back the shutdown forever.
+func (app *App) goShutdownTimeout() {
+	timeout := time.Duration(app.options.ShutdownTimeout) * time.Second
+
+	go func() {
+		time.Sleep(timeout)
+
+		app.sessionsMutex.Lock()
+		contexts := make([]*clientContext, 0, len(app.sessions))
+		for _, context := range app.sessions {
+			contexts = append(contexts, context)
+		}
+		app.sessionsMutex.Unlock()
+
+		if len(contexts) == 0 {
+			return
+		}
+		log.Printf("Sessions did not close within %v, force-closing %d sessions", timeout, len(contexts))
+		for _, context := range contexts {
+			syscall.Kill(-context.command.Process.Pid, syscall.Signal(context.closeSignal))
+			context.writeMutex.Lock()
+			context.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "Server shutting down"), time.Now().Add(time.Second))
+			context.writeMutex.Unlock()
+			context.connection.Close()
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"TERM":  syscall.SIGTERM,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// parseSignal accepts signal names with or without the SIG prefix, e.g. SIGTERM or term, and numbers.
+func parseSignal(name string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(name); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	upper := strings.TrimPrefix(strings.ToUpper(name), "SIG")
+	if signal, ok := signalNames[upper]; ok {
+		return signal, nil
+	}
+	return 0, errors.New("Unknown signal: " + name)
+}
+
+func checkAllowedSignals(names []string) error {
+	for _, name := range names {
+		if _, err := parseSignal(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *App) signalAllowed(signal syscall.Signal) bool {
+	for _, name := range app.options.AllowedSignals {
+		if allowed, err := parseSignal(name); err == nil && allowed == signal {
+			return true
+		}
+	}
+	return false
+}
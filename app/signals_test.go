@@ -0,0 +1,69 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	for name, expected := range map[string]syscall.Signal{
+		"SIGTERM":  syscall.SIGTERM,
+		"term":     syscall.SIGTERM,
+		"Int":      syscall.SIGINT,
+		"SIGWINCH": syscall.SIGWINCH,
+		"9":        syscall.SIGKILL,
+	} {
+		if signal, err := parseSignal(name); err != nil || signal != expected {
+			t.Errorf("%s: expected %d, got %d, %v", name, expected, signal, err)
+		}
+	}
+	for _, name := range []string{"", "SIGFOO", "0", "-1"} {
+		if _, err := parseSignal(name); err == nil {
+			t.Errorf("%q was parsed", name)
+		}
+	}
+}
+
+func TestCheckConfigAllowedSignals(t *testing.T) {
+	options := testOptions()
+	options.AllowedSignals = []string{"INT", "SIGUSR1"}
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("valid signals were rejected: %v", err)
+	}
+	options.AllowedSignals = []string{"INT", "SIGFOO"}
+	if err := CheckConfig(options); err == nil {
+		t.Error("unknown signal was accepted")
+	}
+}
+
+func TestAdminSignal(t *testing.T) {
+	options := testOptions()
+	options.AllowedSignals = []string{"SIGTERM"}
+	app := newTestCommandApp(t, []string{"sh", "-c", "trap 'echo got TERM' TERM; echo ready; while :; do sleep 0.1; done"}, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readOutput(t, conn, "ready")
+	id := waitSessions(t, app, 1)[0].ID
+
+	tests := []struct {
+		id     string
+		signal string
+		status int
+	}{
+		{id, "SIGFOO", http.StatusBadRequest},
+		{id, "SIGKILL", http.StatusForbidden},
+		{"missing", "SIGTERM", http.StatusNotFound},
+		{id, "term", http.StatusNoContent},
+	}
+	for _, test := range tests {
+		if w := postForm(app.handleAdminSignal, url.Values{"id": {test.id}, "signal": {test.signal}}); w.Code != test.status {
+			t.Errorf("%s to %s: expected %d, got %d", test.signal, test.id, test.status, w.Code)
+		}
+	}
+	readOutput(t, conn, "got TERM")
+}
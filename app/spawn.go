@@ -0,0 +1,96 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Close code asking clients to reconnect later, registered by IANA
+// but not defined by the websocket package.
+const closeTryAgainLater = 1013
+
+// isResourceExhausted reports whether the command failed to start because
+// the system ran out of processes, file descriptors, PTYs or memory.
+func isResourceExhausted(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EAGAIN, syscall.ENFILE, syscall.EMFILE, syscall.ENOSPC, syscall.ENOMEM} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseConnection gives back a slot taken by serveWS for a client,
+// either when its session ends or when it fails to start.
+func (app *App) releaseConnection() int64 {
+	connections := atomic.AddInt64(app.connections, -1)
+	if connections == 0 {
+		app.restartTimer()
+	}
+	return connections
+}
+
+// Seconds clients exceeding MaxConnection are asked to wait before retrying.
+const maxConnectionRetryAfter = 5
+
+// rejectOverLimit answers a client exceeding MaxConnection with 503 before upgrading,
+// so that it can tell the server is busy rather than waiting on a connection nobody serves.
+func (app *App) rejectOverLimit(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(maxConnectionRetryAfter))
+	http.Error(w, "Server is busy, too many connections", http.StatusServiceUnavailable)
+}
+
+func (app *App) startSpawnCooldown() {
+	if app.options.SpawnCooldown <= 0 {
+		return
+	}
+	until := time.Now().Add(time.Duration(app.options.SpawnCooldown) * time.Second)
+	atomic.StoreInt64(app.spawnCooldownUntil, until.UnixNano())
+}
+
+func (app *App) spawnCooldownRemaining() time.Duration {
+	until := time.Unix(0, atomic.LoadInt64(app.spawnCooldownUntil))
+	return time.Until(until)
+}
+
+// rejectDuringCooldown refuses new sessions while the server recovers from resource exhaustion.
+func (app *App) rejectDuringCooldown(w http.ResponseWriter) bool {
+	remaining := app.spawnCooldownRemaining()
+	if remaining <= 0 {
+		return false
+	}
+	retryAfter := int(remaining/time.Second) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "Server is busy", http.StatusServiceUnavailable)
+	return true
+}
+
+func (app *App) handleSpawnError(conn *websocket.Conn, err error) {
+	app.server.FinishRoutine()
+	connections := app.releaseConnection()
+
+	code := websocket.CloseInternalServerErr
+	reason := "Failed to execute command"
+	if isResourceExhausted(err) {
+		log.Printf("Failed to execute command due to resource exhaustion: %v, connections: %d", err, connections)
+		app.startSpawnCooldown()
+		retryAfter := app.options.SpawnCooldown
+		if retryAfter <= 0 {
+			retryAfter = 1
+		}
+		code = closeTryAgainLater
+		reason = "Server is busy, retry after " + strconv.Itoa(retryAfter) + " seconds"
+	} else {
+		log.Printf("Failed to execute command: %v, connections: %d", err, connections)
+	}
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	conn.Close()
+}
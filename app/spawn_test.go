@@ -0,0 +1,137 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestIsResourceExhausted(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{syscall.EAGAIN, true},
+		{&os.PathError{Op: "fork/exec", Path: "/bin/sh", Err: syscall.ENOMEM}, true},
+		{&os.PathError{Op: "open", Path: "/dev/ptmx", Err: syscall.ENOSPC}, true},
+		{syscall.EMFILE, true},
+		{&os.PathError{Op: "fork/exec", Path: "/missing", Err: syscall.ENOENT}, false},
+		{errors.New("other"), false},
+	}
+	for _, test := range tests {
+		if exhausted := isResourceExhausted(test.err); exhausted != test.expected {
+			t.Errorf("%v: expected %t, got %t", test.err, test.expected, exhausted)
+		}
+	}
+}
+
+func TestSpawnFailure(t *testing.T) {
+	options := testOptions()
+	app := newTestCommandApp(t, []string{"/nonexistent/command"}, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	if code := closeCode(waitClosed(t, conn)); code != websocket.CloseInternalServerErr {
+		t.Errorf("expected close code %d, got %d", websocket.CloseInternalServerErr, code)
+	}
+	waitConnections(t, app, 0)
+	if remaining := app.spawnCooldownRemaining(); remaining > 0 {
+		t.Errorf("cooldown started for a missing command: %v", remaining)
+	}
+}
+
+func TestSpawnResourceExhaustion(t *testing.T) {
+	options := testOptions()
+	options.SpawnCooldown = 30
+	app := newTestApp(t, options)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := app.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(app.connections, 1)
+		app.server.StartRoutine()
+		app.handleSpawnError(conn, &os.PathError{Op: "fork/exec", Path: "cat", Err: syscall.EAGAIN})
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if code := closeCode(waitClosed(t, conn)); code != closeTryAgainLater {
+		t.Errorf("expected close code %d, got %d", closeTryAgainLater, code)
+	}
+	waitConnections(t, app, 0)
+
+	// New sessions are refused during the cooldown.
+	w := httptest.NewRecorder()
+	app.handleWS(w, httptest.NewRequest("GET", "/ws", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 during the cooldown, got %d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "30" {
+		t.Errorf("unexpected Retry-After %q", retryAfter)
+	}
+	if !strings.Contains(w.Body.String(), "busy") {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestMaxConnection(t *testing.T) {
+	options := testOptions()
+	options.MaxConnection = 2
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	first := dialTestSession(t, server, InitMessage{})
+	second := dialTestSession(t, server, InitMessage{})
+	waitSessions(t, app, 2)
+
+	_, response, err := websocket.DefaultDialer.Dial(wsURL(server), nil)
+	if err == nil || response == nil || response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("client over the limit was not rejected with 503: %v", err)
+	}
+	if response.Header.Get("Retry-After") == "" {
+		t.Error("503 without Retry-After")
+	}
+	waitConnections(t, app, 2)
+
+	first.Close()
+	second.Close()
+	waitConnections(t, app, 0)
+
+	again := dialTestSession(t, server, InitMessage{})
+	defer again.Close()
+	waitSessions(t, app, 1)
+	again.Close()
+	waitConnections(t, app, 0)
+}
+
+func TestOnceReleasesRefusedClients(t *testing.T) {
+	options := testOptions()
+	options.Once = true
+	app := newTestApp(t, options)
+	// Another client was accepted already.
+	app.onceMutex.TryLock()
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	if code := closeCode(waitClosed(t, conn)); code != websocket.ClosePolicyViolation {
+		t.Errorf("client after the first one was closed with %d", code)
+	}
+	waitConnections(t, app, 0)
+}
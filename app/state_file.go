@@ -0,0 +1,108 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sessionState is the metadata of a session kept in the state file.
+// It tells operators what was running when gotty died, even though PTYs can't be recovered.
+type sessionState struct {
+	ID         string
+	Label      string `json:",omitempty"`
+	Command    []string
+	Pid        int
+	RemoteAddr string
+	User       string `json:",omitempty"`
+	StartTime  time.Time
+}
+
+type serverState struct {
+	Pid      int
+	Sessions []sessionState
+}
+
+// saveState writes the active sessions to StateFile.
+// The file is replaced atomically so that a crash never leaves it half written.
+func (app *App) saveState() {
+	if app.options.StateFile == "" {
+		return
+	}
+
+	app.stateMutex.Lock()
+	defer app.stateMutex.Unlock()
+
+	app.sessionsMutex.Lock()
+	state := serverState{Pid: os.Getpid(), Sessions: make([]sessionState, 0, len(app.sessions))}
+	for _, context := range app.sessions {
+		state.Sessions = append(state.Sessions, sessionState{
+			ID:         context.id,
+			Label:      context.label,
+			Command:    context.command.Args,
+			Pid:        context.command.Process.Pid,
+			RemoteAddr: context.request.RemoteAddr,
+			User:       context.user,
+			StartTime:  context.startTime,
+		})
+	}
+	app.sessionsMutex.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode state: %v", err)
+		return
+	}
+
+	path := ExpandHomeDir(app.options.StateFile)
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		log.Printf("Failed to write state file: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		log.Printf("Failed to write state file: %v", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("Failed to write state file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("Failed to write state file: %v", err)
+	}
+}
+
+// reportOrphanedSessions logs the sessions left in the state file by a previous instance
+// which didn't exit cleanly.
+func (app *App) reportOrphanedSessions() {
+	path := ExpandHomeDir(app.options.StateFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read state file: %v", err)
+		}
+		return
+	}
+
+	var state serverState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Failed to parse state file %s: %v", path, err)
+		return
+	}
+	for _, session := range state.Sessions {
+		log.Printf("Orphaned session %s of gotty (PID %d): command %q with PID %d for client %s, started at %s",
+			session.ID, state.Pid, session.Command, session.Pid, session.RemoteAddr, session.StartTime.Format(time.RFC3339))
+	}
+}
+
+func (app *App) removeState() {
+	if err := os.Remove(ExpandHomeDir(app.options.StateFile)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove state file: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readState(t *testing.T, path string) serverState {
+	var state serverState
+	if err := json.Unmarshal([]byte(readFile(t, path)), &state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestStateFile(t *testing.T) {
+	options := testOptions()
+	options.StateFile = filepath.Join(t.TempDir(), "gotty.state")
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	id := waitSessions(t, app, 1)[0].ID
+
+	state := readState(t, options.StateFile)
+	if state.Pid != os.Getpid() || len(state.Sessions) != 1 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if session := state.Sessions[0]; session.ID != id || session.Command[0] != "cat" || session.Pid == 0 {
+		t.Errorf("unexpected session state %+v", session)
+	}
+
+	// The session is removed from the state file after its connection is released.
+	conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); len(readState(t, options.StateFile).Sessions) != 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("closed session is still in the state file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	app.removeState()
+	if _, err := os.Stat(options.StateFile); !os.IsNotExist(err) {
+		t.Errorf("state file was not removed: %v", err)
+	}
+}
+
+func TestStateFileVerifiedUser(t *testing.T) {
+	options := testOptions()
+	options.StateFile = filepath.Join(t.TempDir(), "gotty.state")
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	app := newTestApp(t, options)
+	server := startTestServer(app)
+	defer server.Close()
+	token := fetchAuthToken(t, app, "alice", "secret")
+
+	// The Authorization header of /ws isn't checked by wrapBasicAuth.
+	conn := dialAuthenticatedSession(t, server, "mallory", "guessed", token)
+	defer conn.Close()
+	waitSessions(t, app, 1)
+	if session := readState(t, options.StateFile).Sessions[0]; session.User != "" {
+		t.Errorf("made-up user %q was recorded", session.User)
+	}
+}
+
+func TestReportOrphanedSessions(t *testing.T) {
+	options := testOptions()
+	options.StateFile = filepath.Join(t.TempDir(), "gotty.state")
+	app := newTestApp(t, options)
+
+	if output := captureLog(app.reportOrphanedSessions); output != "" {
+		t.Errorf("missing state file was reported: %q", output)
+	}
+
+	state := serverState{Pid: 4242, Sessions: []sessionState{
+		{ID: "abc", Command: []string{"top"}, Pid: 4343, RemoteAddr: "192.0.2.1:1234", StartTime: time.Now()},
+	}}
+	data, _ := json.Marshal(state)
+	if err := ioutil.WriteFile(options.StateFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	output := captureLog(app.reportOrphanedSessions)
+	for _, expected := range []string{"Orphaned session abc", "PID 4242", `["top"]`, "PID 4343", "192.0.2.1:1234"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("report %q does not contain %q", output, expected)
+		}
+	}
+}
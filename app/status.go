@@ -0,0 +1,43 @@
+package app
+
+import (
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ServerStatus is served at StatusPath for operators and dashboards.
+type ServerStatus struct {
+	Version       string
+	Uptime        int64 // seconds
+	Draining      bool
+	Connections   int64
+	MaxConnection int      // 0 for unlimited
+	Clients       []string // remote addresses of the running sessions
+}
+
+func (app *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := ServerStatus{
+		Version:       Version,
+		Uptime:        int64(time.Since(app.startTime) / time.Second),
+		Connections:   atomic.LoadInt64(app.connections),
+		MaxConnection: app.options.MaxConnection,
+	}
+	select {
+	case <-app.quit:
+		status.Draining = true
+	default:
+	}
+
+	app.sessionsMutex.Lock()
+	status.Clients = make([]string, 0, len(app.sessions))
+	for _, context := range app.sessions {
+		status.Clients = append(status.Clients, app.clientAddr(context.request))
+	}
+	app.sessionsMutex.Unlock()
+	sort.Strings(status.Clients)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, status)
+}
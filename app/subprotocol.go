@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Websocket subprotocols of the client wire format
+const (
+	// Messages are text frames starting with a type character, see client_context.go.
+	protocolGotty = "gotty"
+	// Reserved for the next wire format, not supported yet.
+	protocolGotty2 = "gotty2"
+)
+
+// Subprotocols gotty knows how to speak
+var supportedSubprotocols = []string{protocolGotty}
+
+func checkSubprotocols(protocols []string) error {
+	if len(protocols) == 0 {
+		return fmt.Errorf("No websocket subprotocol is accepted")
+	}
+	for _, protocol := range protocols {
+		if !subprotocolSupported(protocol) {
+			return fmt.Errorf("Unsupported websocket subprotocol: %s", protocol)
+		}
+	}
+	return nil
+}
+
+func subprotocolSupported(protocol string) bool {
+	for _, supported := range supportedSubprotocols {
+		if protocol == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsSubprotocol reports whether one of the subprotocols requested by the client is accepted.
+// Clients requesting none are legacy ones, which speak the gotty protocol.
+func (app *App) acceptsSubprotocol(r *http.Request) bool {
+	requested := websocket.Subprotocols(r)
+	if len(requested) == 0 {
+		return true
+	}
+	for _, protocol := range requested {
+		for _, accepted := range app.upgrader.Subprotocols {
+			if protocol == accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// connectionProtocol returns the subprotocol negotiated for the connection.
+func connectionProtocol(conn *websocket.Conn) string {
+	if conn.Subprotocol() == "" {
+		return protocolGotty
+	}
+	return conn.Subprotocol()
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// First byte of a TLS record carrying a handshake message such as ClientHello
+const tlsHandshakeRecord = 0x16
+
+// Time given to new connections to send their first byte
+const tlsDetectTimeout = 10 * time.Second
+
+// tlsFallbackListener serves TLS and plaintext on the same port.
+// It peeks at the first byte sent by each client, which starts
+// a handshake record for TLS, and hands over plaintext connections as they are.
+// TLS connections are given as *tls.Conn so that net/http knows about them.
+type tlsFallbackListener struct {
+	net.Listener
+	config *tls.Config
+
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce *sync.Once
+}
+
+func newTLSFallbackListener(inner net.Listener, config *tls.Config) *tlsFallbackListener {
+	listener := &tlsFallbackListener{
+		Listener:  inner,
+		config:    config,
+		conns:     make(chan net.Conn),
+		errs:      make(chan error),
+		done:      make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+	go listener.acceptLoop()
+	return listener
+}
+
+func (listener *tlsFallbackListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case err := <-listener.errs:
+		return nil, err
+	case <-listener.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (listener *tlsFallbackListener) Close() error {
+	listener.closeOnce.Do(func() { close(listener.done) })
+	return listener.Listener.Close()
+}
+
+// acceptLoop accepts connections and detects their protocol in background,
+// so that a client slow to speak doesn't hold back the others.
+func (listener *tlsFallbackListener) acceptLoop() {
+	for {
+		conn, err := listener.Listener.Accept()
+		if err != nil {
+			select {
+			case listener.errs <- err:
+			case <-listener.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go listener.detect(conn)
+	}
+}
+
+func (listener *tlsFallbackListener) detect(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(tlsDetectTimeout))
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	var detected net.Conn = &peekedConn{Conn: conn, reader: reader}
+	if first[0] == tlsHandshakeRecord {
+		detected = tls.Server(detected, listener.config)
+	}
+	select {
+	case listener.conns <- detected:
+	case <-listener.done:
+		conn.Close()
+	}
+}
+
+// peekedConn gives back the bytes peeked at before reading from the connection.
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (conn *peekedConn) Read(b []byte) (int, error) {
+	return conn.reader.Read(b)
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSFallbackListener(t *testing.T) {
+	dir := t.TempDir()
+	crtFile, keyFile := filepath.Join(dir, "gotty.crt"), filepath.Join(dir, "gotty.key")
+	if err := generateSelfSignedCert(crtFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := newTLSFallbackListener(inner, &tls.Config{Certificates: []tls.Certificate{cert}})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Write([]byte("tls"))
+		} else {
+			w.Write([]byte("plaintext"))
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	// A client which never speaks doesn't hold back the others.
+	silent, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	for scheme, expected := range map[string]string{"http": "plaintext", "https": "tls"} {
+		resp, err := client.Get(scheme + "://" + inner.Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != expected {
+			t.Errorf("%s: expected %q, got %q", scheme, expected, body)
+		}
+	}
+
+	listener.Close()
+	if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected ErrClosed after closing, got %v", err)
+	}
+}
+
+func TestCheckConfigTLSPlaintextFallback(t *testing.T) {
+	options := testOptions()
+	options.TLSPlaintextFallback = true
+	if err := CheckConfig(options); err == nil {
+		t.Error("plaintext fallback was accepted without TLS")
+	}
+
+	options.EnableTLS = true
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("valid configuration was rejected: %v", err)
+	}
+
+	options.EnableTLSClientAuth = true
+	if err := CheckConfig(options); err == nil {
+		t.Error("plaintext fallback was accepted with client authentication")
+	}
+}
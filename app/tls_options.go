@@ -0,0 +1,61 @@
+package app
+
+import (
+	"crypto/tls"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion returns the TLS version for a string like "1.2".
+// An empty string gives 0, which leaves the choice to Go.
+func parseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	if v, ok := tlsVersions[version]; ok {
+		return v, nil
+	}
+
+	valid := make([]string, 0, len(tlsVersions))
+	for name := range tlsVersions {
+		valid = append(valid, name)
+	}
+	sort.Strings(valid)
+	return 0, errors.New("Invalid TLS minimum version: " + version + " (valid values: " + strings.Join(valid, ", ") + ")")
+}
+
+// parseCipherSuites returns the IDs of cipher suites given by their names, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+// Note that TLS 1.3 cipher suites are not configurable and always enabled by Go.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := map[string]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			valid := make([]string, 0, len(suites))
+			for name := range suites {
+				valid = append(valid, name)
+			}
+			sort.Strings(valid)
+			return nil, errors.New("Invalid TLS cipher suite: " + name + " (valid values: " + strings.Join(valid, ", ") + ")")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transcript writes the output of a session to a plain text file, without the escape sequences
+// of the terminal. Only this copy is stripped, the client still receives the output as it is.
+// Like recorder, it writes in background so that a slow disk never holds back the session.
+type transcript struct {
+	file  *os.File
+	strip *ansiStripper
+
+	mutex  *sync.Mutex
+	queue  []byte
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+func newTranscript(dir string, remoteAddr string) (*transcript, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(recordFileName(remoteAddr, time.Now()), ".cast") + ".txt"
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &transcript{
+		file:  file,
+		strip: &ansiStripper{},
+		mutex: &sync.Mutex{},
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	go t.writeLoop()
+	return t, nil
+}
+
+// write queues the stripped output. data is not modified.
+func (t *transcript) write(data []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.queue = t.strip.appendText(t.queue, data)
+
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *transcript) writeLoop() {
+	defer close(t.done)
+	for range t.wake {
+		t.mutex.Lock()
+		batch := t.queue
+		t.queue = nil
+		closed := t.closed
+		t.mutex.Unlock()
+
+		if len(batch) > 0 {
+			t.file.Write(batch)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// Close writes the queued text and closes the file.
+func (t *transcript) Close() error {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mutex.Unlock()
+
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	<-t.done
+	return t.file.Close()
+}
+
+// States of ansiStripper
+const (
+	ansiText      = iota
+	ansiEscape    // after ESC
+	ansiCSI       // in a control sequence, ESC [
+	ansiString    // in a string such as an OSC title, ESC ] ... BEL or ESC \
+	ansiStringEsc // after ESC in a string
+)
+
+// ansiStripper removes escape sequences and control characters other than
+// newlines and tabs from the output of a terminal.
+// Its state is kept between calls, as sequences may be split between reads.
+type ansiStripper struct {
+	state int
+}
+
+// appendText appends the text of data to dst.
+func (s *ansiStripper) appendText(dst []byte, data []byte) []byte {
+	for _, b := range data {
+		switch s.state {
+		case ansiText:
+			switch {
+			case b == 0x1b:
+				s.state = ansiEscape
+			case b == '\n' || b == '\t':
+				dst = append(dst, b)
+			case b < 0x20 || b == 0x7f:
+			default:
+				dst = append(dst, b)
+			}
+		case ansiEscape:
+			switch {
+			case b == '[':
+				s.state = ansiCSI
+			case b == ']' || b == 'P' || b == 'X' || b == '^' || b == '_':
+				s.state = ansiString
+			case b >= 0x20 && b <= 0x2f:
+				// Intermediate bytes, e.g. ESC ( B, the sequence goes on.
+			default:
+				s.state = ansiText
+			}
+		case ansiCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.state = ansiText
+			}
+		case ansiString:
+			if b == 0x07 {
+				s.state = ansiText
+			} else if b == 0x1b {
+				s.state = ansiStringEsc
+			}
+		case ansiStringEsc:
+			if b == '\\' {
+				s.state = ansiText
+			} else {
+				s.state = ansiString
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestANSIStripper(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{"plain text\n", "plain text\n"},
+		{"\x1b[1;31mred\x1b[0m\r\n", "red\n"},
+		{"\x1b]0;title\x07prompt$ ", "prompt$ "},
+		{"\x1b]2;title\x1b\\after", "after"},
+		{"\x1b(Bcharset", "charset"},
+		{"tab\there\x07\x08", "tab\there"},
+	}
+	for _, test := range tests {
+		if output := string((&ansiStripper{}).appendText(nil, []byte(test.input))); output != test.output {
+			t.Errorf("%q: expected %q, got %q", test.input, test.output, output)
+		}
+	}
+
+	// Sequences split between reads are still removed.
+	stripper := &ansiStripper{}
+	output := stripper.appendText(nil, []byte("one\x1b[3"))
+	output = stripper.appendText(output, []byte("2mtwo\x1b]0;ti"))
+	output = stripper.appendText(output, []byte("tle\x07three"))
+	if string(output) != "onetwothree" {
+		t.Errorf("unexpected output of split sequences %q", output)
+	}
+}
+
+func TestTranscriptSession(t *testing.T) {
+	options := testOptions()
+	options.TranscriptDir = t.TempDir()
+	app := newTestCommandApp(t, []string{"printf", `\033[1mbold\033[0m text\n`}, options)
+	server := startTestServer(app)
+	defer server.Close()
+
+	// The client receives the output as it is.
+	conn := dialTestSession(t, server, InitMessage{})
+	defer conn.Close()
+	readOutput(t, conn, "\x1b[1mbold\x1b[0m text")
+	waitClosed(t, conn)
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		files, _ := filepath.Glob(filepath.Join(options.TranscriptDir, "*.txt"))
+		if len(files) == 1 && readFile(t, files[0]) == "bold text\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected transcripts %v", files)
+		}
+	}
+}
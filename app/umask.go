@@ -0,0 +1,40 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// The umask belongs to the whole process, commands are started one at a time
+// while it's changed.
+var umaskMutex = &sync.Mutex{}
+
+// parseUmask parses an octal umask such as 027. An empty string gives -1,
+// meaning the umask of gotty is inherited.
+func parseUmask(umask string) (int, error) {
+	if umask == "" {
+		return -1, nil
+	}
+	mask, err := strconv.ParseUint(umask, 8, 32)
+	if err != nil || mask > 0777 {
+		return -1, errors.New("Invalid umask: " + umask + " (use an octal value such as 027)")
+	}
+	return int(mask), nil
+}
+
+// withUmask runs start, typically exec.Cmd.Start, with the umask set to mask,
+// so that the forked command inherits it. Files created by gotty itself meanwhile get it too,
+// gotty sets the modes of its own files explicitly.
+func withUmask(mask int, start func() error) error {
+	if mask < 0 {
+		return start()
+	}
+	umaskMutex.Lock()
+	defer umaskMutex.Unlock()
+
+	old := syscall.Umask(mask)
+	defer syscall.Umask(old)
+	return start()
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseUmask(t *testing.T) {
+	for umask, expected := range map[string]int{"": -1, "027": 027, "0": 0, "777": 0777} {
+		if mask, err := parseUmask(umask); err != nil || mask != expected {
+			t.Errorf("%q: expected %o, got %o, %v", umask, expected, mask, err)
+		}
+	}
+	for _, umask := range []string{"1000", "8", "u=rwx", "-1"} {
+		if _, err := parseUmask(umask); err == nil {
+			t.Errorf("%q was accepted", umask)
+		}
+	}
+}
+
+func TestStartPtyUmask(t *testing.T) {
+	current := syscall.Umask(022)
+	syscall.Umask(current)
+
+	cmd := exec.Command("sh", "-c", "umask")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	ptyIo, err := startPty(cmd, &windowSize{row: 24, col: 80}, false, 0027, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ptyIo.Close()
+	output, _ := ioutil.ReadAll(ptyIo)
+	cmd.Wait()
+	if strings.TrimSpace(string(output)) != "0027" {
+		t.Errorf("command started with umask %q", output)
+	}
+
+	if restored := syscall.Umask(current); restored != current {
+		t.Errorf("umask of gotty was left at %o", restored)
+	}
+}
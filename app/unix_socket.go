@@ -0,0 +1,40 @@
+package app
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+)
+
+func parseSocketMode(mode string) (os.FileMode, error) {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || parsed > 0777 {
+		return 0, errors.New("Invalid unix socket mode: " + mode + " (use an octal value such as 0660)")
+	}
+	return os.FileMode(parsed), nil
+}
+
+// listenUnix listens on a unix socket at path with the given permissions.
+// A socket left behind by a previous run is replaced, but no other kind of file.
+// The socket is removed when the listener is closed.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, errors.New("Not a unix socket: " + path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
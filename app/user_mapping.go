@@ -0,0 +1,76 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"os/user"
+	"regexp"
+	"strconv"
+	"syscall"
+)
+
+// System users given by their IDs, e.g. "1000" or "1000:1000"
+var numericUserPattern = regexp.MustCompile(`^(\d+)(?::(\d+))?$`)
+
+// resolveUserMapping looks up the system users given by UserMapping,
+// either by name or by uid[:gid], for each authenticated user.
+func resolveUserMapping(mapping map[string]string) (map[string]*syscall.Credential, error) {
+	credentials := make(map[string]*syscall.Credential, len(mapping))
+	for authUser, systemUser := range mapping {
+		credential, err := lookupCredential(systemUser)
+		if err != nil {
+			return nil, errors.New("Failed to look up user " + systemUser + " mapped from " + authUser + ": " + err.Error())
+		}
+		log.Printf("Commands of %s run as %s (%d, %d)", authUser, systemUser, credential.Uid, credential.Gid)
+		credentials[authUser] = credential
+	}
+	return credentials, nil
+}
+
+func lookupCredential(systemUser string) (*syscall.Credential, error) {
+	if match := numericUserPattern.FindStringSubmatch(systemUser); match != nil {
+		uid, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		gid := uid
+		if match[2] != "" {
+			if gid, err = strconv.ParseUint(match[2], 10, 32); err != nil {
+				return nil, err
+			}
+		}
+		credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+		// Users given by id may not exist, they're run without supplementary groups then.
+		if u, err := user.LookupId(match[1]); err == nil {
+			credential.Groups, _ = lookupGroups(u)
+		}
+		return credential, nil
+	}
+
+	u, err := user.Lookup(systemUser)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := lookupGroups(u)
+	if err != nil {
+		return nil, err
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}, nil
+}
+
+// sessionCredential returns the system user to run the command of an authenticated user as,
+// which is RunAsUser unless the user is listed in UserMapping.
+func (app *App) sessionCredential(authUser string) *syscall.Credential {
+	if credential, ok := app.userCredentials[authUser]; ok && authUser != "" {
+		return &syscall.Credential{Uid: credential.Uid, Gid: credential.Gid, Groups: credential.Groups}
+	}
+	return app.runAsCredential()
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestResolveUserMapping(t *testing.T) {
+	credentials, err := resolveUserMapping(map[string]string{"alice": "1000:1001", "bob": "1002", "carol": "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for user, expected := range map[string][2]uint32{"alice": {1000, 1001}, "bob": {1002, 1002}, "carol": {0, 0}} {
+		if credential := credentials[user]; credential.Uid != expected[0] || credential.Gid != expected[1] {
+			t.Errorf("%s: expected %v, got %d:%d", user, expected, credential.Uid, credential.Gid)
+		}
+	}
+
+	if _, err := resolveUserMapping(map[string]string{"mallory": "no-such-user"}); err == nil {
+		t.Error("unknown system user was resolved")
+	}
+}
+
+func TestSessionCredential(t *testing.T) {
+	app := newTestApp(t, testOptions())
+	app.uid, app.gid = 1, 2
+	app.userCredentials, _ = resolveUserMapping(map[string]string{"alice": "1000:1001"})
+
+	for user, expected := range map[string]uint32{"alice": 1000, "bob": 1, "": 1} {
+		if credential := app.sessionCredential(user); credential.Uid != expected {
+			t.Errorf("%q: expected uid %d, got %d", user, expected, credential.Uid)
+		}
+	}
+}
+
+func TestCheckConfigUserMapping(t *testing.T) {
+	options := testOptions()
+	options.UserMapping = map[string]string{"alice": "nobody"}
+	if err := CheckConfig(options); err == nil {
+		t.Error("user mapping was accepted without basic authentication")
+	}
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	if err := CheckConfig(options); err != nil {
+		t.Errorf("valid configuration was rejected: %v", err)
+	}
+}
+
+func TestUserMappingSession(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("running commands as another user requires root")
+	}
+	options := testOptions()
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	app := newTestCommandApp(t, []string{"sh", "-c", "echo uid=$(id -u)"}, options)
+	app.userCredentials, _ = resolveUserMapping(map[string]string{"alice": "65534:65534"})
+	server := startTestServer(app)
+	defer server.Close()
+
+	header := http.Header{}
+	(&http.Request{Header: header}).SetBasicAuth("alice", "secret")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(server), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	message, _ := json.Marshal(InitMessage{AuthToken: fetchAuthToken(t, app, "alice", "secret")})
+	conn.WriteMessage(websocket.TextMessage, message)
+	readOutput(t, conn, "uid=65534")
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// webhook posts session events as JSON to WebhookURL.
+// Events are kept in a bounded queue while the endpoint is unavailable
+// and retried with exponential backoff. When the queue is full,
+// the oldest event is dropped so that recent ones are delivered first.
+type webhook struct {
+	url        string
+	client     *http.Client
+	maxSize    int
+	maxBackoff time.Duration
+
+	mutex   *sync.Mutex
+	queue   []webhookEntry
+	nextSeq int64
+	wake    chan struct{}
+
+	// Use atomic operations.
+	dropped int64
+}
+
+type webhookEntry struct {
+	seq   int64
+	event SessionEvent
+}
+
+func newWebhook(url string, maxSize int, maxBackoff time.Duration) *webhook {
+	return &webhook{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		maxSize:    maxSize,
+		maxBackoff: maxBackoff,
+		mutex:      &sync.Mutex{},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+func (hook *webhook) enqueue(event SessionEvent) {
+	hook.mutex.Lock()
+	if len(hook.queue) >= hook.maxSize {
+		hook.queue = hook.queue[1:]
+		dropped := atomic.AddInt64(&hook.dropped, 1)
+		log.Printf("Webhook queue is full, dropped the oldest event (%d dropped in total)", dropped)
+	}
+	hook.queue = append(hook.queue, webhookEntry{seq: hook.nextSeq, event: event})
+	hook.nextSeq++
+	hook.mutex.Unlock()
+
+	select {
+	case hook.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (hook *webhook) depth() int {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	return len(hook.queue)
+}
+
+func (hook *webhook) peek() (webhookEntry, bool) {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	if len(hook.queue) == 0 {
+		return webhookEntry{}, false
+	}
+	return hook.queue[0], true
+}
+
+// remove drops the delivered entry, unless it has already been pushed out by an overflow.
+func (hook *webhook) remove(entry webhookEntry) {
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	if len(hook.queue) > 0 && hook.queue[0].seq == entry.seq {
+		hook.queue = hook.queue[1:]
+	}
+}
+
+func (hook *webhook) post(event SessionEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := hook.client.Post(hook.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("Unexpected status code: " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+// goDeliver sends the queued events in order until quit is closed.
+func (hook *webhook) goDeliver(quit <-chan struct{}) {
+	go func() {
+		backoff := time.Duration(0)
+		for {
+			select {
+			case <-hook.wake:
+			case <-quit:
+				return
+			}
+
+			for {
+				entry, ok := hook.peek()
+				if !ok {
+					break
+				}
+				if err := hook.post(entry.event); err != nil {
+					if backoff == 0 {
+						backoff = time.Second
+					} else if backoff *= 2; backoff > hook.maxBackoff {
+						backoff = hook.maxBackoff
+					}
+					log.Printf("Failed to deliver webhook event, retrying in %v: %v", backoff, err)
+					select {
+					case <-time.After(backoff):
+						continue
+					case <-quit:
+						return
+					}
+				}
+				backoff = 0
+				hook.remove(entry)
+			}
+		}
+	}()
+}
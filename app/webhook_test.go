@@ -0,0 +1,144 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newWebhookReceiver returns a server passing the received events to the channel.
+// The first failures requests are answered with an error.
+func newWebhookReceiver(failures int) (*httptest.Server, chan SessionEvent) {
+	events := make(chan SessionEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event SessionEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- event
+	}))
+	return server, events
+}
+
+func receiveEvent(t *testing.T, events chan SessionEvent) SessionEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event was delivered")
+		return SessionEvent{}
+	}
+}
+
+func TestWebhookQueueOverflow(t *testing.T) {
+	hook := newWebhook("http://127.0.0.1:1/", 2, time.Second)
+	for _, id := range []string{"first", "second", "third"} {
+		hook.enqueue(SessionEvent{SessionID: id})
+	}
+	if depth := hook.depth(); depth != 2 {
+		t.Fatalf("expected 2 queued events, got %d", depth)
+	}
+	if entry, _ := hook.peek(); entry.event.SessionID != "second" {
+		t.Errorf("expected the oldest event to be dropped, %s is first", entry.event.SessionID)
+	}
+	if hook.dropped != 1 {
+		t.Errorf("expected 1 dropped event, got %d", hook.dropped)
+	}
+}
+
+func TestWebhookRetriesInOrder(t *testing.T) {
+	server, events := newWebhookReceiver(1)
+	defer server.Close()
+	hook := newWebhook(server.URL, 10, time.Second)
+	quit := make(chan struct{})
+	defer close(quit)
+	hook.goDeliver(quit)
+
+	hook.enqueue(SessionEvent{SessionID: "first"})
+	hook.enqueue(SessionEvent{SessionID: "second"})
+	for _, expected := range []string{"first", "second"} {
+		if event := receiveEvent(t, events); event.SessionID != expected {
+			t.Errorf("expected event of %s, got %s", expected, event.SessionID)
+		}
+	}
+}
+
+func TestWebhookSessionEvents(t *testing.T) {
+	receiver, events := newWebhookReceiver(0)
+	defer receiver.Close()
+	options := testOptions()
+	options.WebhookURL = receiver.URL
+	app := newTestApp(t, options)
+	quit := make(chan struct{})
+	defer close(quit)
+	app.webhook.goDeliver(quit)
+	server := startTestServer(app)
+	defer server.Close()
+
+	conn := dialTestSession(t, server, InitMessage{})
+	id := waitSessions(t, app, 1)[0].ID
+	connect := receiveEvent(t, events)
+	if connect.Event != "connect" || connect.SessionID != id || connect.Command[0] != "cat" || connect.Pid == 0 {
+		t.Errorf("unexpected connect event %+v", connect)
+	}
+
+	conn.Close()
+	for {
+		event := receiveEvent(t, events)
+		if event.SessionID != id {
+			t.Fatalf("event of an unknown session %+v", event)
+		}
+		if event.Event == "disconnect" {
+			if event.Duration <= 0 {
+				t.Errorf("disconnect event without duration %+v", event)
+			}
+			break
+		}
+	}
+}
+
+func TestWebhookVerifiedUser(t *testing.T) {
+	receiver, events := newWebhookReceiver(0)
+	defer receiver.Close()
+	options := testOptions()
+	options.WebhookURL = receiver.URL
+	options.EnableBasicAuth = true
+	options.Credential = "alice:secret"
+	app := newTestApp(t, options)
+	quit := make(chan struct{})
+	defer close(quit)
+	app.webhook.goDeliver(quit)
+	server := startTestServer(app)
+	defer server.Close()
+	token := fetchAuthToken(t, app, "alice", "secret")
+
+	// The Authorization header of /ws isn't checked by wrapBasicAuth, a made-up user is not reported.
+	tests := []struct {
+		user     string
+		password string
+		expected string
+	}{
+		{"alice", "secret", "alice"},
+		{"mallory", "guessed", ""},
+	}
+	for _, test := range tests {
+		conn := dialAuthenticatedSession(t, server, test.user, test.password, token)
+		id := waitSessions(t, app, 1)[0].ID
+		connect := receiveEvent(t, events)
+		if connect.Event != "connect" || connect.SessionID != id || connect.User != test.expected {
+			t.Errorf("%s: unexpected connect event %+v", test.user, connect)
+		}
+		conn.Close()
+		for receiveEvent(t, events).Event != "disconnect" {
+		}
+		waitSessions(t, app, 0)
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/user"
+	"syscall"
+)
+
+// resolveWorkingDir returns the directory commands start in.
+// Without WorkingDir, commands run by another user than gotty start in the home directory of that user,
+// otherwise they inherit the working directory of gotty, which is denoted by an empty string.
+// With Chroot, WorkingDir is a path inside it, and commands start in its root by default.
+func (app *App) resolveWorkingDir() string {
+	if app.options.WorkingDir != "" {
+		return ExpandHomeDir(app.options.WorkingDir)
+	}
+	if app.chroot != "" {
+		return "/"
+	}
+
+	current, err := user.Current()
+	if err == nil && current.Username == app.options.RunAsUser {
+		return ""
+	}
+	u, err := user.Lookup(app.options.RunAsUser)
+	if err != nil {
+		log.Printf("Failed to look up the home directory of user %q: %v", app.options.RunAsUser, err)
+		return ""
+	}
+	return u.HomeDir
+}
+
+// checkWorkingDir verifies that dir is a directory the command user can enter.
+func checkWorkingDir(dir string, credential *syscall.Credential) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("Not a directory: " + dir)
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || credential.Uid == 0 {
+		return nil
+	}
+	var mode os.FileMode
+	switch {
+	case stat.Uid == credential.Uid:
+		mode = 0100
+	case stat.Gid == credential.Gid:
+		mode = 0010
+	default:
+		mode = 0001
+	}
+	if info.Mode().Perm()&mode == 0 {
+		return errors.New("Permission denied: " + dir)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"os"
 	"reflect"
 	"strings"
 
@@ -34,7 +35,8 @@ func generateFlags(flags []flag, hint map[string]string) ([]cli.Flag, error) {
 		if flag.shortName != "" {
 			flagName += ", " + flag.shortName
 		}
-		envName := "GOTTY_" + strings.ToUpper(strings.Join(strings.Split(flag.name, "-"), "_"))
+		// The variable read by app.ApplyEnv, e.g. GOTTY_TLS_CRT_FILE for --tls-crt.
+		envName := app.EnvName(field.Tag("hcl"))
 
 		switch field.Kind() {
 		case reflect.String:
@@ -89,6 +91,23 @@ func applyFlags(
 	}
 }
 
+// applyEnablingFlags turns on the feature of the options enabling it, which map flags to the
+// field of the feature, when they are given on the command line or in the environment.
+func applyEnablingFlags(
+	options *app.Options,
+	enablingFlags map[string]string,
+	mappingHint map[string]string,
+	c *cli.Context,
+) {
+	o := structs.New(options)
+	for name, enable := range enablingFlags {
+		field := o.Field(fieldName(name, mappingHint))
+		if c.IsSet(name) || os.Getenv(app.EnvName(field.Tag("hcl"))) != "" {
+			o.Field(enable).Set(true)
+		}
+	}
+}
+
 func fieldName(name string, hint map[string]string) string {
 	if fieldName, ok := hint[name]; ok {
 		return fieldName
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/codegangsta/cli"
+
+	"github.com/yudai/gotty/app"
+)
+
+// Options are taken from the config file, then the environment, then the command line.
+func TestOptionsPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gotty.hcl")
+	ioutil.WriteFile(path, []byte("port = \"9000\"\naddress = \"127.0.0.1\"\ntitle_format = \"file\"\n"), 0600)
+	t.Setenv("GOTTY_PORT", "9001")
+	t.Setenv("GOTTY_TITLE_FORMAT", "env")
+
+	flags := []flag{
+		flag{"address", "a", ""},
+		flag{"port", "p", ""},
+		flag{"title-format", "", ""},
+	}
+	cliFlags, err := generateFlags(flags, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := app.DefaultOptions
+	cmd := cli.NewApp()
+	cmd.Flags = cliFlags
+	cmd.Action = func(c *cli.Context) {
+		if err := app.ApplyConfigFile(&options, path); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.ApplyEnv(&options); err != nil {
+			t.Fatal(err)
+		}
+		applyFlags(&options, flags, nil, c)
+	}
+	if err := cmd.Run([]string{"gotty", "--port", "9002", "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if options.Address != "127.0.0.1" {
+		t.Errorf("expected the address of the config file, got %q", options.Address)
+	}
+	if options.TitleFormat != "env" {
+		t.Errorf("expected the title format of the environment, got %q", options.TitleFormat)
+	}
+	if options.Port != "9002" {
+		t.Errorf("expected the port of the command line, got %q", options.Port)
+	}
+}
+
+// Flags advertise the environment variables read by app.ApplyEnv.
+func TestFlagsEnvVar(t *testing.T) {
+	flags := []flag{
+		flag{"tls-crt", "", ""},
+		flag{"random-url", "r", ""},
+	}
+	hint := map[string]string{"tls-crt": "TLSCrtFile", "random-url": "EnableRandomUrl"}
+	cliFlags, err := generateFlags(flags, hint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envVar := cliFlags[0].(cli.StringFlag).EnvVar; envVar != "GOTTY_TLS_CRT_FILE" {
+		t.Errorf("unexpected variable of --tls-crt: %s", envVar)
+	}
+	if envVar := cliFlags[1].(cli.BoolFlag).EnvVar; envVar != "GOTTY_ENABLE_RANDOM_URL" {
+		t.Errorf("unexpected variable of --random-url: %s", envVar)
+	}
+
+	t.Setenv("GOTTY_TLS_CRT_FILE", "/etc/gotty.crt")
+	options := app.DefaultOptions
+	if err := app.ApplyEnv(&options); err != nil {
+		t.Fatal(err)
+	}
+	if options.TLSCrtFile != "/etc/gotty.crt" {
+		t.Errorf("advertised variable was not applied: %q", options.TLSCrtFile)
+	}
+}
+
+func TestEnablingFlags(t *testing.T) {
+	flags := []flag{
+		flag{"credential", "c", ""},
+		flag{"tls-ca-crt", "", ""},
+	}
+	hint := map[string]string{"tls-ca-crt": "TLSCACrtFile"}
+	enablingFlags := map[string]string{
+		"credential": "EnableBasicAuth",
+		"tls-ca-crt": "EnableTLSClientAuth",
+	}
+	cliFlags, err := generateFlags(flags, hint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		env        string
+		basicAuth  bool
+		clientAuth bool
+	}{
+		{"credential flag", []string{"--credential", "user:pass"}, "", true, false},
+		{"CA flag", []string{"--tls-ca-crt", "/etc/ca.crt"}, "", false, true},
+		{"credential variable", nil, "GOTTY_CREDENTIAL", true, false},
+		{"CA variable", nil, "GOTTY_TLS_CA_CRT_FILE", false, true},
+		{"none", nil, "", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("GOTTY_CREDENTIAL", "")
+			t.Setenv("GOTTY_TLS_CA_CRT_FILE", "")
+			if test.env != "" {
+				t.Setenv(test.env, "given")
+			}
+			options := app.DefaultOptions
+			cmd := cli.NewApp()
+			cmd.Flags = cliFlags
+			cmd.Action = func(c *cli.Context) {
+				applyEnablingFlags(&options, enablingFlags, hint, c)
+			}
+			if err := cmd.Run(append(append([]string{"gotty"}, test.args...), "true")); err != nil {
+				t.Fatal(err)
+			}
+			if options.EnableBasicAuth != test.basicAuth || options.EnableTLSClientAuth != test.clientAuth {
+				t.Errorf("basic auth %v, client auth %v", options.EnableBasicAuth, options.EnableTLSClientAuth)
+			}
+		})
+	}
+}
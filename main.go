@@ -22,13 +22,17 @@ func main() {
 		flag{"address", "a", "IP address to listen"},
 		flag{"port", "p", "Port number to listen"},
 		flag{"permit-write", "w", "Permit clients to write to the TTY (BE CAREFUL)"},
+		flag{"output-only", "", "Run the command with stdin redirected to /dev/null and drop all client input"},
 		flag{"credential", "c", "Credential for Basic Authentication (ex: user:pass, default disabled)"},
 		flag{"random-url", "r", "Add a random string to the URL"},
 		flag{"random-url-length", "", "Random URL length"},
+		flag{"print-qr", "", "Print the URLs as QR codes at startup"},
 		flag{"tls", "t", "Enable TLS/SSL"},
 		flag{"tls-crt", "", "TLS/SSL certificate file path"},
 		flag{"tls-key", "", "TLS/SSL key file path"},
 		flag{"tls-ca-crt", "", "TLS/SSL CA certificate file for client certifications"},
+		flag{"tls-min-version", "", "Minimum TLS/SSL version (1.0, 1.1, 1.2 or 1.3)"},
+		flag{"generate-self-signed-cert", "", "Generate a self-signed certificate when the TLS/SSL certificate and key files don't exist"},
 		flag{"index", "", "Custom index.html file"},
 		flag{"title-format", "", "Title format of browser window"},
 		flag{"reconnect", "", "Enable reconnection"},
@@ -37,19 +41,90 @@ func main() {
 		flag{"max-connection", "", "Maximum connection to gotty, 0(default) means no limit"},
 		flag{"once", "", "Accept only one client and exit on disconnection"},
 		flag{"permit-arguments", "", "Permit clients to send command line arguments in URL (e.g. http://example.com:8080/?arg=AAA&arg=BBB)"},
+		flag{"argument-transform", "", "Template applied to each argument sent by clients (e.g. --param={{ .Arg }})"},
+		flag{"max-paste-bytes", "", "Maximum size of a single input message such as a paste, 0(default) means no limit"},
+		flag{"paste-overflow", "", "What to do with input exceeding the paste limit (reject or truncate)"},
+		flag{"state-file", "", "File to keep the list of active sessions in, for inspection after a crash"},
+		flag{"ws-ping-interval", "", "Interval seconds to send websocket pings to clients (0 to disable)"},
+		flag{"ws-pong-timeout", "", "Seconds to wait for a pong before closing the connection"},
+		flag{"max-clock-skew", "", "Maximum seconds the timestamp sent by clients can differ from the server time, 0(default) means no check"},
+		flag{"per-user-home", "", "Give each authenticated user their own HOME directory under the home base directory"},
+		flag{"home-base-dir", "", "Base directory of per user HOME directories"},
+		flag{"record-dir", "", "Directory to record sessions to in the asciinema format"},
+		flag{"public-url", "", "Public URL of gotty, only websocket connections from its origin are accepted when given"},
+		flag{"metrics", "", "Enable Prometheus metrics"},
+		flag{"metrics-path", "", "Path to serve Prometheus metrics at"},
+		flag{"metrics-skip-auth", "", "Serve metrics without Basic Authentication, e.g. for scrapers"},
+		flag{"health-check-path", "", "Path to serve the health check at without authentication (empty to disable)"},
+		flag{"webhook-url", "", "URL to post session connect and disconnect events to"},
+		flag{"webhook-queue-size", "", "Maximum number of events kept while the webhook is unavailable"},
+		flag{"webhook-max-backoff", "", "Maximum seconds to wait between webhook retries"},
+		flag{"manifest-arguments", "", "How command arguments are shown to clients in the session manifest (full, redacted or none)"},
+		flag{"startup-buffer-size", "", "Bytes of command output kept while a new client is being initialized"},
+		flag{"k8s-events", "", "Create Kubernetes events of the pod when sessions start and stop"},
+		flag{"clear-env", "", "Don't pass the environment variables of gotty to the command"},
+		flag{"working-dir", "", "Directory the command starts in (default: home directory of the run-as user when it differs from gotty's)"},
+		flag{"reconnect-rate", "", "Maximum number of reconnecting clients admitted per second across the server (0 for unlimited)"},
+		flag{"reconnect-burst", "", "Number of reconnecting clients admitted at once before the reconnect rate applies"},
+		flag{"tls-plaintext-fallback", "", "Also accept plaintext connections on the TLS port, e.g. while migrating clients to TLS"},
+		flag{"client-config", "", "Serve the preferences and client-facing options at config.json"},
+		flag{"idle-timeout", "", "Close sessions when the client sends no input for this many seconds (0 to disable)"},
+		flag{"record-fifo", "", "Directory to create a named pipe per session in, which the output is copied to"},
+		flag{"max-session-duration", "", "Close sessions after this many seconds regardless of activity (0 to disable)"},
+		flag{"shutdown-timeout", "", "Seconds to wait for sessions to close on exit before force-closing them (0 to wait forever)"},
+		flag{"tls-crl", "", "CRL file to reject revoked client certificates, reloaded when it changes"},
+		flag{"log-format", "", "Format of request and connection logs (text or json)"},
+		flag{"strict-run-as-user", "", "Refuse to start when the user to run commands as can't be looked up, instead of running them as root"},
+		flag{"max-concurrent-recordings", "", "Maximum number of sessions recorded at the same time (0 to disable)"},
+		flag{"recording-limit-policy", "", "What to do with sessions over max-concurrent-recordings (skip their recording or queue them)"},
+		flag{"transcript-dir", "", "Directory to write plain text transcripts of sessions to, without escape sequences"},
+		flag{"fallback-ui", "", "Serve a minimal terminal page when the bundled frontend is not built in"},
+		flag{"trust-x-forwarded-for", "", "Take the client IP from X-Forwarded-For when requests come from trusted proxies"},
+		flag{"max-pending-upgrades", "", "Maximum number of websocket handshakes in progress, others are rejected (0 to disable)"},
+		flag{"umask", "", "Umask of commands in octal, e.g. 027 (default: inherited from gotty)"},
+		flag{"auth-max-attempts", "", "Failed basic authentications after which a client IP is locked out (0 to disable)"},
+		flag{"auth-lockout-seconds", "", "Duration of the lockout, which is also the window failures are counted in"},
+		flag{"status-path", "", "Path to serve the server status with the connected clients at, e.g. /status (default: disabled)"},
+		flag{"admin-address", "", "Address of the admin listener, separate from the public one, e.g. 127.0.0.1:6060"},
+		flag{"pprof", "", "Serve Go runtime profiles on the admin listener, only to admin users with basic authentication"},
+		flag{"shared-session", "", "Share the command of the first client with the next ones requesting the same command, which are read-only spectators"},
+		flag{"pty-reserve", "", "Number of PTYs of the system kept free by refusing new sessions (Linux only)"},
+		flag{"min-client-key-bits", "", "Minimum size of the keys of TLS client certificates in RSA bits, elliptic curve keys are compared by equivalent strength"},
+		flag{"unix-socket", "", "Path of a unix socket to listen on instead of the address and port"},
+		flag{"unix-socket-mode", "", "Permissions of the unix socket"},
+		flag{"interface", "", "Network interface to listen on the primary address of, e.g. eth0"},
 		flag{"close-signal", "", "Signal sent to the command process when gotty close it (default: SIGHUP)"},
 		flag{"width", "", "Static width of the screen, 0(default) means dynamically resize"},
 		flag{"height", "", "Static height of the screen, 0(default) means dynamically resize"},
 	}
 
 	mappingHint := map[string]string{
-		"index":      "IndexFile",
-		"tls":        "EnableTLS",
-		"tls-crt":    "TLSCrtFile",
-		"tls-key":    "TLSKeyFile",
-		"tls-ca-crt": "TLSCACrtFile",
-		"random-url": "EnableRandomUrl",
-		"reconnect":  "EnableReconnect",
+		"index":                  "IndexFile",
+		"tls":                    "EnableTLS",
+		"tls-crt":                "TLSCrtFile",
+		"tls-key":                "TLSKeyFile",
+		"tls-ca-crt":             "TLSCACrtFile",
+		"tls-min-version":        "TLSMinVersion",
+		"tls-plaintext-fallback": "TLSPlaintextFallback",
+		"pprof":                  "EnablePprof",
+		"fallback-ui":            "EnableFallbackUI",
+		"tls-crl":                "TLSCRLFile",
+		"random-url":             "EnableRandomUrl",
+		"reconnect":              "EnableReconnect",
+		"print-qr":               "PrintQR",
+		"metrics":                "EnableMetrics",
+		"k8s-events":             "EnableK8sEvents",
+		"client-config":          "EnableClientConfig",
+		"public-url":             "PublicURL",
+		"ws-ping-interval":       "WSPingInterval",
+		"ws-pong-timeout":        "WSPongTimeout",
+		"webhook-url":            "WebhookURL",
+	}
+
+	// Giving these options enables their feature.
+	enablingFlags := map[string]string{
+		"credential": "EnableBasicAuth",
+		"tls-ca-crt": "EnableTLSClientAuth",
 	}
 
 	cliFlags, err := generateFlags(flags, mappingHint)
@@ -84,15 +159,13 @@ func main() {
 			}
 		}
 
-		applyFlags(&options, flags, mappingHint, c)
-
-		if c.IsSet("credential") {
-			options.EnableBasicAuth = true
-		}
-		if c.IsSet("tls-ca-crt") {
-			options.EnableTLSClientAuth = true
+		if err := app.ApplyEnv(&options); err != nil {
+			exit(err, 2)
 		}
 
+		applyFlags(&options, flags, mappingHint, c)
+		applyEnablingFlags(&options, enablingFlags, mappingHint, c)
+
 		if err := app.CheckConfig(&options); err != nil {
 			exit(err, 6)
 		}
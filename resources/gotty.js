@@ -72,6 +72,9 @@
                 autoReconnect = JSON.parse(data);
                 console.log("Enabling reconnect: " + autoReconnect + " seconds")
                 break;
+            case '7':
+                reauthenticate(ws);
+                break;
             }
         };
 
@@ -92,5 +95,25 @@
         ws.send("1");
     }
 
+    // Fetch a fresh token with the credentials the browser holds,
+    // so that the session ends once they are no longer valid.
+    var reauthenticate = function(ws) {
+        var request = new XMLHttpRequest();
+        request.open("GET", window.location.pathname + "auth_token.js");
+        request.onload = function() {
+            var token = "";
+            var match = /^var gotty_auth_token = (.*);$/.exec(request.responseText);
+            if (request.status == 200 && match) {
+                token = JSON.parse(match[1]);
+                gotty_auth_token = token;
+            }
+            ws.send("3" + JSON.stringify({ AuthToken: token }));
+        };
+        request.onerror = function() {
+            ws.send("3" + JSON.stringify({ AuthToken: gotty_auth_token }));
+        };
+        request.send();
+    }
+
     openWs();
 })()